@@ -0,0 +1,30 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// adminToken guards admin-only endpoints; they are disabled entirely when unset
+var adminToken = getEnv("ADMIN_TOKEN", "")
+
+// adminOnly rejects requests that don't carry a matching X-Admin-Token header
+func adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			writeError(w, r, newAppError(http.StatusForbidden, "admin_disabled", "admin endpoints are disabled; set ADMIN_TOKEN to enable"))
+			return
+		}
+		token := r.Header.Get("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			logWarnContext(r.Context(), "Rejected admin request", map[string]interface{}{
+				"remote_addr": r.RemoteAddr,
+				"method":      r.Method,
+				"path":        r.URL.Path,
+			})
+			writeError(w, r, newAppError(http.StatusUnauthorized, "invalid_admin_token", "invalid admin token"))
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"go-service/middleware"
+)
+
+// AppError is the error handlers return to clients. Code is a stable, machine-readable
+// identifier (and the error.type of the span); Message is safe to show to callers. Err is
+// the underlying cause, which is logged but never rendered.
+type AppError struct {
+	Status    int
+	Code      string
+	Message   string
+	Retryable bool
+	Details   map[string]interface{}
+	Err       error
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Message + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+func newAppError(status int, code, message string) *AppError {
+	return &AppError{Status: status, Code: code, Message: message}
+}
+
+// badRequest is the AppError for invalid input
+func badRequest(message string) *AppError {
+	return newAppError(http.StatusBadRequest, "invalid_request", message)
+}
+
+// unavailable is a retryable 503, e.g. for a dependency that is not configured or full
+func unavailable(code, message string) *AppError {
+	return newAppError(http.StatusServiceUnavailable, code, message).retryable()
+}
+
+// retryable marks e as safe for the client to retry
+func (e *AppError) retryable() *AppError {
+	e.Retryable = true
+	return e
+}
+
+// withCause records err as the cause of e
+func (e *AppError) withCause(err error) *AppError {
+	e.Err = err
+	return e
+}
+
+// withDetails adds machine-readable context rendered under "details"
+func (e *AppError) withDetails(details map[string]interface{}) *AppError {
+	e.Details = details
+	return e
+}
+
+// writeError renders err through middleware.WriteProblem, so handler errors look and are
+// recorded like the ones middleware writes. Errors that are not an AppError become an
+// opaque 500.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *AppError
+	if !errors.As(err, &appErr) {
+		appErr = newAppError(http.StatusInternalServerError, "internal", "internal error").withCause(err)
+	}
+	middleware.WriteProblem(w, r, middleware.Problem{
+		Status:    appErr.Status,
+		Code:      appErr.Code,
+		Message:   appErr.Message,
+		Retryable: appErr.Retryable,
+		Details:   appErr.Details,
+		Err:       appErr.Err,
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// BaggageResponse reports the baggage sent downstream and what the downstream call returned
+type BaggageResponse struct {
+	TraceID    string            `json:"trace_id"`
+	Baggage    map[string]string `json:"baggage"`
+	Downstream CallResult        `json:"downstream"`
+}
+
+// baggageHandler adds user.tier and demo.flag to the request baggage (from ?tier= and ?flag=)
+// and calls the TypeScript service, which receives them in the baggage header
+func baggageHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+
+	bag := baggage.FromContext(ctx)
+	for key, value := range map[string]string{
+		"user.tier": queryDefault(r, "tier", "gold"),
+		"demo.flag": queryDefault(r, "flag", "on"),
+	} {
+		m, err := baggage.NewMemberRaw(key, value)
+		if err == nil {
+			bag, err = bag.SetMember(m)
+		}
+		if err != nil {
+			writeError(w, r, badRequest("invalid baggage value for "+key))
+			return
+		}
+	}
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+	span.SetAttributes(obs.BaggageAttributes(ctx)...)
+	span.SetAttributes(attribute.String("peer.service", nodeTarget.Name))
+
+	result := nodeTarget.call(ctx, outboundPath(r))
+	obs.InfoContext(ctx, "Baggage forwarded downstream", map[string]interface{}{
+		"target": nodeTarget.Name,
+		"status": result.Status,
+	})
+
+	members := make(map[string]string, bag.Len())
+	for _, m := range bag.Members() {
+		members[m.Key()] = m.Value()
+	}
+	writeJSON(w, callStatus(result), BaggageResponse{
+		TraceID:    span.SpanContext().TraceID().String(),
+		Baggage:    members,
+		Downstream: result,
+	})
+}
+
+// queryDefault returns the query parameter name, or fallback when it is absent
+func queryDefault(r *http.Request, name, fallback string) string {
+	if v := r.URL.Query().Get(name); v != "" {
+		return v
+	}
+	return fallback
+}
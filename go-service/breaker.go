@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// errCircuitOpen is returned without calling the target while the breaker is open
+var errCircuitOpen = errors.New("circuit breaker open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerHalfOpen:
+		return "half_open"
+	case breakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+var (
+	breakerStateGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "circuit_breaker_state",
+			Help: "Circuit breaker state per outbound target (0 closed, 1 half-open, 2 open)",
+		},
+		[]string{"target"},
+	)
+
+	breakerTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_transitions_total",
+			Help: "Total number of circuit breaker state transitions",
+		},
+		[]string{"target", "from", "to"},
+	)
+
+	breakerRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "circuit_breaker_rejected_total",
+			Help: "Total number of calls short-circuited by an open breaker",
+		},
+		[]string{"target"},
+	)
+)
+
+func init() {
+	obs.Registry.MustRegister(breakerStateGauge)
+	obs.Registry.MustRegister(breakerTransitions)
+	obs.Registry.MustRegister(breakerRejected)
+}
+
+// breakerConfig sets when a breaker trips and how it recovers
+type breakerConfig struct {
+	FailureThreshold int           // consecutive failures that open the breaker
+	OpenTimeout      time.Duration // time spent open before allowing trial calls
+	HalfOpenMax      int           // concurrent trial calls allowed while half-open
+}
+
+func loadBreakerConfig() breakerConfig {
+	return breakerConfig{
+		FailureThreshold: getEnvInt("BREAKER_FAILURE_THRESHOLD", 5),
+		OpenTimeout:      getEnvDuration("BREAKER_OPEN_TIMEOUT", 30*time.Second),
+		HalfOpenMax:      getEnvInt("BREAKER_HALF_OPEN_REQUESTS", 1),
+	}
+}
+
+// circuitBreaker is a consecutive-failure breaker guarding one outbound target
+type circuitBreaker struct {
+	target string
+	cfg    breakerConfig
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+	trials   int
+}
+
+func newCircuitBreaker(target string, cfg breakerConfig) *circuitBreaker {
+	breakerStateGauge.WithLabelValues(target).Set(float64(breakerClosed))
+	return &circuitBreaker{target: target, cfg: cfg}
+}
+
+// Do runs fn unless the breaker is open; fn's error decides whether the call counts as a
+// failure. A call the caller cancelled, such as one whose inbound request has ended, counts
+// as neither, so clients disconnecting cannot open the breaker on a healthy target.
+func (b *circuitBreaker) Do(ctx context.Context, fn func() error) error {
+	if !b.before(ctx) {
+		breakerRejected.WithLabelValues(b.target).Inc()
+		return errCircuitOpen
+	}
+	err := fn()
+	if errors.Is(err, context.Canceled) {
+		b.abandon()
+		return err
+	}
+	b.after(ctx, err == nil)
+	return err
+}
+
+// abandon releases a half-open trial slot without recording an outcome
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.trials--
+	}
+}
+
+func (b *circuitBreaker) before(ctx context.Context) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerOpen {
+		if time.Since(b.openedAt) < b.cfg.OpenTimeout {
+			return false
+		}
+		b.transition(ctx, breakerHalfOpen)
+	}
+	if b.state == breakerHalfOpen {
+		if b.trials >= b.cfg.HalfOpenMax {
+			return false
+		}
+		b.trials++
+	}
+	return true
+}
+
+func (b *circuitBreaker) after(ctx context.Context, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.trials--
+		if success {
+			b.transition(ctx, breakerClosed)
+		} else {
+			b.transition(ctx, breakerOpen)
+		}
+		return
+	}
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == breakerClosed && b.failures >= b.cfg.FailureThreshold {
+		b.transition(ctx, breakerOpen)
+	}
+}
+
+// transition must be called with mu held
+func (b *circuitBreaker) transition(ctx context.Context, to breakerState) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	b.failures = 0
+	if to == breakerOpen {
+		b.openedAt = time.Now()
+	}
+	if to != breakerHalfOpen {
+		b.trials = 0
+	}
+
+	breakerStateGauge.WithLabelValues(b.target).Set(float64(to))
+	breakerTransitions.WithLabelValues(b.target, from.String(), to.String()).Inc()
+	trace.SpanFromContext(ctx).AddEvent("circuit_breaker.transition", trace.WithAttributes(
+		attribute.String("circuit_breaker.target", b.target),
+		attribute.String("circuit_breaker.from", from.String()),
+		attribute.String("circuit_breaker.to", to.String()),
+	))
+
+	logFunc := logInfoContext
+	if to == breakerOpen {
+		logFunc = logWarnContext
+	}
+	logFunc(ctx, "Circuit breaker state changed", map[string]interface{}{
+		"target": b.target,
+		"from":   from.String(),
+		"to":     to.String(),
+	})
+}
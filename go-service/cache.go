@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// maxCacheValueBytes bounds PUT bodies so the demo cache can't be used as a blob store
+const maxCacheValueBytes = 64 << 10
+
+var (
+	// cacheClient is nil when REDIS_ADDR is unset, in which case /cache returns 503
+	cacheClient *redis.Client
+
+	cacheDefaultTTL = getEnvDuration("CACHE_DEFAULT_TTL", 5*time.Minute)
+
+	cacheRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_requests_total",
+			Help: "Total number of cache lookups by result",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() {
+	obs.Registry.MustRegister(cacheRequestsTotal)
+}
+
+type CacheEntry struct {
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	TTLSeconds int64  `json:"ttl_seconds,omitempty"`
+}
+
+// initCache connects the Redis client with tracing and metrics hooks enabled
+func initCache() {
+	addr := getEnv("REDIS_ADDR", "")
+	if addr == "" {
+		logInfo("REDIS_ADDR not set, /cache endpoints disabled", nil)
+		return
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: getEnv("REDIS_PASSWORD", ""),
+		DB:       getEnvInt("REDIS_DB", 0),
+	})
+	if err := redisotel.InstrumentTracing(client); err != nil {
+		logWarn("Failed to instrument Redis tracing", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if err := redisotel.InstrumentMetrics(client); err != nil {
+		logWarn("Failed to instrument Redis metrics", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	cacheClient = client
+	logInfo("Cache initialized", map[string]interface{}{
+		"redis_addr": addr,
+	})
+}
+
+// requireCache short-circuits /cache requests when no Redis is configured
+func requireCache(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cacheClient == nil {
+			writeError(w, r, unavailable("cache_not_configured", "cache not configured"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// recordCacheResult counts the lookup and tags the request span with the outcome
+func recordCacheResult(r *http.Request, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequestsTotal.WithLabelValues(result).Inc()
+	trace.SpanFromContext(r.Context()).SetAttributes(attribute.Bool("cache.hit", hit))
+}
+
+func writeCacheError(w http.ResponseWriter, r *http.Request, err error) {
+	cacheRequestsTotal.WithLabelValues("error").Inc()
+	logErrorContext(r.Context(), "Cache operation failed", map[string]interface{}{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"error":  err.Error(),
+	})
+	writeError(w, r, newAppError(http.StatusBadGateway, "cache_error", "cache error").withCause(err).retryable())
+}
+
+func getCacheHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	value, err := cacheClient.Get(r.Context(), key).Result()
+	if errors.Is(err, redis.Nil) {
+		recordCacheResult(r, false)
+		writeError(w, r, newAppError(http.StatusNotFound, "key_not_found", "key not found"))
+		return
+	}
+	if err != nil {
+		writeCacheError(w, r, err)
+		return
+	}
+	recordCacheResult(r, true)
+
+	entry := CacheEntry{Key: key, Value: value}
+	if ttl, err := cacheClient.TTL(r.Context(), key).Result(); err == nil && ttl > 0 {
+		entry.TTLSeconds = int64(ttl.Seconds())
+	}
+	writeJSON(w, http.StatusOK, entry)
+}
+
+func setCacheHandler(w http.ResponseWriter, r *http.Request) {
+	key := mux.Vars(r)["key"]
+
+	var in struct {
+		Value      string `json:"value"`
+		TTLSeconds int64  `json:"ttl_seconds"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCacheValueBytes)).Decode(&in); err != nil {
+		writeError(w, r, badRequest("body must be JSON: {\"value\": \"...\", \"ttl_seconds\": 60}"))
+		return
+	}
+	if in.TTLSeconds < 0 {
+		writeError(w, r, badRequest("ttl_seconds must be non-negative"))
+		return
+	}
+	ttl := cacheDefaultTTL
+	if in.TTLSeconds > 0 {
+		ttl = time.Duration(in.TTLSeconds) * time.Second
+	}
+
+	if err := cacheClient.Set(r.Context(), key, in.Value, ttl).Err(); err != nil {
+		writeCacheError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, CacheEntry{Key: key, Value: in.Value, TTLSeconds: int64(ttl.Seconds())})
+}
+
+func deleteCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if err := cacheClient.Del(r.Context(), mux.Vars(r)["key"]).Err(); err != nil {
+		writeCacheError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/middleware"
+	"go-service/obs"
+)
+
+const (
+	defaultChaosDuration = 5 * time.Minute
+	maxChaosDuration     = time.Hour
+	maxChaosLatency      = 30 * time.Second
+	maxChaosLeak         = 100
+)
+
+// errChaosDropped fails an outbound call before it is sent; it counts as a breaker failure
+var errChaosDropped = errors.New("outbound call dropped by chaos mode")
+
+var (
+	chaosInjections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chaos_injections_total",
+			Help: "Total number of faults injected by chaos mode by kind",
+		},
+		[]string{"kind"},
+	)
+
+	chaosActive = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "chaos_mode_active",
+			Help: "1 while chaos mode is enabled",
+		},
+	)
+
+	chaosLeakedGoroutines = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "chaos_leaked_goroutines",
+			Help: "Goroutines deliberately leaked by chaos mode; released when it ends",
+		},
+	)
+)
+
+func init() {
+	obs.Registry.MustRegister(chaosInjections)
+	obs.Registry.MustRegister(chaosActive)
+	obs.Registry.MustRegister(chaosLeakedGoroutines)
+}
+
+// ChaosRequest is the POST /admin/chaos body. Rates are fractions of requests (or outbound
+// calls for DropRate) between 0 and 1.
+type ChaosRequest struct {
+	ErrorRate      float64 `json:"error_rate"`
+	LatencyMs      int     `json:"latency_ms"`
+	LatencyRate    float64 `json:"latency_rate"`
+	DropRate       float64 `json:"drop_rate"`
+	LeakGoroutines int     `json:"leak_goroutines"` // leaked per request
+	Duration       string  `json:"duration"`
+}
+
+func (c ChaosRequest) validate() (time.Duration, error) {
+	for name, rate := range map[string]float64{"error_rate": c.ErrorRate, "latency_rate": c.LatencyRate, "drop_rate": c.DropRate} {
+		if rate < 0 || rate > 1 {
+			return 0, fmt.Errorf("%s must be between 0 and 1", name)
+		}
+	}
+	if c.LatencyMs < 0 || time.Duration(c.LatencyMs)*time.Millisecond > maxChaosLatency {
+		return 0, fmt.Errorf("latency_ms must be between 0 and %d", maxChaosLatency.Milliseconds())
+	}
+	if c.LeakGoroutines < 0 || c.LeakGoroutines > maxChaosLeak {
+		return 0, fmt.Errorf("leak_goroutines must be between 0 and %d", maxChaosLeak)
+	}
+	if c.ErrorRate == 0 && (c.LatencyMs == 0 || c.LatencyRate == 0) && c.DropRate == 0 && c.LeakGoroutines == 0 {
+		return 0, errors.New("no chaos behavior enabled")
+	}
+
+	duration := defaultChaosDuration
+	if c.Duration != "" {
+		d, err := time.ParseDuration(c.Duration)
+		if err != nil || d <= 0 || d > maxChaosDuration {
+			return 0, fmt.Errorf("duration must be a Go duration up to %s", maxChaosDuration)
+		}
+		duration = d
+	}
+	return duration, nil
+}
+
+// chaosSession is one time-boxed chaos run
+type chaosSession struct {
+	cfg     ChaosRequest
+	started time.Time
+	until   time.Time
+	timer   *time.Timer
+	release chan struct{} // closed when the session ends, freeing leaked goroutines
+	once    sync.Once
+}
+
+type ChaosStatus struct {
+	Active           bool          `json:"active"`
+	Config           *ChaosRequest `json:"config,omitempty"`
+	ExpiresAt        string        `json:"expires_at,omitempty"`
+	RemainingSeconds float64       `json:"remaining_seconds,omitempty"`
+}
+
+var activeChaos atomic.Pointer[chaosSession]
+
+func chaosStatus() ChaosStatus {
+	s := activeChaos.Load()
+	if s == nil {
+		return ChaosStatus{}
+	}
+	return ChaosStatus{
+		Active:           true,
+		Config:           &s.cfg,
+		ExpiresAt:        s.until.UTC().Format(time.RFC3339),
+		RemainingSeconds: time.Until(s.until).Seconds(),
+	}
+}
+
+func startChaos(cfg ChaosRequest, duration time.Duration) {
+	s := &chaosSession{cfg: cfg, started: time.Now(), until: time.Now().Add(duration), release: make(chan struct{})}
+	s.timer = time.AfterFunc(duration, func() { stopChaos(s, "expired") })
+	if prev := activeChaos.Swap(s); prev != nil {
+		prev.end("replaced")
+	}
+	chaosActive.Set(1)
+	logWarn("Chaos mode enabled", map[string]interface{}{
+		"error_rate":       cfg.ErrorRate,
+		"latency_ms":       cfg.LatencyMs,
+		"latency_rate":     cfg.LatencyRate,
+		"drop_rate":        cfg.DropRate,
+		"leak_goroutines":  cfg.LeakGoroutines,
+		"duration_seconds": duration.Seconds(),
+	})
+}
+
+// stopChaos ends s if it is still the active session
+func stopChaos(s *chaosSession, reason string) bool {
+	if s == nil || !activeChaos.CompareAndSwap(s, nil) {
+		return false
+	}
+	chaosActive.Set(0)
+	s.end(reason)
+	return true
+}
+
+func (s *chaosSession) end(reason string) {
+	s.once.Do(func() {
+		s.timer.Stop()
+		close(s.release)
+		logWarn("Chaos mode ended", map[string]interface{}{
+			"reason":          reason,
+			"elapsed_seconds": time.Since(s.started).Seconds(),
+			"leak_goroutines": s.cfg.LeakGoroutines,
+		})
+	})
+}
+
+// chaosHandler serves /admin/chaos: POST starts (or replaces) a session, DELETE stops it
+// and GET reports the current one
+func chaosHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var req ChaosRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, badRequest("invalid JSON body"))
+			return
+		}
+		duration, err := req.validate()
+		if err != nil {
+			writeError(w, r, badRequest(err.Error()))
+			return
+		}
+		startChaos(req, duration)
+	case http.MethodDelete:
+		stopChaos(activeChaos.Load(), "stopped")
+	}
+	writeJSON(w, http.StatusOK, chaosStatus())
+}
+
+// operationalRoute reports health checks and operational routes, which are kept out of
+// chaos (so the demo can always be observed and turned off) and out of the SLOs
+func operationalRoute(route string) bool {
+	switch route {
+	case "/health", "/readyz", "/metrics", "/slo":
+		return true
+	}
+	return strings.HasPrefix(route, "/admin/") || strings.HasPrefix(route, "/debug/") || strings.HasPrefix(route, "/stress/")
+}
+
+// recordChaos marks a fault on the span, in chaos_injections_total and in the logs
+func recordChaos(ctx context.Context, kind string, fields map[string]interface{}) {
+	chaosInjections.WithLabelValues(kind).Inc()
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Bool("chaos.injected", true))
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	fields["chaos_kind"] = kind
+	logWarnContext(ctx, "Chaos injected", fields)
+}
+
+// chaosMiddleware applies the active session's latency, goroutine leaks and 5xx responses
+func chaosMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := activeChaos.Load()
+		route := middleware.RouteTemplate(r)
+		if s == nil || operationalRoute(route) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+
+		if s.cfg.LatencyMs > 0 && rand.Float64() < s.cfg.LatencyRate {
+			delay := time.Duration(s.cfg.LatencyMs) * time.Millisecond
+			recordChaos(ctx, "latency", map[string]interface{}{"route": route, "delay_ms": s.cfg.LatencyMs})
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+			}
+			timer.Stop()
+		}
+
+		if s.cfg.LeakGoroutines > 0 {
+			recordChaos(ctx, "goroutine_leak", map[string]interface{}{"route": route, "goroutines": s.cfg.LeakGoroutines})
+			for i := 0; i < s.cfg.LeakGoroutines; i++ {
+				chaosLeakedGoroutines.Inc()
+				go func() {
+					<-s.release
+					chaosLeakedGoroutines.Dec()
+				}()
+			}
+		}
+
+		if s.cfg.ErrorRate > 0 && rand.Float64() < s.cfg.ErrorRate {
+			status := []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable}[rand.Intn(3)]
+			recordChaos(ctx, "error", map[string]interface{}{"route": route, "status": status})
+			trace.SpanFromContext(ctx).SetStatus(codes.Error, "chaos: injected error")
+			writeError(w, r, newAppError(status, "chaos_injected", "chaos: injected error"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// chaosDropCall reports whether the active session drops this outbound call
+func chaosDropCall(ctx context.Context, target string) bool {
+	s := activeChaos.Load()
+	if s == nil || s.cfg.DropRate == 0 || rand.Float64() >= s.cfg.DropRate {
+		return false
+	}
+	recordChaos(ctx, "dropped_call", map[string]interface{}{"target": target})
+	return true
+}
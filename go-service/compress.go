@@ -0,0 +1,195 @@
+package main
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/middleware"
+	"go-service/obs"
+)
+
+var (
+	compressionEnabled = getEnvBool("COMPRESSION_ENABLED", true)
+	compressionLevel   = getEnvInt("COMPRESSION_LEVEL", gzip.DefaultCompression)
+
+	responseUncompressedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_response_uncompressed_bytes_total",
+			Help: "Total response body bytes written by handlers before compression",
+		},
+		[]string{"endpoint", "encoding"},
+	)
+
+	responseCompressedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_response_compressed_bytes_total",
+			Help: "Total response body bytes sent on the wire after compression",
+		},
+		[]string{"endpoint", "encoding"},
+	)
+
+	gzipWriters = sync.Pool{New: func() interface{} {
+		zw, err := gzip.NewWriterLevel(io.Discard, compressionLevel)
+		if err != nil {
+			zw = gzip.NewWriter(io.Discard)
+		}
+		return zw
+	}}
+)
+
+func init() {
+	obs.Registry.MustRegister(responseUncompressedBytes)
+	obs.Registry.MustRegister(responseCompressedBytes)
+}
+
+// negotiateEncoding picks gzip over deflate from Accept-Encoding, or "" for identity
+func negotiateEncoding(header string) string {
+	var deflate bool
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.ReplaceAll(params, " ", "") == "q=0" {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "gzip", "*":
+			return "gzip"
+		case "deflate":
+			deflate = true
+		}
+	}
+	if deflate {
+		return "deflate"
+	}
+	return ""
+}
+
+// compressionMiddleware compresses JSON responses for clients that accept it.
+// It must run inside the router so route templates are resolved.
+func compressionMiddleware(next http.Handler) http.Handler {
+	if !compressionEnabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		// Upgraded connections (WebSockets) need the raw, hijackable writer
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		next.ServeHTTP(cw, r)
+		cw.Close()
+
+		if cw.active {
+			endpoint := middleware.RouteTemplate(r)
+			responseUncompressedBytes.WithLabelValues(endpoint, encoding).Add(float64(cw.uncompressed))
+			responseCompressedBytes.WithLabelValues(endpoint, encoding).Add(float64(cw.wire.n))
+			trace.SpanFromContext(r.Context()).SetAttributes(
+				attribute.String("http.response.content_encoding", encoding),
+				attribute.Int64("http.response.uncompressed_size", cw.uncompressed),
+				attribute.Int64("http.response.body.size", cw.wire.n),
+			)
+		}
+	})
+}
+
+// countingWriter counts the bytes that reach the underlying ResponseWriter
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// compressWriter decides on the first write whether the response is compressible
+type compressWriter struct {
+	http.ResponseWriter
+	encoding     string
+	decided      bool
+	active       bool
+	wire         countingWriter
+	zw           io.WriteCloser
+	uncompressed int64
+}
+
+func (cw *compressWriter) decide(status int) {
+	if cw.decided {
+		return
+	}
+	cw.decided = true
+	h := cw.Header()
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || !strings.HasPrefix(h.Get("Content-Type"), "application/json") {
+		return
+	}
+
+	cw.active = true
+	h.Set("Content-Encoding", cw.encoding)
+	h.Del("Content-Length")
+	cw.wire.w = cw.ResponseWriter
+	if cw.encoding == "gzip" {
+		zw := gzipWriters.Get().(*gzip.Writer)
+		zw.Reset(&cw.wire)
+		cw.zw = zw
+	} else {
+		// The HTTP deflate coding is the zlib format, not raw DEFLATE
+		zw, err := zlib.NewWriterLevel(&cw.wire, compressionLevel)
+		if err != nil {
+			zw = zlib.NewWriter(&cw.wire)
+		}
+		cw.zw = zw
+	}
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	cw.decide(code)
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.decided {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.active {
+		return cw.ResponseWriter.Write(p)
+	}
+	cw.uncompressed += int64(len(p))
+	return cw.zw.Write(p)
+}
+
+// Flush pushes buffered compressed output so streaming handlers still work
+func (cw *compressWriter) Flush() {
+	if cw.active {
+		if f, ok := cw.zw.(interface{ Flush() error }); ok {
+			f.Flush()
+		}
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *compressWriter) Close() {
+	if !cw.active {
+		return
+	}
+	cw.zw.Close()
+	if zw, ok := cw.zw.(*gzip.Writer); ok {
+		zw.Reset(io.Discard)
+		gzipWriters.Put(zw)
+	}
+}
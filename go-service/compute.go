@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// maxComputeItems caps ?items= so the transform phase stays a demo, not a CPU burner (see /stress/cpu)
+const maxComputeItems = 10000
+
+// computePhases are the phases of /compute, in order
+var computePhases = []string{"validate", "fetch", "transform", "render"}
+
+var computePhaseDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "compute_phase_duration_seconds",
+		Help:    "Duration of each /compute phase in seconds",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+	},
+	[]string{"phase", "status"},
+)
+
+func init() {
+	obs.Registry.MustRegister(computePhaseDuration)
+}
+
+type ComputePhase struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+type ComputeResponse struct {
+	Items    int            `json:"items"`
+	Checksum string         `json:"checksum"`
+	TraceID  string         `json:"trace_id"`
+	Phases   []ComputePhase `json:"phases"`
+}
+
+// errInjectedPhase is the cause recorded when ?fail= names a phase
+var errInjectedPhase = errors.New("injected phase failure")
+
+// computeHandler is the reference for manual instrumentation: each phase of the request
+// runs in its own child span, created with the service tracer, carrying compute.*
+// attributes and events, and timed in compute_phase_duration_seconds. ?items=100 sets
+// the workload and ?fail=<phase> makes that phase fail, to show how an error surfaces on
+// the phase span, the request span and the response.
+func computeHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	failPhase := query.Get("fail")
+	if failPhase != "" && !containsString(computePhases, failPhase) {
+		writeError(w, r, badRequest("fail must be one of validate, fetch, transform or render"))
+		return
+	}
+
+	var (
+		items    int
+		values   []string
+		digests  []string
+		checksum string
+		phases   []ComputePhase
+	)
+	steps := map[string]func(ctx context.Context, span trace.Span) error{
+		"validate": func(ctx context.Context, span trace.Span) error {
+			items = 100
+			if raw := query.Get("items"); raw != "" {
+				v, err := strconv.Atoi(raw)
+				if err != nil || v < 1 || v > maxComputeItems {
+					return badRequest("items must be between 1 and " + strconv.Itoa(maxComputeItems))
+				}
+				items = v
+			}
+			span.SetAttributes(attribute.Int("compute.items", items))
+			return nil
+		},
+		"fetch": func(ctx context.Context, span trace.Span) error {
+			// Stands in for a datastore read, with a realistic latency tail
+			delay := time.Duration(sampleLatency(5, 50) * float64(time.Millisecond))
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			values = make([]string, items)
+			for i := range values {
+				values[i] = "item-" + strconv.Itoa(i)
+			}
+			span.SetAttributes(attribute.Int("compute.fetched", len(values)))
+			return nil
+		},
+		"transform": func(ctx context.Context, span trace.Span) error {
+			digests = make([]string, len(values))
+			for i, v := range values {
+				sum := sha256.Sum256([]byte(v))
+				digests[i] = hex.EncodeToString(sum[:])
+				if (i+1)%1000 == 0 {
+					span.AddEvent("compute.transform.progress", trace.WithAttributes(attribute.Int("compute.transformed", i+1)))
+				}
+			}
+			sort.Strings(digests)
+			span.SetAttributes(attribute.Int("compute.transformed", len(digests)))
+			return nil
+		},
+		"render": func(ctx context.Context, span trace.Span) error {
+			h := sha256.New()
+			for _, d := range digests {
+				h.Write([]byte(d))
+			}
+			checksum = hex.EncodeToString(h.Sum(nil))[:16]
+			span.SetAttributes(attribute.String("compute.checksum", checksum))
+			return nil
+		},
+	}
+
+	ctx := r.Context()
+	tracer := otel.Tracer(serviceName)
+	for _, name := range computePhases {
+		phaseCtx, span := tracer.Start(ctx, "compute."+name,
+			trace.WithSpanKind(trace.SpanKindInternal),
+			trace.WithAttributes(attribute.String("compute.phase", name)),
+		)
+		span.AddEvent("compute.phase.started")
+		start := time.Now()
+		err := steps[name](phaseCtx, span)
+		if err == nil && name == failPhase {
+			err = errInjectedPhase
+		}
+		elapsed := time.Since(start)
+
+		status := "success"
+		if err != nil {
+			status = "error"
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.AddEvent("compute.phase.completed")
+		}
+		computePhaseDuration.WithLabelValues(name, status).Observe(elapsed.Seconds())
+		span.End()
+		phases = append(phases, ComputePhase{Name: name, DurationMs: float64(elapsed.Microseconds()) / 1000})
+
+		if err != nil {
+			var appErr *AppError
+			if !errors.As(err, &appErr) {
+				appErr = newAppError(http.StatusInternalServerError, "compute_failed", "compute phase failed").withCause(err)
+			}
+			writeError(w, r, appErr.withDetails(map[string]interface{}{"phase": name}))
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, ComputeResponse{
+		Items:    items,
+		Checksum: checksum,
+		TraceID:  trace.SpanFromContext(ctx).SpanContext().TraceID().String(),
+		Phases:   phases,
+	})
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-service/obs"
+)
+
+var corsRejectedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cors_rejected_requests_total",
+		Help: "Total number of cross-origin requests rejected by the CORS policy",
+	},
+	[]string{"kind", "reason"},
+)
+
+func init() {
+	obs.Registry.MustRegister(corsRejectedTotal)
+}
+
+// corsConfig is the CORS policy; an empty AllowedOrigins disables CORS handling entirely
+type corsConfig struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	MaxAge         int
+}
+
+func loadCORSConfig() corsConfig {
+	return corsConfig{
+		AllowedOrigins: splitList(getEnv("CORS_ALLOWED_ORIGINS", "")),
+		AllowedMethods: splitList(getEnv("CORS_ALLOWED_METHODS", "GET,POST,PUT,DELETE,OPTIONS")),
+		AllowedHeaders: splitList(getEnv("CORS_ALLOWED_HEADERS", "Content-Type,Authorization,traceparent,tracestate,baggage,X-User-Id,X-Session-Id,X-Tenant-Id,X-Debug-Trace,X-API-Key")),
+		MaxAge:         getEnvInt("CORS_MAX_AGE", 600),
+	}
+}
+
+func (c corsConfig) originAllowed(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c corsConfig) methodAllowed(method string) bool {
+	for _, m := range c.AllowedMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c corsConfig) headersAllowed(requested string) bool {
+	for _, h := range splitList(requested) {
+		allowed := false
+		for _, a := range c.AllowedHeaders {
+			if strings.EqualFold(a, h) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// corsMiddleware applies the CORS policy. It wraps the router rather than being
+// registered with r.Use because preflights (OPTIONS) never match the GET/POST routes.
+func corsMiddleware(cfg corsConfig, next http.Handler) http.Handler {
+	if len(cfg.AllowedOrigins) == 0 {
+		return next
+	}
+	allowMethods := strings.Join(cfg.AllowedMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+	maxAge := strconv.Itoa(cfg.MaxAge)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		kind := "actual"
+		if preflight {
+			kind = "preflight"
+		}
+
+		reason := ""
+		switch {
+		case !cfg.originAllowed(origin):
+			reason = "origin"
+		case preflight && !cfg.methodAllowed(r.Header.Get("Access-Control-Request-Method")):
+			reason = "method"
+		case preflight && !cfg.headersAllowed(r.Header.Get("Access-Control-Request-Headers")):
+			reason = "headers"
+		}
+
+		if reason != "" {
+			corsRejectedTotal.WithLabelValues(kind, reason).Inc()
+			logWarn("CORS request rejected", map[string]interface{}{
+				"origin":            origin,
+				"kind":              kind,
+				"reason":            reason,
+				"method":            r.Method,
+				"path":              r.URL.Path,
+				"requested_method":  r.Header.Get("Access-Control-Request-Method"),
+				"requested_headers": r.Header.Get("Access-Control-Request-Headers"),
+			})
+			if preflight {
+				writeError(w, r, newAppError(http.StatusForbidden, "cors_rejected", "CORS preflight rejected").withDetails(map[string]interface{}{"reason": reason}))
+				return
+			}
+			// Serve without CORS headers; the browser withholds the response from the page
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Expose-Headers", "X-Trace-Id, traceresponse, Server-Timing")
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", allowMethods)
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+			h.Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
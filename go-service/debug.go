@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go-service/obs"
+)
+
+// maskedValue replaces secrets in /debug/config output
+const maskedValue = "****"
+
+// httpDurationBuckets are the http_request_duration_seconds buckets used by the router
+var httpDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DebugConfig is the effective runtime configuration reported by /debug/config
+type DebugConfig struct {
+	Sampler            string               `json:"sampler"`
+	Propagators        []string             `json:"propagators"`
+	TracesExporter     string               `json:"traces_exporter"`
+	MetricsExporter    string               `json:"metrics_exporter"`
+	MetricViews        []obs.MetricViewSpec `json:"metric_views"`
+	MetricsTemporality string               `json:"metrics_temporality"`
+	OTLP               DebugOTLPConfig      `json:"otlp"`
+	Pipeline           obs.PipelineSettings `json:"pipeline"`
+	Logging            DebugLoggingConfig   `json:"logging"`
+	HTTP               DebugHTTPConfig      `json:"http"`
+	Endpoints          DebugEndpoints       `json:"endpoints"`
+	Breaker            DebugBreakerConfig   `json:"breaker"`
+}
+
+type DebugOTLPConfig struct {
+	Protocol    string            `json:"protocol,omitempty"`
+	Endpoint    string            `json:"endpoint,omitempty"`
+	Endpoints   []string          `json:"endpoints,omitempty"` // every collector, when fanning out
+	Insecure    bool              `json:"insecure"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Compression string            `json:"compression,omitempty"`
+	TimeoutMs   int64             `json:"timeout_ms,omitempty"`
+}
+
+type DebugLoggingConfig struct {
+	Level      string   `json:"level"`
+	Outputs    []string `json:"outputs"`
+	Format     string   `json:"format"`
+	Async      bool     `json:"async"`
+	BufferSize int      `json:"buffer_size"`
+	LokiURL    string   `json:"loki_url,omitempty"`
+}
+
+type DebugHTTPConfig struct {
+	Addr                  string          `json:"addr"`
+	TLS                   bool            `json:"tls"`
+	RedirectAddr          string          `json:"redirect_addr,omitempty"`
+	DurationBuckets       []float64       `json:"duration_buckets"`
+	CompressionEnabled    bool            `json:"compression_enabled"`
+	CompressionLevel      int             `json:"compression_level"`
+	CORSAllowedOrigins    []string        `json:"cors_allowed_origins"`
+	RateLimit             rateLimitConfig `json:"rate_limit"`
+	AdminEndpointsEnabled bool            `json:"admin_endpoints_enabled"`
+}
+
+type DebugEndpoints struct {
+	NodeService   string `json:"node_service"`
+	ElixirService string `json:"elixir_service"`
+	Database      string `json:"database,omitempty"`
+	Redis         string `json:"redis,omitempty"`
+	RedisPassword string `json:"redis_password,omitempty"`
+	KafkaBrokers  string `json:"kafka_brokers,omitempty"`
+	KafkaTopic    string `json:"kafka_topic"`
+}
+
+type DebugBreakerConfig struct {
+	FailureThreshold int    `json:"failure_threshold"`
+	OpenTimeout      string `json:"open_timeout"`
+	HalfOpenMax      int    `json:"half_open_requests"`
+}
+
+// maskSecret hides any non-empty secret while still showing that it is set
+func maskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return maskedValue
+}
+
+// maskURL hides the password and sensitive query parameters of a connection URL
+func maskURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		// key=value DSNs and unparsable values may embed credentials anywhere
+		return maskedValue
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), maskedValue)
+	}
+	if q := u.Query(); len(q) > 0 {
+		for key := range q {
+			if obs.IsSensitive(key) {
+				q.Set(key, maskedValue)
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+	return strings.Replace(u.String(), url.QueryEscape(maskedValue), maskedValue, -1)
+}
+
+func debugConfigHandler(w http.ResponseWriter, r *http.Request) {
+	s := obs.CurrentSettings()
+	serverCfg := loadServerConfig()
+	breakerCfg := loadBreakerConfig()
+
+	headers := map[string]string{}
+	for _, name := range s.OTLPHeaders {
+		headers[name] = maskedValue
+	}
+
+	writeJSON(w, http.StatusOK, DebugConfig{
+		Sampler:            s.Sampler,
+		Propagators:        s.Propagators,
+		TracesExporter:     s.TracesExporter,
+		MetricsExporter:    s.MetricsExporter,
+		MetricViews:        s.MetricViews,
+		MetricsTemporality: s.MetricsTemporality,
+		Pipeline:           s.Pipeline,
+		OTLP: DebugOTLPConfig{
+			Protocol:    s.OTLPProtocol,
+			Endpoint:    s.CollectorEndpoint,
+			Endpoints:   s.CollectorEndpoints,
+			Insecure:    s.OTLPInsecure,
+			Headers:     headers,
+			Compression: s.OTLPCompression,
+			TimeoutMs:   s.OTLPTimeout.Milliseconds(),
+		},
+		Logging: DebugLoggingConfig{
+			Level:      obs.Level(),
+			Outputs:    s.LogOutputs,
+			Format:     s.LogFormat,
+			Async:      s.LogAsync,
+			BufferSize: s.LogBufferSize,
+			LokiURL:    maskURL(s.LokiURL),
+		},
+		HTTP: DebugHTTPConfig{
+			Addr:                  serverCfg.Addr,
+			TLS:                   serverCfg.TLSEnabled(),
+			RedirectAddr:          serverCfg.RedirectAddr,
+			DurationBuckets:       httpDurationBuckets,
+			CompressionEnabled:    compressionEnabled,
+			CompressionLevel:      compressionLevel,
+			CORSAllowedOrigins:    loadCORSConfig().AllowedOrigins,
+			RateLimit:             loadRateLimitConfig(),
+			AdminEndpointsEnabled: adminToken != "",
+		},
+		Endpoints: DebugEndpoints{
+			NodeService:   maskURL(nodeTarget.BaseURL),
+			ElixirService: maskURL(elixirTarget.BaseURL),
+			Database:      maskURL(getEnv("DATABASE_URL", "")),
+			Redis:         getEnv("REDIS_ADDR", ""),
+			RedisPassword: maskSecret(getEnv("REDIS_PASSWORD", "")),
+			KafkaBrokers:  getEnv("KAFKA_BROKERS", ""),
+			KafkaTopic:    eventsTopic,
+		},
+		Breaker: DebugBreakerConfig{
+			FailureThreshold: breakerCfg.FailureThreshold,
+			OpenTimeout:      breakerCfg.OpenTimeout.Round(time.Millisecond).String(),
+			HalfOpenMax:      breakerCfg.HalfOpenMax,
+		},
+	})
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+var dependencyProbeTimeout = getEnvDuration("DEPENDENCY_PROBE_TIMEOUT", 2*time.Second)
+
+type DependencyStatus struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type DependenciesResponse struct {
+	Status       string             `json:"status"`
+	Dependencies []DependencyStatus `json:"dependencies"`
+}
+
+type dependencyProbe struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// httpProbe treats any non-5xx response from the target's url as healthy
+func httpProbe(t *outboundTarget, url string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := t.client.Do(req)
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// tcpProbe only checks that addr accepts connections
+func tcpProbe(addr string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// dependencyProbes lists the downstream services plus any optional backends that are configured
+func dependencyProbes() []dependencyProbe {
+	probes := []dependencyProbe{
+		{name: nodeTarget.Name, check: httpProbe(nodeTarget, nodeTarget.BaseURL+"/health")},
+		{name: elixirTarget.Name, check: httpProbe(elixirTarget, elixirTarget.BaseURL+"/health")},
+	}
+	for i, endpoint := range obs.CurrentSettings().CollectorEndpoints {
+		name := "otel-collector"
+		if i > 0 {
+			name = fmt.Sprintf("otel-collector-%d", i+1)
+		}
+		probes = append(probes, dependencyProbe{name: name, check: tcpProbe(endpoint)})
+	}
+	if usersDB != nil {
+		probes = append(probes, dependencyProbe{name: "postgres", check: usersDB.PingContext})
+	}
+	if cacheClient != nil {
+		probes = append(probes, dependencyProbe{name: "redis", check: func(ctx context.Context) error {
+			return cacheClient.Ping(ctx).Err()
+		}})
+	}
+	return probes
+}
+
+// runProbe executes one probe in its own child span, bounded by dependencyProbeTimeout
+func runProbe(ctx context.Context, p dependencyProbe) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, dependencyProbeTimeout)
+	defer cancel()
+	ctx, span := otel.Tracer(serviceName).Start(ctx, "probe "+p.name,
+		trace.WithAttributes(attribute.String("dependency.name", p.name)),
+	)
+	defer span.End()
+
+	start := time.Now()
+	err := p.check(ctx)
+	result := DependencyStatus{
+		Name:      p.name,
+		Status:    "up",
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.SetAttributes(attribute.String("dependency.status", result.Status))
+	return result
+}
+
+// dependenciesHealthHandler probes every dependency concurrently; any failure yields 503
+func dependenciesHealthHandler(w http.ResponseWriter, r *http.Request) {
+	probes := dependencyProbes()
+	results := make([]DependencyStatus, len(probes))
+
+	var wg sync.WaitGroup
+	for i, p := range probes {
+		wg.Add(1)
+		go func(i int, p dependencyProbe) {
+			defer wg.Done()
+			results[i] = runProbe(r.Context(), p)
+		}(i, p)
+	}
+	wg.Wait()
+
+	resp := DependenciesResponse{Status: "healthy", Dependencies: results}
+	status := http.StatusOK
+	for _, d := range results {
+		if d.Status != "up" {
+			resp.Status = "degraded"
+			status = http.StatusServiceUnavailable
+			logWarnContext(r.Context(), "Dependency unhealthy", map[string]interface{}{
+				"dependency": d.Name,
+				"error":      d.Error,
+			})
+		}
+	}
+	writeJSON(w, status, resp)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-service/middleware"
+)
+
+// draining is set on SIGTERM so /readyz fails before the listener closes
+var draining atomic.Bool
+
+// background tracks the goroutines started on the background context (jobs, heartbeat,
+// watchdog, Kafka consumer), so shutdown can wait for a run in progress to log and end its
+// span before telemetry is flushed
+var background sync.WaitGroup
+
+// goBackground runs fn in a goroutine tracked by background
+func goBackground(fn func()) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		fn()
+	}()
+}
+
+// stopBackground cancels the background context and waits up to timeout for its goroutines
+func stopBackground(cancel context.CancelFunc, timeout time.Duration) {
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		logWarn("Background tasks still running at shutdown", map[string]interface{}{
+			"timeout_seconds": timeout.Seconds(),
+		})
+	}
+}
+
+// drainConfig controls graceful shutdown
+type drainConfig struct {
+	Delay   time.Duration // time /readyz reports 503 before the listener closes
+	Timeout time.Duration // upper bound on waiting for in-flight requests afterwards
+}
+
+func loadDrainConfig() drainConfig {
+	return drainConfig{
+		Delay:   getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+		Timeout: getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+	}
+}
+
+type ReadinessResponse struct {
+	Status string `json:"status"`
+}
+
+// readyzHandler reports whether the instance should receive traffic; /health stays 200 while draining
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if draining.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, ReadinessResponse{Status: "draining"})
+		return
+	}
+	writeJSON(w, http.StatusOK, ReadinessResponse{Status: "ready"})
+}
+
+// drain fails readiness, keeps serving for cfg.Delay so load balancers stop routing here,
+// then shuts srv down and waits up to cfg.Timeout for in-flight requests
+func drain(srv *http.Server, cfg drainConfig) {
+	draining.Store(true)
+	logInfo("Draining HTTP server", map[string]interface{}{
+		"drain_delay_seconds": cfg.Delay.Seconds(),
+		"timeout_seconds":     cfg.Timeout.Seconds(),
+		"in_flight":           middleware.InFlightRequests(),
+	})
+	time.Sleep(cfg.Delay)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		// Streams and other long requests still running; cut them off
+		logWarn("HTTP server drain timed out, closing remaining connections", map[string]interface{}{
+			"in_flight": middleware.InFlightRequests(),
+			"error":     err.Error(),
+		})
+		srv.Close()
+		return
+	}
+	logInfo("HTTP server drained", map[string]interface{}{
+		"wait_seconds": time.Since(start).Seconds(),
+	})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"time"
+
+	"go-service/internal/env"
+)
+
+// getEnv returns the value of key or fallback when unset or empty
+func getEnv(key, fallback string) string {
+	return env.String(key, fallback)
+}
+
+// getEnvInt returns key parsed as an int, or fallback when unset or invalid
+func getEnvInt(key string, fallback int) int {
+	return env.Int(key, fallback)
+}
+
+// getEnvFloat returns key parsed as a float64, or fallback when unset or invalid
+func getEnvFloat(key string, fallback float64) float64 {
+	return env.Float(key, fallback)
+}
+
+// getEnvBool returns key parsed as a bool, or fallback when unset or invalid
+func getEnvBool(key string, fallback bool) bool {
+	return env.Bool(key, fallback)
+}
+
+// getEnvDuration returns key parsed as a time.Duration, or fallback when unset or invalid
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	return env.Duration(key, fallback)
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(raw string) []string {
+	return env.Split(raw)
+}
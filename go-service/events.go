@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// maxEventBytes bounds POST /events payloads
+const maxEventBytes = 256 << 10
+
+var (
+	// eventsWriter is nil when KAFKA_BROKERS is unset, in which case /events returns 503
+	eventsWriter *kafka.Writer
+	eventsTopic  = getEnv("KAFKA_TOPIC", "demo-events")
+	eventsGroup  = getEnv("KAFKA_GROUP_ID", serviceName)
+
+	kafkaMessagesProduced = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_messages_produced_total",
+			Help: "Total number of Kafka messages produced",
+		},
+		[]string{"topic", "status"},
+	)
+
+	kafkaMessagesConsumed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kafka_messages_consumed_total",
+			Help: "Total number of Kafka messages consumed",
+		},
+		[]string{"topic", "status"},
+	)
+
+	kafkaProcessingDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "kafka_message_processing_duration_seconds",
+			Help:    "Kafka message processing duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"topic"},
+	)
+
+	kafkaConsumerLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kafka_consumer_lag",
+			Help: "Messages between the last consumed offset and the partition high water mark",
+		},
+		[]string{"topic", "partition"},
+	)
+)
+
+func init() {
+	obs.Registry.MustRegister(kafkaMessagesProduced)
+	obs.Registry.MustRegister(kafkaMessagesConsumed)
+	obs.Registry.MustRegister(kafkaProcessingDuration)
+	obs.Registry.MustRegister(kafkaConsumerLag)
+}
+
+// kafkaHeaderCarrier adapts Kafka message headers to the OTel TextMapCarrier interface
+type kafkaHeaderCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c kafkaHeaderCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c kafkaHeaderCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c kafkaHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+var _ propagation.TextMapCarrier = kafkaHeaderCarrier{}
+
+type Event struct {
+	Key     string          `json:"key,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type EventAccepted struct {
+	Topic   string `json:"topic"`
+	Key     string `json:"key,omitempty"`
+	TraceID string `json:"trace_id"`
+}
+
+// initEvents creates the producer and starts the background consumer when KAFKA_BROKERS is set
+func initEvents(ctx context.Context) {
+	brokers := getEnv("KAFKA_BROKERS", "")
+	if brokers == "" {
+		logInfo("KAFKA_BROKERS not set, /events endpoint disabled", nil)
+		return
+	}
+	brokerList := strings.Split(brokers, ",")
+
+	eventsWriter = &kafka.Writer{
+		Addr:                   kafka.TCP(brokerList...),
+		Topic:                  eventsTopic,
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireOne,
+		AllowAutoTopicCreation: true,
+		BatchTimeout:           10 * time.Millisecond,
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: brokerList,
+		GroupID: eventsGroup,
+		Topic:   eventsTopic,
+		MaxWait: time.Second,
+	})
+	goBackground(func() { consumeEvents(ctx, reader) })
+
+	logInfo("Kafka events initialized", map[string]interface{}{
+		"brokers": brokerList,
+		"topic":   eventsTopic,
+	})
+}
+
+// closeEvents flushes and closes the producer; the consumer stops with its context
+func closeEvents() {
+	if eventsWriter == nil {
+		return
+	}
+	if err := eventsWriter.Close(); err != nil {
+		logWarn("Failed to close Kafka writer", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// eventsHandler publishes the request body to Kafka with the current trace context in the headers
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	if eventsWriter == nil {
+		writeError(w, r, unavailable("kafka_not_configured", "kafka not configured"))
+		return
+	}
+
+	var event Event
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxEventBytes)).Decode(&event); err != nil || event.Type == "" {
+		writeError(w, r, badRequest("body must be JSON with a non-empty \"type\""))
+		return
+	}
+	value, _ := json.Marshal(event)
+
+	ctx, span := otel.Tracer(serviceName).Start(r.Context(), eventsTopic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(eventsTopic),
+			semconv.MessagingKafkaMessageKey(event.Key),
+			attribute.String("event.type", event.Type),
+		),
+	)
+	defer span.End()
+
+	msg := kafka.Message{Key: []byte(event.Key), Value: value}
+	otel.GetTextMapPropagator().Inject(ctx, kafkaHeaderCarrier{headers: &msg.Headers})
+
+	if err := eventsWriter.WriteMessages(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		kafkaMessagesProduced.WithLabelValues(eventsTopic, "error").Inc()
+		logErrorContext(ctx, "Failed to publish event", map[string]interface{}{
+			"topic":      eventsTopic,
+			"event_type": event.Type,
+			"error":      err.Error(),
+		})
+		writeError(w, r, newAppError(http.StatusBadGateway, "publish_failed", "failed to publish event").withCause(err).retryable())
+		return
+	}
+	kafkaMessagesProduced.WithLabelValues(eventsTopic, "success").Inc()
+
+	writeJSON(w, http.StatusAccepted, EventAccepted{
+		Topic:   eventsTopic,
+		Key:     event.Key,
+		TraceID: span.SpanContext().TraceID().String(),
+	})
+}
+
+// consumeEvents reads messages until ctx is cancelled, continuing the producer's trace for
+// each. A message being processed when ctx is cancelled is finished and committed.
+func consumeEvents(ctx context.Context, reader *kafka.Reader) {
+	defer reader.Close()
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			logError("Failed to fetch Kafka message", map[string]interface{}{
+				"topic": eventsTopic,
+				"error": err.Error(),
+			})
+			time.Sleep(time.Second)
+			continue
+		}
+
+		msgCtx := context.WithoutCancel(ctx)
+		processEvent(msgCtx, msg)
+
+		if err := reader.CommitMessages(msgCtx, msg); err != nil {
+			logWarn("Failed to commit Kafka offset", map[string]interface{}{
+				"topic":     msg.Topic,
+				"partition": msg.Partition,
+				"offset":    msg.Offset,
+				"error":     err.Error(),
+			})
+		}
+	}
+}
+
+// processEvent handles a single message inside a consumer span parented to the producer span
+func processEvent(ctx context.Context, msg kafka.Message) {
+	start := time.Now()
+	parent := otel.GetTextMapPropagator().Extract(ctx, kafkaHeaderCarrier{headers: &msg.Headers})
+
+	spanCtx, span := otel.Tracer(serviceName).Start(parent, msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKafka,
+			semconv.MessagingOperationDeliver,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingKafkaDestinationPartition(msg.Partition),
+			semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+			semconv.MessagingKafkaConsumerGroup(eventsGroup),
+		),
+	)
+	defer span.End()
+
+	status := "success"
+	var event Event
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		status = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "invalid event payload")
+		logWarnContext(spanCtx, "Discarding malformed event", map[string]interface{}{
+			"topic":     msg.Topic,
+			"partition": msg.Partition,
+			"offset":    msg.Offset,
+			"error":     err.Error(),
+		})
+	} else {
+		span.SetAttributes(attribute.String("event.type", event.Type))
+		logInfoContext(spanCtx, "Event processed", map[string]interface{}{
+			"topic":      msg.Topic,
+			"partition":  msg.Partition,
+			"offset":     msg.Offset,
+			"event_type": event.Type,
+		})
+	}
+
+	kafkaMessagesConsumed.WithLabelValues(msg.Topic, status).Inc()
+	kafkaProcessingDuration.WithLabelValues(msg.Topic).Observe(time.Since(start).Seconds())
+	if msg.HighWaterMark > 0 {
+		lag := msg.HighWaterMark - msg.Offset - 1
+		kafkaConsumerLag.WithLabelValues(msg.Topic, strconv.Itoa(msg.Partition)).Set(float64(lag))
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// maxFanoutWorkers caps ?n= so one request can't start an unbounded number of goroutines
+const maxFanoutWorkers = 50
+
+var fanoutWorkerDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "fanout_worker_duration_seconds",
+		Help:    "Duration of /fanout worker tasks in seconds",
+		Buckets: prometheus.DefBuckets,
+	},
+	[]string{"status"},
+)
+
+func init() {
+	obs.Registry.MustRegister(fanoutWorkerDuration)
+}
+
+type FanoutWorker struct {
+	Index      int     `json:"index"`
+	TraceID    string  `json:"trace_id"`
+	DurationMs float64 `json:"duration_ms"`
+	Cancelled  bool    `json:"cancelled,omitempty"`
+}
+
+type FanoutResponse struct {
+	TraceID    string         `json:"trace_id"`
+	Workers    []FanoutWorker `json:"workers"`
+	DurationMs float64        `json:"duration_ms"`
+}
+
+// fanoutHandler runs ?n=5 workers in parallel (in sequence with fanout-strategy=sequential),
+// each in its own root span linked to the request span, so the trace view shows links
+// rather than one deep tree
+func fanoutHandler(w http.ResponseWriter, r *http.Request) {
+	n := 5
+	if raw := r.URL.Query().Get("n"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > maxFanoutWorkers {
+			writeError(w, r, badRequest("n must be between 1 and "+strconv.Itoa(maxFanoutWorkers)))
+			return
+		}
+		n = v
+	}
+
+	ctx := r.Context()
+	requestSpan := trace.SpanFromContext(ctx)
+	link := trace.LinkFromContext(ctx, attribute.String("fanout.link", "request"))
+	tracer := otel.Tracer(serviceName)
+
+	// The fanout-strategy flag switches to running the workers one after another
+	strategy := stringFlag(r, flagFanoutStrategy, "parallel")
+
+	start := time.Now()
+	workers := make([]FanoutWorker, n)
+	runWorker := func(i int) {
+		workerCtx, span := tracer.Start(ctx, "fanout worker",
+			trace.WithNewRoot(),
+			trace.WithLinks(link),
+			trace.WithSpanKind(trace.SpanKindInternal),
+			trace.WithAttributes(
+				attribute.Int("fanout.worker.index", i),
+				attribute.Int("fanout.workers", n),
+			),
+		)
+		defer span.End()
+
+		workerStart := time.Now()
+		delay := time.Duration(sampleLatency(20, 500) * float64(time.Millisecond))
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		result := FanoutWorker{Index: i, TraceID: span.SpanContext().TraceID().String()}
+		status := "success"
+		select {
+		case <-timer.C:
+		case <-workerCtx.Done():
+			status = "cancelled"
+			result.Cancelled = true
+			span.SetStatus(codes.Error, workerCtx.Err().Error())
+		}
+		elapsed := time.Since(workerStart)
+		result.DurationMs = float64(elapsed.Microseconds()) / 1000
+		fanoutWorkerDuration.WithLabelValues(status).Observe(elapsed.Seconds())
+		workers[i] = result
+	}
+	if strategy == "sequential" {
+		for i := 0; i < n; i++ {
+			runWorker(i)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				runWorker(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	// The request span can't link forward to spans started after it, so record each worker as an event
+	for _, wk := range workers {
+		requestSpan.AddEvent("fanout.worker.completed", trace.WithAttributes(
+			attribute.Int("fanout.worker.index", wk.Index),
+			attribute.String("fanout.worker.trace_id", wk.TraceID),
+			attribute.Float64("fanout.worker.duration_ms", wk.DurationMs),
+		))
+	}
+	requestSpan.SetAttributes(
+		attribute.Int("fanout.workers", n),
+		attribute.String("fanout.strategy", strategy),
+	)
+
+	writeJSON(w, http.StatusOK, FanoutResponse{
+		TraceID:    requestSpan.SpanContext().TraceID().String(),
+		Workers:    workers,
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+	})
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/middleware"
+	"go-service/obs"
+)
+
+// Flags gating demo behavior
+const (
+	flagRootErrorInjection = "root-error-injection" // bool: GET / returns 500
+	flagFanoutStrategy     = "fanout-strategy"      // string: "parallel" (default) or "sequential"
+)
+
+const flagProviderName = "env"
+
+// defaultFlags are defined even when unconfigured so the gated paths never hit FLAG_NOT_FOUND
+var defaultFlags = map[string]interface{}{
+	flagRootErrorInjection: false,
+	flagFanoutStrategy:     "parallel",
+}
+
+var featureFlagEvaluations = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "feature_flag_evaluations_total",
+		Help: "Total number of feature flag evaluations by flag, variant and reason",
+	},
+	[]string{"flag", "variant", "reason"},
+)
+
+func init() {
+	obs.Registry.MustRegister(featureFlagEvaluations)
+}
+
+// flagDefinition is one flag: a set of named variants, the variant served by default and an
+// optional percentage rollout across variants keyed on the evaluation's targeting key
+type flagDefinition struct {
+	Variants       map[string]interface{} `json:"variants"`
+	DefaultVariant string                 `json:"default_variant"`
+	Rollout        map[string]int         `json:"rollout,omitempty"`
+}
+
+// flagProvider is an OpenFeature provider backed by FEATURE_FLAGS_FILE and FEATURE_FLAGS.
+// The flag set is swapped atomically so a config reload takes effect for the next evaluation.
+type flagProvider struct {
+	flags atomic.Pointer[map[string]flagDefinition]
+}
+
+var (
+	featureFlags     = &flagProvider{}
+	flagClient       = openfeature.NewClient(serviceName)
+	installFlagsOnce sync.Once
+)
+
+// initFeatureFlags loads the flags and, the first time, installs the provider and telemetry
+// hook globally
+func initFeatureFlags() {
+	if err := featureFlags.reload(); err != nil {
+		logError("Failed to load feature flags, serving defaults", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	installFlagsOnce.Do(func() {
+		openfeature.SetProviderAndWait(featureFlags)
+		openfeature.AddHooks(flagTelemetryHook{})
+	})
+}
+
+// reload re-reads the flags; on error the current set is kept
+func (p *flagProvider) reload() error {
+	flags, err := loadFlags()
+	if err != nil {
+		return err
+	}
+	p.flags.Store(&flags)
+	keys := make([]string, 0, len(flags))
+	for key := range flags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	logInfo("Feature flags loaded", map[string]interface{}{
+		"flags": keys,
+	})
+	return nil
+}
+
+// loadFlags reads FEATURE_FLAGS_FILE, a JSON object of flag -> value or flag -> definition,
+// then applies FEATURE_FLAGS ("root-error-injection=true,fanout-strategy=sequential") on top
+func loadFlags() (map[string]flagDefinition, error) {
+	flags := map[string]flagDefinition{}
+	for key, value := range defaultFlags {
+		flags[key] = staticFlag(value)
+	}
+	if path := getEnv("FEATURE_FLAGS_FILE", ""); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var file map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &file); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for key, value := range file {
+			def, err := parseFlagDefinition(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: flag %q: %w", path, key, err)
+			}
+			flags[key] = def
+		}
+	}
+
+	for _, entry := range splitList(getEnv("FEATURE_FLAGS", "")) {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("FEATURE_FLAGS entry %q must be flag=value", entry)
+		}
+		flags[strings.TrimSpace(key)] = staticFlag(parseFlagValue(strings.TrimSpace(value)))
+	}
+	return flags, nil
+}
+
+func parseFlagDefinition(raw json.RawMessage) (flagDefinition, error) {
+	var def flagDefinition
+	if json.Unmarshal(raw, &def) == nil && len(def.Variants) > 0 {
+		if _, ok := def.Variants[def.DefaultVariant]; !ok {
+			return def, fmt.Errorf("default_variant %q is not one of the variants", def.DefaultVariant)
+		}
+		total := 0
+		for variant, weight := range def.Rollout {
+			if _, ok := def.Variants[variant]; !ok {
+				return def, fmt.Errorf("rollout variant %q is not one of the variants", variant)
+			}
+			total += weight
+		}
+		if len(def.Rollout) > 0 && total != 100 {
+			return def, fmt.Errorf("rollout weights add up to %d, want 100", total)
+		}
+		return def, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return def, err
+	}
+	return staticFlag(value), nil
+}
+
+// staticFlag is a single-variant flag; the variant is named after the value
+func staticFlag(value interface{}) flagDefinition {
+	variant := fmt.Sprint(value)
+	if b, ok := value.(bool); ok {
+		variant = map[bool]string{true: "on", false: "off"}[b]
+	}
+	return flagDefinition{Variants: map[string]interface{}{variant: value}, DefaultVariant: variant}
+}
+
+// parseFlagValue types a FEATURE_FLAGS value the way JSON would
+func parseFlagValue(raw string) interface{} {
+	if b, err := strconv.ParseBool(raw); err == nil {
+		return b
+	}
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f
+	}
+	return raw
+}
+
+func (p *flagProvider) Metadata() openfeature.Metadata {
+	return openfeature.Metadata{Name: flagProviderName}
+}
+
+func (p *flagProvider) Hooks() []openfeature.Hook {
+	return nil
+}
+
+// resolve picks the variant for flag: a rollout bucket when the flag has one and the
+// evaluation carries a targeting key, otherwise the default variant
+func (p *flagProvider) resolve(flag string, evalCtx openfeature.FlattenedContext) (interface{}, openfeature.ProviderResolutionDetail) {
+	var flags map[string]flagDefinition
+	if loaded := p.flags.Load(); loaded != nil {
+		flags = *loaded
+	}
+	def, ok := flags[flag]
+	if !ok {
+		return nil, openfeature.ProviderResolutionDetail{
+			ResolutionError: openfeature.NewFlagNotFoundResolutionError("flag " + flag + " is not defined"),
+			Reason:          openfeature.ErrorReason,
+		}
+	}
+
+	targetingKey, _ := evalCtx[openfeature.TargetingKey].(string)
+	if len(def.Rollout) == 0 || targetingKey == "" {
+		reason := openfeature.StaticReason
+		if len(def.Variants) > 1 {
+			reason = openfeature.DefaultReason
+		}
+		return def.Variants[def.DefaultVariant], openfeature.ProviderResolutionDetail{Reason: reason, Variant: def.DefaultVariant}
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(flag + ":" + targetingKey))
+	bucket := int(h.Sum32() % 100)
+	variants := make([]string, 0, len(def.Rollout))
+	for variant := range def.Rollout {
+		variants = append(variants, variant)
+	}
+	sort.Strings(variants)
+	for _, variant := range variants {
+		bucket -= def.Rollout[variant]
+		if bucket < 0 {
+			return def.Variants[variant], openfeature.ProviderResolutionDetail{Reason: openfeature.SplitReason, Variant: variant}
+		}
+	}
+	return def.Variants[def.DefaultVariant], openfeature.ProviderResolutionDetail{Reason: openfeature.DefaultReason, Variant: def.DefaultVariant}
+}
+
+func typeMismatch(detail openfeature.ProviderResolutionDetail, value interface{}, want string) openfeature.ProviderResolutionDetail {
+	if detail.ResolutionError != (openfeature.ResolutionError{}) {
+		return detail
+	}
+	return openfeature.ProviderResolutionDetail{
+		ResolutionError: openfeature.NewTypeMismatchResolutionError(fmt.Sprintf("value %v is not a %s", value, want)),
+		Reason:          openfeature.ErrorReason,
+	}
+}
+
+func (p *flagProvider) BooleanEvaluation(ctx context.Context, flag string, defaultValue bool, evalCtx openfeature.FlattenedContext) openfeature.BoolResolutionDetail {
+	value, detail := p.resolve(flag, evalCtx)
+	if v, ok := value.(bool); ok {
+		return openfeature.BoolResolutionDetail{Value: v, ProviderResolutionDetail: detail}
+	}
+	return openfeature.BoolResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(detail, value, "bool")}
+}
+
+func (p *flagProvider) StringEvaluation(ctx context.Context, flag string, defaultValue string, evalCtx openfeature.FlattenedContext) openfeature.StringResolutionDetail {
+	value, detail := p.resolve(flag, evalCtx)
+	if v, ok := value.(string); ok {
+		return openfeature.StringResolutionDetail{Value: v, ProviderResolutionDetail: detail}
+	}
+	return openfeature.StringResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(detail, value, "string")}
+}
+
+func (p *flagProvider) FloatEvaluation(ctx context.Context, flag string, defaultValue float64, evalCtx openfeature.FlattenedContext) openfeature.FloatResolutionDetail {
+	value, detail := p.resolve(flag, evalCtx)
+	if v, ok := value.(float64); ok {
+		return openfeature.FloatResolutionDetail{Value: v, ProviderResolutionDetail: detail}
+	}
+	return openfeature.FloatResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(detail, value, "number")}
+}
+
+func (p *flagProvider) IntEvaluation(ctx context.Context, flag string, defaultValue int64, evalCtx openfeature.FlattenedContext) openfeature.IntResolutionDetail {
+	value, detail := p.resolve(flag, evalCtx)
+	// JSON and FEATURE_FLAGS numbers are float64; only whole numbers are valid integers
+	if v, ok := value.(float64); ok && v == math.Trunc(v) {
+		return openfeature.IntResolutionDetail{Value: int64(v), ProviderResolutionDetail: detail}
+	}
+	return openfeature.IntResolutionDetail{Value: defaultValue, ProviderResolutionDetail: typeMismatch(detail, value, "integer")}
+}
+
+func (p *flagProvider) ObjectEvaluation(ctx context.Context, flag string, defaultValue interface{}, evalCtx openfeature.FlattenedContext) openfeature.InterfaceResolutionDetail {
+	value, detail := p.resolve(flag, evalCtx)
+	if detail.ResolutionError != (openfeature.ResolutionError{}) {
+		value = defaultValue
+	}
+	return openfeature.InterfaceResolutionDetail{Value: value, ProviderResolutionDetail: detail}
+}
+
+// flagTelemetryHook records every evaluation on the active span and in
+// feature_flag_evaluations_total, so a trace shows which variant served the request
+type flagTelemetryHook struct {
+	openfeature.UnimplementedHook
+}
+
+func (flagTelemetryHook) After(ctx context.Context, hc openfeature.HookContext, details openfeature.InterfaceEvaluationDetails, _ openfeature.HookHints) error {
+	recordFlagEvaluation(ctx, hc, details.Variant, string(details.Reason), "")
+	return nil
+}
+
+func (flagTelemetryHook) Error(ctx context.Context, hc openfeature.HookContext, err error, _ openfeature.HookHints) {
+	recordFlagEvaluation(ctx, hc, "", string(openfeature.ErrorReason), err.Error())
+	logWarnContext(ctx, "Feature flag evaluation failed, using default", map[string]interface{}{
+		"flag":    hc.FlagKey(),
+		"default": hc.DefaultValue(),
+		"error":   err.Error(),
+	})
+}
+
+func recordFlagEvaluation(ctx context.Context, hc openfeature.HookContext, variant, reason, errMsg string) {
+	if variant == "" {
+		variant = "default"
+	}
+	featureFlagEvaluations.WithLabelValues(hc.FlagKey(), variant, strings.ToLower(reason)).Inc()
+
+	attrs := []attribute.KeyValue{
+		semconv.FeatureFlagKey(hc.FlagKey()),
+		semconv.FeatureFlagProviderName(hc.ProviderMetadata().Name),
+		semconv.FeatureFlagVariant(variant),
+		attribute.String("feature_flag.reason", strings.ToLower(reason)),
+	}
+	if errMsg != "" {
+		attrs = append(attrs, attribute.String("feature_flag.error", errMsg))
+	}
+	span := trace.SpanFromContext(ctx)
+	span.AddEvent("feature_flag", trace.WithAttributes(attrs...))
+	// One attribute per flag keeps the variant searchable on the span itself
+	span.SetAttributes(attribute.String("feature_flag."+hc.FlagKey(), variant))
+}
+
+// flagContext targets evaluations by request ID, so a rollout splits traffic per request
+func flagContext(r *http.Request) openfeature.EvaluationContext {
+	return openfeature.NewEvaluationContext(middleware.RequestIDFromContext(r.Context()), map[string]interface{}{
+		"route":  middleware.RouteTemplate(r),
+		"method": r.Method,
+	})
+}
+
+func boolFlag(r *http.Request, flag string, defaultValue bool) bool {
+	v, _ := flagClient.BooleanValue(r.Context(), flag, defaultValue, flagContext(r))
+	return v
+}
+
+func stringFlag(r *http.Request, flag string, defaultValue string) string {
+	v, _ := flagClient.StringValue(r.Context(), flag, defaultValue, flagContext(r))
+	return v
+}
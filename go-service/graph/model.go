@@ -0,0 +1,42 @@
+package graph
+
+import "strconv"
+
+// Product is bound in gqlgen.yml so reviews and inventory stay resolvers rather than fields
+type Product struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	PriceCents int    `json:"priceCents"`
+}
+
+// catalogSize is the number of products in the demo catalog
+const catalogSize = 100
+
+var productNames = []string{"Lamp", "Desk", "Chair", "Shelf", "Rug", "Mirror", "Clock", "Vase", "Stool", "Bench"}
+
+// catalog is the fixed in-memory product list behind the schema
+var catalog = func() []*Product {
+	products := make([]*Product, catalogSize)
+	for i := range products {
+		products[i] = &Product{
+			ID:         strconv.Itoa(i + 1),
+			Name:       productNames[i%len(productNames)] + " " + strconv.Itoa(i/len(productNames)+1),
+			PriceCents: 999 + i*250,
+		}
+	}
+	return products
+}()
+
+// reviewsFor derives 0-3 reviews from the product ID, so results are stable across requests
+func reviewsFor(p *Product) []*Review {
+	id, _ := strconv.Atoi(p.ID)
+	reviews := make([]*Review, id%4)
+	for i := range reviews {
+		reviews[i] = &Review{
+			ID:     p.ID + "-" + strconv.Itoa(i+1),
+			Rating: (id+i)%5 + 1,
+			Body:   "Review " + strconv.Itoa(i+1) + " of " + p.Name,
+		}
+	}
+	return reviews
+}
@@ -0,0 +1,12 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graph
+
+type Query struct {
+}
+
+type Review struct {
+	ID     string `json:"id"`
+	Rating int    `json:"rating"`
+	Body   string `json:"body"`
+}
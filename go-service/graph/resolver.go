@@ -0,0 +1,73 @@
+package graph
+
+//go:generate go run github.com/99designs/gqlgen generate
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// errFailingField is returned by Query.failing
+var errFailingField = errors.New("failing resolver always errors")
+
+// Resolver serves the schema from the in-memory catalog
+type Resolver struct{}
+
+// Product returns ProductResolver implementation.
+func (r *Resolver) Product() ProductResolver { return &productResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type productResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+
+// Reviews is the resolver for the reviews field.
+func (r *productResolver) Reviews(ctx context.Context, obj *Product) ([]*Review, error) {
+	return reviewsFor(obj), nil
+}
+
+// Inventory is the resolver for the inventory field. It stands in for a call to a stock
+// service, so each product pays 1-5ms.
+func (r *productResolver) Inventory(ctx context.Context, obj *Product) (int, error) {
+	select {
+	case <-time.After(time.Millisecond + time.Duration(rand.Int63n(int64(4*time.Millisecond)))):
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+	return len(obj.Name) * 7 % 50, nil
+}
+
+// Products is the resolver for the products field.
+func (r *queryResolver) Products(ctx context.Context, limit *int) ([]*Product, error) {
+	n := 10
+	if limit != nil {
+		n = *limit
+	}
+	if n < 1 || n > catalogSize {
+		return nil, &gqlerror.Error{
+			Message:    "limit must be between 1 and 100",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return catalog[:n], nil
+}
+
+// Product is the resolver for the product field.
+func (r *queryResolver) Product(ctx context.Context, id string) (*Product, error) {
+	for _, p := range catalog {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+// Failing is the resolver for the failing field.
+func (r *queryResolver) Failing(ctx context.Context) (*string, error) {
+	return nil, errFailingField
+}
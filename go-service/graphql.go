@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+
+	"go-service/graph"
+)
+
+// graphqlHandler serves the demo catalog schema over GET and POST. Each operation and
+// resolver gets its own span and graphql_* metrics from graph.Telemetry, under the
+// request span and http_* metrics every route has.
+func graphqlHandler() http.Handler {
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}}))
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(graph.Telemetry{})
+	return srv
+}
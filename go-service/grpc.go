@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"net"
+	"path"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"go-service/obs"
+)
+
+// maxWorkDuration caps the simulated work of DemoService/Work
+const maxWorkDuration = 30 * time.Second
+
+var (
+	// Prometheus metrics mirroring the HTTP ones for gRPC calls
+	grpcRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "Total number of gRPC requests completed on the server",
+		},
+		[]string{"grpc_service", "grpc_method", "grpc_code"},
+	)
+
+	grpcRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "gRPC request duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"grpc_service", "grpc_method"},
+	)
+)
+
+func init() {
+	obs.Registry.MustRegister(grpcRequestsTotal)
+	obs.Registry.MustRegister(grpcRequestDuration)
+}
+
+// DemoServer is the gRPC counterpart of the HTTP demo endpoints
+type DemoServer interface {
+	Echo(context.Context, *wrapperspb.StringValue) (*wrapperspb.StringValue, error)
+	Work(context.Context, *durationpb.Duration) (*durationpb.Duration, error)
+}
+
+// demoServiceDesc is written by hand using well-known types so no protoc step is needed
+var demoServiceDesc = grpc.ServiceDesc{
+	ServiceName: "demo.v1.DemoService",
+	HandlerType: (*DemoServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Echo",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(wrapperspb.StringValue)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(DemoServer).Echo(ctx, req.(*wrapperspb.StringValue))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/demo.v1.DemoService/Echo"}, handler)
+			},
+		},
+		{
+			MethodName: "Work",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(durationpb.Duration)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(DemoServer).Work(ctx, req.(*durationpb.Duration))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/demo.v1.DemoService/Work"}, handler)
+			},
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "demo/v1/demo.proto",
+}
+
+type demoServer struct{}
+
+func (demoServer) Echo(ctx context.Context, in *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("demo.echo.length", len(in.GetValue())))
+	return wrapperspb.String(in.GetValue()), nil
+}
+
+// Work sleeps for the requested duration, honoring cancellation and deadlines
+func (demoServer) Work(ctx context.Context, in *durationpb.Duration) (*durationpb.Duration, error) {
+	if err := in.CheckValid(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	d := in.AsDuration()
+	if d < 0 || d > maxWorkDuration {
+		return nil, status.Errorf(codes.InvalidArgument, "duration must be between 0s and %s", maxWorkDuration)
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return durationpb.New(time.Since(start)), nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// splitMethod turns "/pkg.Service/Method" into ("pkg.Service", "Method")
+func splitMethod(fullMethod string) (string, string) {
+	service, method := path.Split(fullMethod)
+	return path.Clean(service)[1:], method
+}
+
+// grpcObservabilityInterceptor logs each call and records the Prometheus metrics,
+// matching what loggingMiddleware does for HTTP
+func grpcObservabilityInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	service, method := splitMethod(info.FullMethod)
+
+	remoteAddr := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+
+	logInfoContext(ctx, "Incoming gRPC request", map[string]interface{}{
+		"remote_addr":  remoteAddr,
+		"grpc_service": service,
+		"grpc_method":  method,
+	})
+
+	resp, err := handler(ctx, req)
+
+	duration := time.Since(start).Seconds()
+	code := status.Code(err)
+
+	fields := map[string]interface{}{
+		"remote_addr":      remoteAddr,
+		"grpc_service":     service,
+		"grpc_method":      method,
+		"grpc_code":        code.String(),
+		"duration_seconds": duration,
+	}
+	switch code {
+	case codes.OK:
+		logInfoContext(ctx, "gRPC request completed", fields)
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable, codes.DeadlineExceeded:
+		fields["error"] = err.Error()
+		logErrorContext(ctx, "gRPC request completed", fields)
+	default:
+		fields["error"] = err.Error()
+		logWarnContext(ctx, "gRPC request completed", fields)
+	}
+
+	grpcRequestsTotal.WithLabelValues(service, method, code.String()).Inc()
+	grpcRequestDuration.WithLabelValues(service, method).Observe(duration)
+
+	return resp, err
+}
+
+func newGRPCServer() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(grpcObservabilityInterceptor),
+	)
+
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthSrv.SetServingStatus(demoServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthSrv)
+
+	srv.RegisterService(&demoServiceDesc, demoServer{})
+	return srv
+}
+
+// serveGRPC runs the gRPC server on addr in the background; it returns nil when addr
+// cannot be listened on
+func serveGRPC(addr string) *grpc.Server {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		logError("Failed to listen for gRPC", map[string]interface{}{
+			"addr":  addr,
+			"error": err.Error(),
+		})
+		return nil
+	}
+
+	logInfo("gRPC server starting", map[string]interface{}{
+		"addr": addr,
+	})
+	srv := newGRPCServer()
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			logError("gRPC server stopped", map[string]interface{}{
+				"addr":  addr,
+				"error": err.Error(),
+			})
+		}
+	}()
+	return srv
+}
+
+// stopGRPC lets in-flight calls finish for up to timeout, then closes the remaining ones
+func stopGRPC(srv *grpc.Server, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		logInfo("gRPC server drained", nil)
+	case <-time.After(timeout):
+		logWarn("gRPC server drain timed out, closing remaining calls", map[string]interface{}{
+			"timeout_seconds": timeout.Seconds(),
+		})
+		srv.Stop()
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"go-service/obs"
+)
+
+// testHarness captures spans, OTel metrics and log lines produced while serving requests
+// through the real router
+type testHarness struct {
+	t      *testing.T
+	spans  *tracetest.InMemoryExporter
+	reader *metricsdk.ManualReader
+	router http.Handler
+
+	mu   sync.Mutex
+	logs bytes.Buffer
+}
+
+// newTestHarness installs in-memory telemetry as the global providers for the test's duration
+func newTestHarness(t *testing.T) *testHarness {
+	t.Helper()
+	h := &testHarness{
+		t:      t,
+		spans:  tracetest.NewInMemoryExporter(),
+		reader: metricsdk.NewManualReader(),
+	}
+
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(h.spans))
+	mp := metricsdk.NewMeterProvider(metricsdk.WithReader(h.reader))
+	prevTP, prevMP, prevProp := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	obs.SetOutput(h)
+
+	t.Cleanup(func() {
+		obs.SetOutput(os.Stdout)
+		otel.SetTracerProvider(prevTP)
+		otel.SetMeterProvider(prevMP)
+		otel.SetTextMapPropagator(prevProp)
+		tp.Shutdown(context.Background())
+		mp.Shutdown(context.Background())
+	})
+
+	initFeatureFlags()
+	// Order instruments are bound to the meter provider current when they were created
+	initOrderService().close()
+	orders = newOrderService()
+	h.router = newRouter()
+	return h
+}
+
+// Write collects log output; the writer is shared with background goroutines
+func (h *testHarness) Write(p []byte) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.logs.Write(p)
+}
+
+func (h *testHarness) do(method, target string, header http.Header) *httptest.ResponseRecorder {
+	h.t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	for k, vs := range header {
+		req.Header[k] = vs
+	}
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// span returns the single finished span with the given name
+func (h *testHarness) span(name string) tracetest.SpanStub {
+	h.t.Helper()
+	var found []tracetest.SpanStub
+	for _, s := range h.spans.GetSpans() {
+		if s.Name == name {
+			found = append(found, s)
+		}
+	}
+	if len(found) != 1 {
+		h.t.Fatalf("want exactly one %q span, got %d", name, len(found))
+	}
+	return found[0]
+}
+
+// logEntries returns every captured log line whose message matches
+func (h *testHarness) logEntries(message string) []obs.LogEntry {
+	h.t.Helper()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var entries []obs.LogEntry
+	for _, line := range strings.Split(h.logs.String(), "\n") {
+		if line == "" {
+			continue
+		}
+		var e obs.LogEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			h.t.Fatalf("log line is not JSON: %q", line)
+		}
+		if e["message"] == message {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// collectMetrics reads the OTel meter provider through the manual reader
+func (h *testHarness) collectMetrics() metricdata.ResourceMetrics {
+	h.t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := h.reader.Collect(context.Background(), &rm); err != nil {
+		h.t.Fatalf("collect metrics: %v", err)
+	}
+	return rm
+}
+
+// promCounter returns the value of a Prometheus counter in obs.Registry with exactly these labels
+func promCounter(t *testing.T, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := obs.Registry.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if labelsEqual(m.GetLabel(), labels) {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func labelsEqual(pairs []*dto.LabelPair, want map[string]string) bool {
+	if len(pairs) != len(want) {
+		return false
+	}
+	for _, p := range pairs {
+		if want[p.GetName()] != p.GetValue() {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/middleware"
+	"go-service/obs"
+)
+
+var (
+	heartbeatTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "app_heartbeat_timestamp_seconds",
+		Help: "Unix timestamp of the last heartbeat; alert when time() minus this exceeds a few heartbeat intervals",
+	})
+
+	heartbeatInterval = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "app_heartbeat_interval_seconds",
+		Help: "Configured interval between heartbeats, for alert rules that scale with it",
+	})
+
+	heartbeatsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "app_heartbeats_total",
+		Help: "Total number of heartbeats since the process started",
+	})
+)
+
+func init() {
+	obs.Registry.MustRegister(heartbeatTimestamp, heartbeatInterval, heartbeatsTotal)
+}
+
+// processStart is when the service started, for the heartbeat's uptime
+var processStart = time.Now()
+
+// heartbeatConfig is read from HEARTBEAT_INTERVAL (0 disables) and HEARTBEAT_SPAN
+type heartbeatConfig struct {
+	Interval time.Duration
+	Span     bool
+}
+
+func loadHeartbeatConfig() heartbeatConfig {
+	return heartbeatConfig{
+		Interval: getEnvDuration("HEARTBEAT_INTERVAL", 30*time.Second),
+		Span:     getEnvBool("HEARTBEAT_SPAN", false),
+	}
+}
+
+// startHeartbeat is a dead man's switch: every interval, with or without traffic, it sets
+// app_heartbeat_timestamp_seconds, logs a "Heartbeat" line at INFO and, if configured,
+// exports a "heartbeat" root span. Any of the three going stale means the service stopped
+// reporting, which a request-rate alert cannot tell apart from a quiet period.
+func startHeartbeat(ctx context.Context, cfg heartbeatConfig) {
+	if cfg.Interval <= 0 {
+		return
+	}
+	heartbeatInterval.Set(cfg.Interval.Seconds())
+	beat(ctx, cfg)
+	goBackground(func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				beat(ctx, cfg)
+			}
+		}
+	})
+}
+
+func beat(ctx context.Context, cfg heartbeatConfig) {
+	heartbeatsTotal.Inc()
+	uptime := time.Since(processStart).Seconds()
+	goroutines := runtime.NumGoroutine()
+	fields := map[string]interface{}{
+		"uptime_seconds":   uptime,
+		"goroutines":       goroutines,
+		"in_flight":        middleware.InFlightRequests(),
+		"interval_seconds": cfg.Interval.Seconds(),
+	}
+	if cfg.Span {
+		var span trace.Span
+		ctx, span = otel.Tracer(serviceName).Start(ctx, "heartbeat",
+			trace.WithNewRoot(),
+			trace.WithSpanKind(trace.SpanKindInternal),
+			trace.WithAttributes(
+				attribute.Float64("heartbeat.uptime_seconds", uptime),
+				attribute.Int("heartbeat.goroutines", goroutines),
+			),
+		)
+		defer span.End()
+	}
+	logInfoContext(ctx, "Heartbeat", fields)
+	heartbeatTimestamp.SetToCurrentTime()
+}
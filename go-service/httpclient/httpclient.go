@@ -0,0 +1,140 @@
+// Package httpclient builds the instrumented HTTP clients used for outbound calls.
+//
+// Every client traces requests with otelhttp, injecting the configured propagators, breaks
+// each call into DNS, connect, TLS, send and receive phases with otelhttptrace, and
+// records client-side RED metrics labelled by the target service:
+//
+//	client := httpclient.New("typescript-service", httpclient.Options{Timeout: 5 * time.Second})
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// Network phase tracing modes for Options.PhaseTrace
+const (
+	PhaseSpans  = "spans"  // child spans: http.getconn, http.dns, http.connect, http.tls, http.send, http.receive
+	PhaseEvents = "events" // the same phases as events on the client span
+	PhaseOff    = "off"
+)
+
+// Options configures New; zero values use a 5s timeout, http.DefaultTransport,
+// obs.Registry and PhaseSpans
+type Options struct {
+	Timeout    time.Duration
+	Transport  http.RoundTripper
+	Registerer prometheus.Registerer
+	PhaseTrace string
+}
+
+// New returns a client for calls to target. Requests are counted in
+// http_client_requests_total and timed in http_client_request_duration_seconds (up to the
+// response headers) by target and status class; transport failures use class "error".
+func New(target string, opts Options) *http.Client {
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	if opts.Registerer == nil {
+		opts.Registerer = obs.Registry
+	}
+
+	requests := obs.RegisterOrExisting(opts.Registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_client_requests_total",
+			Help: "Total number of outbound HTTP requests",
+		},
+		[]string{"target", "status_class"},
+	))
+	duration := obs.RegisterOrExisting(opts.Registerer, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_client_request_duration_seconds",
+			Help:    "Outbound HTTP request duration in seconds, until the response headers arrive",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"target", "status_class"},
+	))
+
+	var otelOpts []otelhttp.Option
+	if opts.PhaseTrace != PhaseOff {
+		otelOpts = append(otelOpts, otelhttp.WithClientTrace(phaseTrace(opts.PhaseTrace)))
+	}
+
+	return &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &metricsTransport{
+			target:   target,
+			next:     otelhttp.NewTransport(opts.Transport, otelOpts...),
+			requests: requests,
+			duration: duration,
+		},
+	}
+}
+
+// phaseTrace returns the otelhttptrace hooks for a request, plus the time to first response
+// byte as an attribute on the client span. Headers are left to the capture allowlist.
+func phaseTrace(mode string) func(context.Context) *httptrace.ClientTrace {
+	opts := []otelhttptrace.ClientTraceOption{otelhttptrace.WithoutHeaders()}
+	if mode == PhaseEvents {
+		opts = append(opts, otelhttptrace.WithoutSubSpans())
+	}
+	return func(ctx context.Context) *httptrace.ClientTrace {
+		start := time.Now()
+		span := trace.SpanFromContext(ctx)
+		ct := otelhttptrace.NewClientTrace(ctx, opts...)
+		gotFirstByte := ct.GotFirstResponseByte
+		ct.GotFirstResponseByte = func() {
+			span.SetAttributes(attribute.Float64("http.time_to_first_byte_ms", float64(time.Since(start).Microseconds())/1000))
+			gotFirstByte()
+		}
+		return ct
+	}
+}
+
+type metricsTransport struct {
+	target   string
+	next     http.RoundTripper
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	class := StatusClass(resp, err)
+	t.requests.WithLabelValues(t.target, class).Inc()
+	t.duration.WithLabelValues(t.target, class).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// StatusClass returns "2xx" through "5xx" for resp, or "error" when no response arrived
+func StatusClass(resp *http.Response, err error) string {
+	if err != nil || resp == nil {
+		return "error"
+	}
+	switch {
+	case resp.StatusCode >= 500:
+		return "5xx"
+	case resp.StatusCode >= 400:
+		return "4xx"
+	case resp.StatusCode >= 300:
+		return "3xx"
+	case resp.StatusCode >= 200:
+		return "2xx"
+	default:
+		return "1xx"
+	}
+}
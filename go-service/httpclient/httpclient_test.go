@@ -0,0 +1,86 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestClientCountsRequestsByTargetAndStatusClass(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	client := New("demo", Options{Registerer: reg})
+	for _, path := range []string{"/", "/", "/fail"} {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	srv.Close()
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+
+	requests := New("demo", Options{Registerer: reg}).Transport.(*metricsTransport).requests
+	for class, want := range map[string]float64{"2xx": 2, "5xx": 1, "error": 1} {
+		if got := testutil.ToFloat64(requests.WithLabelValues("demo", class)); got != want {
+			t.Errorf("http_client_requests_total{status_class=%q} = %v, want %v", class, got, want)
+		}
+	}
+}
+
+func TestClientTracesNetworkPhases(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(spans))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(prev)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// A parent span makes the SDK tracer provider visible to otelhttptrace
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "parent")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := New("demo", Options{Registerer: prometheus.NewRegistry()}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	parent.End()
+
+	names := map[string]bool{}
+	var ttfb bool
+	for _, s := range spans.GetSpans() {
+		names[s.Name] = true
+		for _, kv := range s.Attributes {
+			if kv.Key == "http.time_to_first_byte_ms" {
+				ttfb = true
+			}
+		}
+	}
+	for _, want := range []string{"http.getconn", "http.connect", "http.send", "http.receive"} {
+		if !names[want] {
+			t.Errorf("missing %s span; got %v", want, names)
+		}
+	}
+	if !ttfb {
+		t.Error("no span carries http.time_to_first_byte_ms")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/gorilla/mux"
+
+	"go-service/middleware"
+	"go-service/obs"
+)
+
+// internalConfig moves the operational endpoints (/metrics, pprof, admin routes) off the
+// public listener and/or behind HTTP basic auth
+type internalConfig struct {
+	Addr     string // dedicated listener, e.g. ":9464"; empty keeps the routes on the public router
+	User     string
+	Password string
+}
+
+func loadInternalConfig() internalConfig {
+	return internalConfig{
+		Addr:     getEnv("INTERNAL_ADDR", ""),
+		User:     getEnv("INTERNAL_BASIC_AUTH_USER", ""),
+		Password: getEnv("INTERNAL_BASIC_AUTH_PASSWORD", ""),
+	}
+}
+
+func (c internalConfig) basicAuthEnabled() bool {
+	return c.User != "" && c.Password != ""
+}
+
+// registerInternalRoutes adds the operational endpoints to r. pprof is only mounted when
+// it is not reachable anonymously on the public port.
+func registerInternalRoutes(r *mux.Router, cfg internalConfig) {
+	protect := func(h http.Handler) http.Handler {
+		if cfg.basicAuthEnabled() {
+			return basicAuth(cfg, h)
+		}
+		return h
+	}
+
+	r.Handle("/metrics", protect(obs.MetricsHandler())).Methods("GET")
+	r.Handle("/slo", protect(http.HandlerFunc(sloHandler))).Methods("GET")
+	r.Handle("/debug/config", protect(adminOnly(debugConfigHandler))).Methods("GET")
+	r.Handle("/debug/topk", protect(adminOnly(topKHandler))).Methods("GET")
+	r.Handle("/admin/reload", protect(adminOnly(reloadHandler))).Methods("POST")
+	r.Handle("/admin/chaos", protect(adminOnly(chaosHandler))).Methods("GET", "POST", "DELETE")
+	r.Handle("/stress/cpu", protect(adminOnly(stressCPUHandler))).Methods("POST")
+	r.Handle("/stress/mem", protect(adminOnly(stressMemHandler))).Methods("POST")
+
+	if cfg.Addr == "" && !cfg.basicAuthEnabled() {
+		return
+	}
+	r.Handle("/debug/pprof/cmdline", protect(http.HandlerFunc(pprof.Cmdline)))
+	r.Handle("/debug/pprof/profile", protect(http.HandlerFunc(pprof.Profile)))
+	r.Handle("/debug/pprof/symbol", protect(http.HandlerFunc(pprof.Symbol)))
+	r.Handle("/debug/pprof/trace", protect(http.HandlerFunc(pprof.Trace)))
+	r.PathPrefix("/debug/pprof/").Handler(protect(http.HandlerFunc(pprof.Index)))
+}
+
+// newInternalRouter serves only the operational endpoints, with the same tracing, logging
+// and metrics as the public router but no auth, rate limiting or timeouts
+func newInternalRouter(cfg internalConfig) *mux.Router {
+	r := mux.NewRouter()
+	r.Use(middleware.Chain(
+		middleware.Quiet(loadQuietOptions()),
+		middleware.Tracing(serviceName),
+		middleware.ClientIP(loadClientIPOptions()),
+		middleware.RequestID(middleware.RequestIDOptions{}),
+		middleware.Logging(middleware.LoggingOptions{}),
+		middleware.Metrics(middleware.MetricsOptions{Buckets: httpDurationBuckets}),
+		initTrafficStats().Middleware(),
+		middleware.Recovery(),
+	))
+	registerInternalRoutes(r, cfg)
+	return r
+}
+
+// basicAuth requires the configured INTERNAL_BASIC_AUTH_USER / _PASSWORD
+func basicAuth(cfg internalConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(cfg.User)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(cfg.Password)) == 1
+		if !ok || !userOK || !passwordOK {
+			logWarnContext(r.Context(), "Rejected internal endpoint request", map[string]interface{}{
+				"remote_addr": r.RemoteAddr,
+				"method":      r.Method,
+				"path":        r.URL.Path,
+			})
+			w.Header().Set("WWW-Authenticate", `Basic realm="go-service internal"`)
+			writeError(w, r, newAppError(http.StatusUnauthorized, "unauthorized", "unauthorized"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
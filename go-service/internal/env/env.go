@@ -0,0 +1,69 @@
+// Package env reads typed configuration values from environment variables.
+package env
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String returns the value of key or fallback when unset or empty
+func String(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// Int returns key parsed as an int, or fallback when unset or invalid
+func Int(key string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// Float returns key parsed as a float64, or fallback when unset or invalid
+func Float(key string, fallback float64) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(os.Getenv(key)), 64)
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// Bool returns key parsed as a bool, or fallback when unset or invalid
+func Bool(key string, fallback bool) bool {
+	v, err := strconv.ParseBool(strings.TrimSpace(os.Getenv(key)))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// Duration returns key parsed as a time.Duration, or fallback when unset or invalid
+func Duration(key string, fallback time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
+
+// List returns key (or fallback) split on commas, dropping empty entries
+func List(key, fallback string) []string {
+	return Split(String(key, fallback))
+}
+
+// Split splits a comma-separated list, dropping empty entries
+func Split(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
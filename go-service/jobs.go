@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+var (
+	jobRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "job_runs_total",
+			Help: "Total number of background job runs",
+		},
+		[]string{"job", "status"},
+	)
+
+	jobDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "job_duration_seconds",
+			Help:    "Background job run duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"job"},
+	)
+
+	jobLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "job_last_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful run of each background job",
+		},
+		[]string{"job"},
+	)
+)
+
+func init() {
+	obs.Registry.MustRegister(jobRunsTotal)
+	obs.Registry.MustRegister(jobDuration)
+	obs.Registry.MustRegister(jobLastSuccess)
+}
+
+// jobFailurePercent is the synthetic-work error-injection rate; it can be changed by a config reload
+var jobFailurePercent atomic.Int64
+
+// Job is a periodic unit of background work
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// startJobs runs each job on its own ticker until ctx is cancelled
+func startJobs(ctx context.Context, jobs []Job) {
+	for _, job := range jobs {
+		logInfo("Background job scheduled", map[string]interface{}{
+			"job":              job.Name,
+			"interval_seconds": job.Interval.Seconds(),
+		})
+		job := job
+		goBackground(func() { scheduleJob(ctx, job) })
+	}
+}
+
+func scheduleJob(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			runJob(ctx, job)
+		}
+	}
+}
+
+// runJob executes a single run inside its own root span, bounded by the job interval
+func runJob(ctx context.Context, job Job) {
+	ctx, cancel := context.WithTimeout(ctx, job.Interval)
+	defer cancel()
+
+	ctx, span := otel.Tracer(serviceName).Start(ctx, "job "+job.Name,
+		trace.WithNewRoot(),
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("job.name", job.Name)),
+	)
+	defer span.End()
+
+	start := time.Now()
+	err := job.Run(ctx)
+	duration := time.Since(start).Seconds()
+
+	jobDuration.WithLabelValues(job.Name).Observe(duration)
+	fields := map[string]interface{}{
+		"job":              job.Name,
+		"duration_seconds": duration,
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		jobRunsTotal.WithLabelValues(job.Name, "failure").Inc()
+		fields["error"] = err.Error()
+		logErrorContext(ctx, "Background job failed", fields)
+		return
+	}
+	jobRunsTotal.WithLabelValues(job.Name, "success").Inc()
+	jobLastSuccess.WithLabelValues(job.Name).SetToCurrentTime()
+	logInfoContext(ctx, "Background job completed", fields)
+}
+
+// defaultJobs are the demo jobs enabled by JOBS_ENABLED
+func defaultJobs() []Job {
+	interval := getEnvDuration("JOBS_INTERVAL", 30*time.Second)
+	jobFailurePercent.Store(int64(getEnvInt("JOBS_FAILURE_PERCENT", 10)))
+
+	return []Job{
+		{
+			Name:     "synthetic-work",
+			Interval: interval,
+			Run: func(ctx context.Context) error {
+				// Simulated work with a tail and an occasional failure
+				delay := time.Duration(sampleLatency(50, 1000) * float64(time.Millisecond))
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("job.work_ms", delay.Milliseconds()))
+				if rand.Float64() < float64(jobFailurePercent.Load())/100 {
+					return errors.New("synthetic job failure")
+				}
+				return nil
+			},
+		},
+		{
+			Name:     "user-count",
+			Interval: interval,
+			Run: func(ctx context.Context) error {
+				if usersDB == nil {
+					return nil
+				}
+				var count int64
+				if err := usersDB.QueryRowContext(ctx, `SELECT count(*) FROM users`).Scan(&count); err != nil {
+					return err
+				}
+				trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("users.count", count))
+				return nil
+			},
+		},
+	}
+}
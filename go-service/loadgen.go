@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// loadgenConfig controls the synthetic traffic generator started with --loadgen
+type loadgenConfig struct {
+	Targets     []string
+	Endpoints   []string
+	RPS         float64
+	ErrorRatio  float64
+	Duration    time.Duration // zero runs until interrupted
+	MaxInFlight int
+}
+
+// loadgenFlags holds the parsed --loadgen-* flags
+type loadgenFlags struct {
+	targets      *string
+	endpoints    *string
+	rps          *float64
+	errorPercent *int
+	duration     *time.Duration
+	maxInFlight  *int
+}
+
+// registerLoadgenFlags registers the loadgen flags, defaulting from LOADGEN_* env vars
+func registerLoadgenFlags(fs *flag.FlagSet) *loadgenFlags {
+	return &loadgenFlags{
+		targets:      fs.String("loadgen-targets", getEnv("LOADGEN_TARGETS", "http://localhost:8080,http://typescript-service:3000,http://elixir-service:4000"), "comma-separated base URLs to send traffic to"),
+		endpoints:    fs.String("loadgen-endpoints", getEnv("LOADGEN_ENDPOINTS", "/,/health,/slow?p50=20&p99=500"), "comma-separated paths requested on each target"),
+		rps:          fs.Float64("loadgen-rps", float64(getEnvInt("LOADGEN_RPS", 5)), "requests per second across all targets"),
+		errorPercent: fs.Int("loadgen-error-percent", getEnvInt("LOADGEN_ERROR_PERCENT", 5), "percentage of requests sent to a missing route"),
+		duration:     fs.Duration("loadgen-duration", getEnvDuration("LOADGEN_DURATION", 0), "how long to run, 0 for forever"),
+		maxInFlight:  fs.Int("loadgen-max-inflight", getEnvInt("LOADGEN_MAX_INFLIGHT", 100), "maximum concurrent requests"),
+	}
+}
+
+// config resolves the flags once they have been parsed, rejecting a rate or concurrency
+// that is not positive
+func (f *loadgenFlags) config() (loadgenConfig, error) {
+	if *f.rps <= 0 {
+		return loadgenConfig{}, fmt.Errorf("loadgen-rps must be positive, got %v", *f.rps)
+	}
+	if *f.maxInFlight <= 0 {
+		return loadgenConfig{}, fmt.Errorf("loadgen-max-inflight must be positive, got %d", *f.maxInFlight)
+	}
+	return loadgenConfig{
+		Targets:     splitList(*f.targets),
+		Endpoints:   splitList(*f.endpoints),
+		RPS:         *f.rps,
+		ErrorRatio:  float64(*f.errorPercent) / 100,
+		Duration:    *f.duration,
+		MaxInFlight: *f.maxInFlight,
+	}, nil
+}
+
+// interval is the time between requests; rates too high to express in nanoseconds send
+// as fast as the ticker allows
+func (cfg loadgenConfig) interval() time.Duration {
+	if d := time.Duration(float64(time.Second) / cfg.RPS); d > 0 {
+		return d
+	}
+	return time.Nanosecond
+}
+
+// loadgenErrorRoute is requested on purpose at ErrorRatio; every target answers it 404
+const loadgenErrorRoute = "/loadgen-missing-route"
+
+// runLoadgen issues requests at the configured rate until ctx is done or Duration elapses
+func runLoadgen(ctx context.Context, cfg loadgenConfig) {
+	if len(cfg.Targets) == 0 || len(cfg.Endpoints) == 0 || cfg.RPS <= 0 || cfg.MaxInFlight <= 0 {
+		logError("Load generator misconfigured", map[string]interface{}{
+			"targets":   cfg.Targets,
+			"endpoints": cfg.Endpoints,
+			"rps":       cfg.RPS,
+			"in_flight": cfg.MaxInFlight,
+		})
+		return
+	}
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
+
+	logInfo("Load generator starting", map[string]interface{}{
+		"targets":     cfg.Targets,
+		"endpoints":   cfg.Endpoints,
+		"rps":         cfg.RPS,
+		"error_ratio": cfg.ErrorRatio,
+	})
+
+	// failed counts requests whose outcome was not the expected one; injected counts the
+	// error route's 404s, which are intended rather than successes or failures
+	var sent, failed, injected, dropped atomic.Int64
+	inFlight := make(chan struct{}, cfg.MaxInFlight)
+	var wg sync.WaitGroup
+
+	ticker := time.NewTicker(cfg.interval())
+	defer ticker.Stop()
+	report := time.NewTicker(10 * time.Second)
+	defer report.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			logInfo("Load generator stopped", map[string]interface{}{
+				"sent":     sent.Load(),
+				"failed":   failed.Load(),
+				"injected": injected.Load(),
+				"dropped":  dropped.Load(),
+			})
+			return
+		case <-report.C:
+			logInfo("Load generator progress", map[string]interface{}{
+				"sent":     sent.Load(),
+				"failed":   failed.Load(),
+				"injected": injected.Load(),
+				"dropped":  dropped.Load(),
+			})
+		case <-ticker.C:
+			select {
+			case inFlight <- struct{}{}:
+			default:
+				// Targets are too slow for the requested rate; shed rather than queue unboundedly
+				dropped.Add(1)
+				continue
+			}
+
+			url := cfg.Targets[rand.Intn(len(cfg.Targets))]
+			injectError := rand.Float64() < cfg.ErrorRatio
+			if injectError {
+				url += loadgenErrorRoute
+			} else {
+				url += cfg.Endpoints[rand.Intn(len(cfg.Endpoints))]
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-inFlight }()
+				sent.Add(1)
+				status := loadgenRequest(ctx, client, url)
+				switch {
+				case injectError && status == http.StatusNotFound:
+					injected.Add(1)
+				case injectError, status == 0, status >= 400:
+					failed.Add(1)
+				}
+			}()
+		}
+	}
+}
+
+// loadgenRequest performs one GET, draining the body so connections are reused, and
+// returns its status, or 0 if no response arrived
+func loadgenRequest(ctx context.Context, client *http.Client, url string) int {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0
+	}
+	req.Header.Set("User-Agent", serviceName+"-loadgen")
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			logDebug("Load generator request failed", map[string]interface{}{
+				"url":   url,
+				"error": err.Error(),
+			})
+		}
+		return 0
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode
+}
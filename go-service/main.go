@@ -3,96 +3,51 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"log"
+	"flag"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	tracesdk "go.opentelemetry.io/otel/sdk/trace"
-	metricsdk "go.opentelemetry.io/otel/sdk/metric"
-	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
-)
+	"google.golang.org/grpc"
 
-// LogEntry represents a structured log entry - Consistent format across all services
-type LogEntry map[string]interface{}
+	"go-service/middleware"
+	"go-service/obs"
+)
 
 type HealthResponse struct {
 	Status  string `json:"status"`
 	Service string `json:"service"`
 }
 
-var (
-	serviceName = "go-service"
-	logger      = log.New(os.Stdout, "", 0)
-	
-	// Prometheus metrics
-	httpRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"method", "endpoint", "status"},
-	)
-	
-	httpRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "HTTP request duration in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"method", "endpoint"},
-	)
-)
+var serviceName = "go-service"
 
-func init() {
-	prometheus.MustRegister(httpRequestsTotal)
-	prometheus.MustRegister(httpRequestDuration)
-}
+// Logger convenience methods
+var logInfo = obs.Info
 
-// log creates a structured log entry with consistent format
-// Core fields at top level, request/context fields nested in "fields" object
-func log(level, message string, additionalFields map[string]interface{}) {
-	entry := LogEntry{
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"level":     level,
-		"service":   serviceName,
-		"message":   message,
-	}
-	
-	// Nest additional fields in "fields" object (consistent structure)
-	if len(additionalFields) > 0 {
-		entry["fields"] = additionalFields
-	}
-	
-	jsonData, _ := json.Marshal(entry)
-	logger.Println(string(jsonData))
-}
+var logWarn = obs.Warn
 
-// Logger convenience methods
-var logInfo = func(message string, fields map[string]interface{}) {
-	log("INFO", message, fields)
-}
+var logError = obs.Error
 
-var logWarn = func(message string, fields map[string]interface{}) {
-	log("WARN", message, fields)
-}
+var logDebug = obs.Debug
 
-var logError = func(message string, fields map[string]interface{}) {
-	log("ERROR", message, fields)
-}
+// Context-aware variants add baggage fields and turn WARN/ERROR records into span events
+var logInfoContext = obs.InfoContext
+
+var logWarnContext = obs.WarnContext
+
+var logErrorContext = obs.ErrorContext
 
-var logDebug = func(message string, fields map[string]interface{}) {
-	log("DEBUG", message, fields)
+// logFatal flushes telemetry and exits; use it instead of log.Fatal so the failure is structured and exported
+var logFatal = obs.Fatal
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -107,148 +62,195 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func rootHandler(w http.ResponseWriter, r *http.Request) {
+	if boolFlag(r, flagRootErrorInjection, false) {
+		logErrorContext(r.Context(), "Injected error", map[string]interface{}{
+			"flag": flagRootErrorInjection,
+		})
+		writeError(w, r, newAppError(http.StatusInternalServerError, "injected_error", "injected error"))
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Go Service is running!"))
 }
 
-func initTracing() {
-	otlpEndpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
-	if otlpEndpoint == "" {
-		otlpEndpoint = "otel-collector:4317"
-	}
-	
-	// Initialize OTLP trace exporter
-	traceExp, err := otlptracegrpc.New(
-		context.Background(),
-		otlptracegrpc.WithEndpoint(otlpEndpoint),
-		otlptracegrpc.WithInsecure(),
-	)
+// initTelemetry runs obs.Init; on failure the service keeps running untraced
+func initTelemetry() obs.ShutdownFunc {
+	shutdown, err := obs.Init(context.Background(), obs.Config{ServiceName: serviceName})
 	if err != nil {
-		logError("Failed to create OTLP trace exporter", map[string]interface{}{
+		logError("Failed to initialize OpenTelemetry, continuing without it", map[string]interface{}{
 			"error": err.Error(),
 		})
-		return
 	}
+	return shutdown
+}
+
+// newRouter builds the HTTP router with the observability middleware and every demo route
+func newRouter() *mux.Router {
+	r := mux.NewRouter()
+	r.Use(middleware.Chain(
+		middleware.DebugTrace(middleware.DebugTraceOptions{Token: getEnv("DEBUG_TRACE_TOKEN", "")}),
+		middleware.Quiet(loadQuietOptions()),
+		middleware.Tracing(serviceName),
+		middleware.ClientIP(loadClientIPOptions()),
+		middleware.Baggage(),
+		middleware.Identity(loadIdentityOptions()),
+		middleware.Tenant(middleware.TenantOptions{Allowed: splitList(getEnv("TENANT_ALLOWLIST", ""))}),
+		middleware.TraceResponse,
+		middleware.RequestID(middleware.RequestIDOptions{}),
+		middleware.Logging(middleware.LoggingOptions{}),
+		middleware.Metrics(middleware.MetricsOptions{Buckets: httpDurationBuckets}),
+		initSLOs().Middleware(),
+		initTrafficStats().Middleware(),
+		middleware.Auth(loadAuthOptions()),
+		middleware.Timeout(loadRouteTimeouts()),
+		middleware.Recovery(),
+	))
+	r.Use(rateLimitMiddleware(loadRateLimitConfig()))
+	r.Use(chaosMiddleware)
+	r.Use(compressionMiddleware)
 	
-	// Initialize OTLP metrics exporter
-	metricExp, err := otlpmetricgrpc.New(
-		context.Background(),
-		otlpmetricgrpc.WithEndpoint(otlpEndpoint),
-		otlpmetricgrpc.WithInsecure(),
-	)
-	if err != nil {
-		logError("Failed to create OTLP metrics exporter", map[string]interface{}{
-			"error": err.Error(),
-		})
-		return
+	r.HandleFunc("/health", healthHandler).Methods("GET")
+	r.HandleFunc("/readyz", readyzHandler).Methods("GET")
+	r.HandleFunc("/health/dependencies", dependenciesHealthHandler).Methods("GET")
+	r.HandleFunc("/", rootHandler).Methods("GET")
+	r.HandleFunc("/openapi.json", openAPIHandler(r)).Methods("GET")
+	r.HandleFunc("/docs", swaggerUIHandler).Methods("GET")
+	r.HandleFunc("/slow", slowHandler).Methods("GET")
+	r.HandleFunc("/users", requireDB(listUsersHandler)).Methods("GET")
+	r.HandleFunc("/users", requireDB(createUserHandler)).Methods("POST")
+	r.HandleFunc("/users/{id}", requireDB(getUserHandler)).Methods("GET")
+	r.HandleFunc("/users/{id}", requireDB(updateUserHandler)).Methods("PUT")
+	r.HandleFunc("/users/{id}", requireDB(deleteUserHandler)).Methods("DELETE")
+	r.HandleFunc("/cache/{key}", requireCache(getCacheHandler)).Methods("GET")
+	r.HandleFunc("/cache/{key}", requireCache(setCacheHandler)).Methods("PUT", "POST")
+	r.HandleFunc("/cache/{key}", requireCache(deleteCacheHandler)).Methods("DELETE")
+	r.HandleFunc("/events", eventsHandler).Methods("POST")
+	r.HandleFunc("/call-node", callTargetHandler(nodeTarget)).Methods("GET")
+	r.HandleFunc("/call-elixir", callTargetHandler(elixirTarget)).Methods("GET")
+	r.HandleFunc("/chain", chainHandler).Methods("GET")
+	r.HandleFunc("/scenario/{name}", scenarioHandler).Methods("GET")
+	r.HandleFunc("/baggage", baggageHandler).Methods("GET")
+	r.HandleFunc("/fanout", fanoutHandler).Methods("GET")
+	r.HandleFunc("/compute", computeHandler).Methods("GET")
+	r.Handle("/graphql", graphqlHandler()).Methods("GET", "POST")
+	r.HandleFunc("/orders", initOrderService().createHandler).Methods("POST")
+	r.HandleFunc("/ws/echo", wsEchoHandler).Methods("GET")
+	r.HandleFunc("/stream", streamHandler).Methods("GET")
+	if internal := loadInternalConfig(); internal.Addr == "" {
+		registerInternalRoutes(r, internal)
 	}
-	
-	res, err := resource.New(
-		context.Background(),
-		resource.WithAttributes(
-			semconv.ServiceNameKey.String(serviceName),
-		),
-	)
-	if err != nil {
-		logError("Failed to create resource", map[string]interface{}{
-			"error": err.Error(),
-		})
+
+	return r
+}
+
+func main() {
+	loadgen := flag.Bool("loadgen", false, "run the synthetic traffic generator instead of the server")
+	loadgenOpts := registerLoadgenFlags(flag.CommandLine)
+	flag.Parse()
+
+	shutdownTelemetry := initTelemetry()
+
+	if *loadgen {
+		cfg, err := loadgenOpts.config()
+		if err != nil {
+			logFatal("Invalid load generator flags", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		runLoadgen(ctx, cfg)
+		stop()
+
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownTelemetry(flushCtx)
 		return
 	}
+
+	// Background work stops after the HTTP and gRPC servers have drained
+	bgCtx, cancelBackground := context.WithCancel(context.Background())
+	initDatabase(context.Background())
+	initCache()
+	initEvents(bgCtx)
+	initFeatureFlags()
+	if getEnvBool("JOBS_ENABLED", true) {
+		startJobs(bgCtx, defaultJobs())
+	}
+	startHeartbeat(bgCtx, loadHeartbeatConfig())
+	startWatchdog(bgCtx, loadWatchdogConfig())
 	
-	tp := tracesdk.NewTracerProvider(
-		tracesdk.WithBatcher(traceExp),
-		tracesdk.WithResource(res),
-	)
-	
-	mp := metricsdk.NewMeterProvider(
-		metricsdk.WithReader(metricsdk.NewPeriodicReader(metricExp)),
-		metricsdk.WithResource(res),
-	)
-	
-	otel.SetTracerProvider(tp)
-	otel.SetMeterProvider(mp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	))
-	
-	logInfo("OpenTelemetry SDK initialized", map[string]interface{}{
-		"otlp_endpoint": otlpEndpoint,
-	})
-}
+	r := newRouter()
+	watchReload(bgCtx)
 
-// loggingMiddleware logs all HTTP requests and responses
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		
-		// Log incoming request
-		logInfo("Incoming HTTP request", map[string]interface{}{
-			"remote_addr": r.RemoteAddr,
-			"method":      r.Method,
-			"path":        r.URL.Path,
-			"user_agent":  r.UserAgent(),
-		})
-		
-		// Wrap response writer to capture status code
-		wrapped := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
-		
-		next.ServeHTTP(wrapped, r)
-		
-		duration := time.Since(start).Seconds()
-		statusCode := wrapped.statusCode
-		
-		// Determine log level based on status code
-		var logFunc func(string, map[string]interface{})
-		if statusCode >= 500 {
-			logFunc = logError
-		} else if statusCode >= 400 {
-			logFunc = logWarn
-		} else {
-			logFunc = logInfo
+	serverCfg := loadServerConfig()
+	srv := newServer(serverCfg, corsMiddleware(loadCORSConfig(), r))
+
+	var internalSrv *http.Server
+	if internal := loadInternalConfig(); internal.Addr != "" {
+		internalSrv = &http.Server{
+			Addr:              internal.Addr,
+			Handler:           newInternalRouter(internal),
+			ReadHeaderTimeout: 10 * time.Second,
 		}
-		
-		// Log response
-		logFunc("HTTP request completed", map[string]interface{}{
-			"remote_addr":     r.RemoteAddr,
-			"method":          r.Method,
-			"path":            r.URL.Path,
-			"status":          statusCode,
-			"duration_seconds": duration,
+		go func() {
+			if err := internalSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logError("Internal listener failed", map[string]interface{}{
+					"addr":  internal.Addr,
+					"error": err.Error(),
+				})
+			}
+		}()
+		logInfo("Internal listener starting", map[string]interface{}{
+			"addr":       internal.Addr,
+			"basic_auth": internal.basicAuthEnabled(),
 		})
-		
-		// Update metrics
-		httpRequestsTotal.WithLabelValues(r.Method, r.URL.Path, fmt.Sprintf("%d", statusCode)).Inc()
-		httpRequestDuration.WithLabelValues(r.Method, r.URL.Path).Observe(duration)
-	})
-}
-
-// responseWriter wraps http.ResponseWriter to capture status code
-type responseWriter struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-func (rw *responseWriter) WriteHeader(code int) {
-	rw.statusCode = code
-	rw.ResponseWriter.WriteHeader(code)
-}
+	}
 
-func main() {
-	initTracing()
-	
-	r := mux.NewRouter()
-	r.Use(otelmux.Middleware(serviceName))
-	r.Use(loggingMiddleware)
-	
-	r.HandleFunc("/health", healthHandler).Methods("GET")
-	r.HandleFunc("/", rootHandler).Methods("GET")
-	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	var grpcSrv *grpc.Server
+	if grpcPort := getEnv("GRPC_PORT", "9090"); grpcPort != "0" {
+		grpcSrv = serveGRPC(":" + grpcPort)
+	}
 
 	logInfo("Go service starting", map[string]interface{}{
-		"port": 8080,
+		"addr":          serverCfg.Addr,
+		"tls":           serverCfg.TLSEnabled(),
+		"redirect_addr": serverCfg.RedirectAddr,
 	})
 	
-	log.Fatal(http.ListenAndServe(":8080", r))
+	stopCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	redirectSrv := newRedirectServer(serverCfg)
+	go func() { serveErr <- listenAndServe(serverCfg, srv, redirectSrv) }()
+
+	select {
+	case err := <-serveErr:
+		logFatal("HTTP server stopped", map[string]interface{}{
+			"error": err.Error(),
+		})
+	case <-stopCtx.Done():
+		stop()
+	}
+
+	// Telemetry is flushed last so the drained requests' spans and logs are exported
+	drainCfg := loadDrainConfig()
+	drain(srv, drainCfg)
+	if redirectSrv != nil {
+		redirectCtx, cancelRedirect := context.WithTimeout(context.Background(), drainCfg.Timeout)
+		redirectSrv.Shutdown(redirectCtx)
+		cancelRedirect()
+	}
+	if grpcSrv != nil {
+		stopGRPC(grpcSrv, drainCfg.Timeout)
+	}
+	stopBackground(cancelBackground, drainCfg.Timeout)
+	closeEvents()
+	initRateLimiter().close()
+	initOrderService().close()
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if internalSrv != nil {
+		internalSrv.Shutdown(flushCtx)
+	}
+	shutdownTelemetry(flushCtx)
 }
@@ -0,0 +1,855 @@
+package main
+
+import (
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/http2"
+
+	"go-service/middleware"
+	"go-service/obs"
+)
+
+func spanAttr(attrs []attribute.KeyValue, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range attrs {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestHealthIsTracedMeasuredAndLogged(t *testing.T) {
+	h := newTestHarness(t)
+	labels := map[string]string{"method": "GET", "endpoint": "/health", "status": "200", "protocol": "http/1.1", "tenant": "none"}
+	before := promCounter(t, "http_requests_total", labels)
+
+	rec := h.do(http.MethodGet, "/health", http.Header{"X-Request-Id": {"req-123"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	span := h.span("/health")
+	if span.SpanKind != trace.SpanKindServer {
+		t.Errorf("span kind = %v, want server", span.SpanKind)
+	}
+	if v, ok := spanAttr(span.Attributes, "http.route"); !ok || v.AsString() != "/health" {
+		t.Errorf("http.route = %v", v.Emit())
+	}
+	if v, ok := spanAttr(span.Attributes, "http.status_code"); !ok || v.AsInt64() != 200 {
+		t.Errorf("http.status_code = %v", v.Emit())
+	}
+	if v, ok := spanAttr(span.Attributes, "http.request_id"); !ok || v.AsString() != "req-123" {
+		t.Errorf("http.request_id = %v", v.Emit())
+	}
+	if got := rec.Header().Get("X-Trace-Id"); got != span.SpanContext.TraceID().String() {
+		t.Errorf("X-Trace-Id = %q, want %q", got, span.SpanContext.TraceID())
+	}
+
+	if got := promCounter(t, "http_requests_total", labels); got != before+1 {
+		t.Errorf("http_requests_total%v = %v, want %v", labels, got, before+1)
+	}
+
+	completed := h.logEntries("HTTP request completed")
+	if len(completed) != 1 {
+		t.Fatalf("want one completion log, got %d", len(completed))
+	}
+	if completed[0]["level"] != "INFO" {
+		t.Errorf("level = %v", completed[0]["level"])
+	}
+	fields := completed[0]["fields"].(map[string]interface{})
+	for k, want := range map[string]interface{}{"method": "GET", "path": "/health", "status": float64(200), "request_id": "req-123"} {
+		if fields[k] != want {
+			t.Errorf("fields[%q] = %v, want %v", k, fields[k], want)
+		}
+	}
+	if _, ok := fields["duration_seconds"]; !ok {
+		t.Error("duration_seconds missing")
+	}
+}
+
+func TestServerErrorsLogAtErrorWithoutDatabase(t *testing.T) {
+	h := newTestHarness(t)
+	usersDB = nil
+
+	rec := h.do(http.MethodGet, "/users/42", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+
+	// The route template, not the raw path, is the metric label and span name
+	span := h.span("/users/{id}")
+	var event *sdktrace.Event
+	for i := range span.Events {
+		if span.Events[i].Name == "HTTP request completed" {
+			event = &span.Events[i]
+		}
+	}
+	if event == nil {
+		t.Fatalf("ERROR log not attached as a span event: %v", span.Events)
+	}
+	if v, _ := spanAttr(event.Attributes, "log.severity"); v.AsString() != "ERROR" {
+		t.Errorf("log.severity = %q, want ERROR", v.AsString())
+	}
+	if v, _ := spanAttr(event.Attributes, "status"); v.AsInt64() != 503 {
+		t.Errorf("status attribute = %v, want 503", v.Emit())
+	}
+	if got := promCounter(t, "http_requests_total", map[string]string{"method": "GET", "endpoint": "/users/{id}", "status": "503", "protocol": "http/1.1", "tenant": "none"}); got < 1 {
+		t.Errorf("503 not counted under the route template")
+	}
+	completed := h.logEntries("HTTP request completed")
+	if len(completed) != 1 || completed[0]["level"] != "ERROR" {
+		t.Errorf("want one ERROR completion log, got %v", completed)
+	}
+}
+
+func TestAppErrorsRenderProblemDetailsAndTelemetry(t *testing.T) {
+	h := newTestHarness(t)
+	usersDB = nil
+	labels := map[string]string{"code": "database_not_configured", "status": "503"}
+	before := promCounter(t, "app_errors_total", labels)
+
+	rec := h.do(http.MethodGet, "/users/42", http.Header{"X-Request-Id": {"req-err"}})
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var problem middleware.ProblemDetails
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	span := h.span("/users/{id}")
+	want := middleware.ProblemDetails{
+		Type:      "urn:go-service:error:database_not_configured",
+		Title:     "Service Unavailable",
+		Status:    http.StatusServiceUnavailable,
+		Detail:    "database not configured",
+		Instance:  "/users/42",
+		Code:      "database_not_configured",
+		Retryable: true,
+		RequestID: "req-err",
+		TraceID:   span.SpanContext.TraceID().String(),
+	}
+	if problem.Type != want.Type || problem.Title != want.Title || problem.Status != want.Status || problem.Detail != want.Detail ||
+		problem.Instance != want.Instance || problem.Code != want.Code || problem.Retryable != want.Retryable ||
+		problem.RequestID != want.RequestID || problem.TraceID != want.TraceID {
+		t.Errorf("problem = %+v, want %+v", problem, want)
+	}
+
+	if v, _ := spanAttr(span.Attributes, "error.type"); v.AsString() != "database_not_configured" {
+		t.Errorf("error.type = %v", v.Emit())
+	}
+	if span.Status.Code != codes.Error {
+		t.Errorf("span status = %v, want error for a 5xx", span.Status.Code)
+	}
+	if got := promCounter(t, "app_errors_total", labels); got != before+1 {
+		t.Errorf("app_errors_total%v = %v, want %v", labels, got, before+1)
+	}
+	completed := h.logEntries("HTTP request completed")
+	if fields, _ := completed[0]["fields"].(map[string]interface{}); fields["error_code"] != "database_not_configured" {
+		t.Errorf("completion log lacks error_code: %v", completed[0])
+	}
+}
+
+func TestReloadConfigLogsDiffAndRejectsUnknownKeys(t *testing.T) {
+	h := newTestHarness(t)
+	for _, key := range reloadableKeys {
+		t.Setenv(key, os.Getenv(key))
+	}
+	t.Cleanup(func() { obs.SetLevel("INFO") })
+
+	changed, err := reloadConfig("test", map[string]string{"LOG_LEVEL": "DEBUG", "JOBS_FAILURE_PERCENT": "50"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed["LOG_LEVEL"].New != "DEBUG" || obs.Level() != "DEBUG" {
+		t.Errorf("LOG_LEVEL not applied: %v, level %s", changed, obs.Level())
+	}
+	if jobFailurePercent.Load() != 50 {
+		t.Errorf("jobFailurePercent = %d, want 50", jobFailurePercent.Load())
+	}
+
+	logged := h.logEntries("Config reloaded")
+	if len(logged) != 1 {
+		t.Fatalf("want one reload log, got %d", len(logged))
+	}
+	diff := logged[0]["fields"].(map[string]interface{})["diff"].(map[string]interface{})
+	if len(diff) != 2 {
+		t.Errorf("diff = %v, want LOG_LEVEL and JOBS_FAILURE_PERCENT", diff)
+	}
+
+	if _, err := reloadConfig("test", map[string]string{"PORT": "1"}); err == nil {
+		t.Error("reloading PORT should fail")
+	}
+	if _, err := reloadConfig("test", map[string]string{"LOG_LEVEL": "LOUD"}); err == nil || obs.Level() != "DEBUG" {
+		t.Errorf("invalid LOG_LEVEL should fail and keep the old level, got %v, level %s", err, obs.Level())
+	}
+}
+
+func TestReloadedRateLimitExemptionsApply(t *testing.T) {
+	for _, key := range reloadableKeys {
+		t.Setenv(key, os.Getenv(key))
+	}
+	t.Setenv("RATE_LIMIT_RPS", "0.001")
+	t.Setenv("RATE_LIMIT_BURST", "1")
+	t.Setenv("RATE_LIMIT_EXEMPT", "/health")
+	h := newTestHarness(t)
+	defer initRateLimiter().update(rateLimitConfig{})
+
+	h.do(http.MethodGet, "/", nil)
+	if rec := h.do(http.MethodGet, "/", nil); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request status = %d, want 429", rec.Code)
+	}
+	if _, err := reloadConfig("test", map[string]string{"RATE_LIMIT_EXEMPT": "/health,/"}); err != nil {
+		t.Fatal(err)
+	}
+	if rec := h.do(http.MethodGet, "/", nil); rec.Code != http.StatusOK {
+		t.Errorf("status after exempting / = %d, want 200", rec.Code)
+	}
+}
+
+func TestCORSDefaultsAllowAPIKeyPreflight(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://app.example")
+	h := corsMiddleware(loadCORSConfig(), http.NotFoundHandler())
+	req := httptest.NewRequest(http.MethodOptions, "/users", nil)
+	req.Header.Set("Origin", "https://app.example")
+	req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	req.Header.Set("Access-Control-Request-Headers", "x-api-key")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code == http.StatusForbidden {
+		t.Errorf("preflight with X-API-Key rejected: %s", rec.Body)
+	}
+}
+
+func TestFanoutWorkersAreLinkedNotParented(t *testing.T) {
+	h := newTestHarness(t)
+
+	rec := h.do(http.MethodGet, "/fanout?n=2", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	request := h.span("/fanout")
+	workers := 0
+	for _, s := range h.spans.GetSpans() {
+		if s.Name != "fanout worker" {
+			continue
+		}
+		workers++
+		if s.SpanContext.TraceID() == request.SpanContext.TraceID() {
+			t.Error("worker shares the request trace; want a new root")
+		}
+		if len(s.Links) != 1 || s.Links[0].SpanContext.SpanID() != request.SpanContext.SpanID() {
+			t.Errorf("worker links = %v, want one link to the request span", s.Links)
+		}
+	}
+	if workers != 2 {
+		t.Errorf("got %d worker spans, want 2", workers)
+	}
+}
+
+func TestComputePhasesAreChildSpans(t *testing.T) {
+	h := newTestHarness(t)
+
+	if rec := h.do(http.MethodGet, "/compute?items=10", nil); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	request := h.span("/compute")
+	for _, phase := range computePhases {
+		s := h.span("compute." + phase)
+		if s.Parent.SpanID() != request.SpanContext.SpanID() {
+			t.Errorf("%s is not a child of the request span", s.Name)
+		}
+	}
+	if v, ok := spanAttr(h.span("compute.transform").Attributes, "compute.transformed"); !ok || v.AsInt64() != 10 {
+		t.Errorf("compute.transformed = %v", v)
+	}
+
+	h.spans.Reset()
+	rec := h.do(http.MethodGet, "/compute?fail=transform", nil)
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), `"phase":"transform"`) {
+		t.Fatalf("failed phase: status = %d, body = %s", rec.Code, rec.Body)
+	}
+	if s := h.span("compute.transform"); s.Status.Code != codes.Error {
+		t.Errorf("transform span status = %v, want error", s.Status)
+	}
+	for _, s := range h.spans.GetSpans() {
+		if s.Name == "compute.render" {
+			t.Error("phases after the failed one should not run")
+		}
+	}
+}
+
+func TestGraphQLResolversGetSpans(t *testing.T) {
+	h := newTestHarness(t)
+
+	query := url.QueryEscape(`query Catalog { products(limit: 3) { id reviews { rating } inventory } }`)
+	if rec := h.do(http.MethodGet, "/graphql?query="+query, nil); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `"errors"`) {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	operation := h.span("query Catalog")
+	if operation.Parent.SpanID() != h.span("/graphql").SpanContext.SpanID() {
+		t.Error("operation span is not a child of the request span")
+	}
+	products := h.span("Query.products")
+	if products.Parent.SpanID() != operation.SpanContext.SpanID() {
+		t.Error("Query.products is not a child of the operation span")
+	}
+	resolvers := map[string]int{}
+	for _, s := range h.spans.GetSpans() {
+		if path, ok := spanAttr(s.Attributes, "graphql.field.path"); ok {
+			resolvers[s.Name]++
+			if strings.HasPrefix(s.Name, "Product.") && s.Parent.SpanID() != products.SpanContext.SpanID() {
+				t.Errorf("%s (%s) is not a child of Query.products", s.Name, path.AsString())
+			}
+		}
+	}
+	// One span per product for each resolver field; id and rating are plain struct fields
+	if want := map[string]int{"Query.products": 1, "Product.reviews": 3, "Product.inventory": 3}; !reflect.DeepEqual(resolvers, want) {
+		t.Errorf("resolver spans = %v, want %v", resolvers, want)
+	}
+
+	h.spans.Reset()
+	rec := h.do(http.MethodGet, "/graphql?query="+url.QueryEscape(`{ failing }`), nil)
+	if !strings.Contains(rec.Body.String(), `"path":["failing"]`) {
+		t.Fatalf("failing field: body = %s", rec.Body)
+	}
+	if s := h.span("Query.failing"); s.Status.Code != codes.Error || len(s.Events) == 0 {
+		t.Errorf("resolver span status = %v, events = %v, want error recorded", s.Status, s.Events)
+	}
+	if s := h.span("query"); s.Status.Code != codes.Error {
+		t.Errorf("operation span status = %v, want error", s.Status)
+	}
+}
+
+func TestHeartbeatReportsWithoutTraffic(t *testing.T) {
+	h := newTestHarness(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	before := testutil.ToFloat64(heartbeatsTotal)
+	startHeartbeat(ctx, heartbeatConfig{Interval: time.Hour, Span: true})
+	if testutil.ToFloat64(heartbeatsTotal) != before+1 {
+		t.Error("no heartbeat on start")
+	}
+	if ts := testutil.ToFloat64(heartbeatTimestamp); time.Since(time.Unix(int64(ts), 0)) > time.Minute {
+		t.Errorf("app_heartbeat_timestamp_seconds = %v, want now", ts)
+	}
+	if v := testutil.ToFloat64(heartbeatInterval); v != 3600 {
+		t.Errorf("app_heartbeat_interval_seconds = %v, want 3600", v)
+	}
+	span := h.span("heartbeat")
+	if span.Parent.IsValid() {
+		t.Error("heartbeat span should be a root span")
+	}
+	entries := h.logEntries("Heartbeat")
+	if len(entries) != 1 || entries[0]["fields"].(map[string]interface{})["trace_id"] != span.SpanContext.TraceID().String() {
+		t.Errorf("heartbeat logs = %v, want one linked to the span", entries)
+	}
+}
+
+func TestWatchdogReportsBlockedRequestsAndGoroutineGrowth(t *testing.T) {
+	h := newTestHarness(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodGet, "/slow?ms=60000", nil).WithContext(ctx)
+		h.router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	defer func() { cancel(); <-done }()
+	for deadline := time.Now().Add(5 * time.Second); longestInFlight() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("request never showed up in flight")
+		}
+	}
+
+	w := newWatchdog(watchdogConfig{GoroutineThreshold: 1 << 20, GoroutineGrowth: 50, RequestThreshold: time.Nanosecond, DumpBytes: 1 << 20})
+	w.check()
+	w.check()
+	entries := h.logEntries("Request blocked past watchdog threshold")
+	if len(entries) != 1 {
+		t.Fatalf("want one blocked request warning across two checks, got %d", len(entries))
+	}
+	fields := entries[0]["fields"].(map[string]interface{})
+	if fields["route"] != "/slow" || fields["request_id"] == nil {
+		t.Errorf("blocked request fields = %v", fields)
+	}
+	if dump, _ := fields["goroutine_dump"].(string); !strings.Contains(dump, ".slowHandler") {
+		t.Errorf("dump does not show the blocked handler:\n%s", dump)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	for i := 0; i < 60; i++ {
+		go func() { <-release }()
+	}
+	w.check()
+	entries = h.logEntries("Goroutine leak suspected")
+	if len(entries) != 1 || entries[0]["fields"].(map[string]interface{})["reason"] != "goroutine_growth" {
+		t.Errorf("goroutine warnings = %v, want one for growth", entries)
+	}
+}
+
+func TestScenarioCrossesServicesRepeatably(t *testing.T) {
+	h := newTestHarness(t)
+	var calls atomic.Int64
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer downstream.Close()
+	for _, target := range []*outboundTarget{nodeTarget, elixirTarget} {
+		prev := target.BaseURL
+		target.BaseURL = downstream.URL
+		t.Cleanup(func() { target.BaseURL = prev })
+	}
+
+	run := func() ScenarioResponse {
+		rec := h.do(http.MethodGet, "/scenario/checkout?seed=7", nil)
+		var resp ScenarioResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v (%s)", err, rec.Body.String())
+		}
+		return resp
+	}
+	first := run()
+	if len(first.Steps) == 0 || first.Steps[0].Step != "cart.load" {
+		t.Fatalf("steps = %+v", first.Steps)
+	}
+	span := h.span("scenario.checkout")
+	if first.TraceID != span.SpanContext.TraceID().String() {
+		t.Errorf("trace_id = %s, want the scenario span's", first.TraceID)
+	}
+	if s := h.span("pricing.quote"); s.Parent.SpanID() != span.SpanContext.SpanID() {
+		t.Error("step span is not a child of the scenario span")
+	}
+	if v, _ := spanAttr(span.Attributes, "scenario.name"); v.AsString() != "checkout" {
+		t.Errorf("scenario span attributes = %v", span.Attributes)
+	}
+	if v, _ := spanAttr(span.Attributes, "user.tier"); v.AsString() != "gold" || first.Baggage["scenario.name"] != "checkout" {
+		t.Errorf("scenario baggage = %v, span attributes = %v", first.Baggage, span.Attributes)
+	}
+	if n := calls.Load(); n < 2 {
+		t.Errorf("downstream calls = %d, want the pricing and payment steps", n)
+	}
+
+	second := run()
+	if second.Status != first.Status || !reflect.DeepEqual(stepOutcomes(second), stepOutcomes(first)) {
+		t.Errorf("same seed gave %+v then %+v", first.Steps, second.Steps)
+	}
+	if rec := h.do(http.MethodGet, "/scenario/nope", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown scenario status = %d, want 404", rec.Code)
+	}
+}
+
+// stepOutcomes strips timings so runs can be compared
+func stepOutcomes(resp ScenarioResponse) []ScenarioStepResult {
+	out := make([]ScenarioStepResult, len(resp.Steps))
+	for i, s := range resp.Steps {
+		s.DurationMs = 0
+		out[i] = s
+	}
+	return out
+}
+
+func TestRouterRebuildsShareTheRateLimiter(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPS", "1000")
+	t.Setenv("RATE_LIMIT_PER_IP_RPS", "1000")
+	h := newTestHarness(t)
+	defer initRateLimiter().update(rateLimitConfig{})
+	newRouter()
+	if rec := h.do(http.MethodGet, "/", nil); rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+	families, err := obs.Registry.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	exported := map[string]bool{}
+	for _, mf := range families {
+		exported[mf.GetName()] = true
+	}
+	if !exported["rate_limiter_tokens"] || !exported["rate_limiter_tracked_clients"] {
+		t.Error("rate limiter gauges are not exported")
+	}
+}
+
+func TestClosedOrderServiceRejectsOrders(t *testing.T) {
+	s := newOrderService()
+	if appErr := s.enqueue(context.Background(), OrderCreated{ID: 1}); appErr != nil {
+		t.Fatalf("enqueue before close = %v", appErr.Code)
+	}
+	s.close()
+	s.close()
+	appErr := s.enqueue(context.Background(), OrderCreated{ID: 2})
+	if appErr == nil || appErr.Code != "order_queue_closed" {
+		t.Errorf("enqueue after close = %v, want order_queue_closed", appErr)
+	}
+}
+
+func TestRateLimitConfigAcceptsFractionalRates(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPS", "0.5")
+	t.Setenv("RATE_LIMIT_PER_IP_RPS", "0.25")
+	if cfg := loadRateLimitConfig(); cfg.GlobalRPS != 0.5 || cfg.ClientRPS != 0.25 {
+		t.Errorf("rates = %v and %v, want 0.5 and 0.25", cfg.GlobalRPS, cfg.ClientRPS)
+	}
+}
+
+func TestBreakerIgnoresCancelledCalls(t *testing.T) {
+	b := newCircuitBreaker("cancel-test", breakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenMax: 1})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cancelled := &url.Error{Op: "Get", URL: "http://target", Err: context.Canceled}
+	for i := 0; i < 3; i++ {
+		b.Do(ctx, func() error { return cancelled })
+	}
+	if b.state != breakerClosed {
+		t.Fatalf("state after cancelled calls = %v, want closed", b.state)
+	}
+	b.Do(context.Background(), func() error { return errors.New("connection refused") })
+	if b.state != breakerOpen {
+		t.Errorf("state after a real failure = %v, want open", b.state)
+	}
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	h := newTestHarness(t)
+	t.Setenv("INTERNAL_BASIC_AUTH_USER", "ops")
+	t.Setenv("INTERNAL_BASIC_AUTH_PASSWORD", "secret")
+
+	for name, r := range map[string]*mux.Router{"public": newRouter(), "internal": newInternalRouter(loadInternalConfig())} {
+		doc, err := buildOpenAPI(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for path, ops := range doc.Paths {
+			for method, op := range ops {
+				if op.Summary == "" {
+					t.Errorf("%s router: %s %s has no apiRoutes entry", name, strings.ToUpper(method), path)
+				}
+			}
+		}
+	}
+
+	rec := h.do(http.MethodGet, "/openapi.json", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json status = %d", rec.Code)
+	}
+	var doc OpenAPIDocument
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	getUser := doc.Paths["/users/{id}"]["get"]
+	if len(getUser.Parameters) != 1 || getUser.Parameters[0].Name != "id" || getUser.Parameters[0].In != "path" {
+		t.Errorf("GET /users/{id} parameters = %+v", getUser.Parameters)
+	}
+	if _, ok := doc.Paths["/docs"]; !ok {
+		t.Error("the Swagger UI route is missing from the document")
+	}
+}
+
+func TestH2CRequestsAreLabelledByProtocol(t *testing.T) {
+	h := newTestHarness(t)
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config = newServer(serverConfig{H2C: true}, h.router)
+	srv.Start()
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}}
+	resp, err := client.Get(srv.URL + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("response protocol = %s, want HTTP/2", resp.Proto)
+	}
+
+	if v, _ := spanAttr(h.span("/health").Attributes, "http.protocol"); v.AsString() != "h2c" {
+		t.Errorf("http.protocol = %q, want h2c", v.AsString())
+	}
+	labels := map[string]string{"method": "GET", "endpoint": "/health", "status": "200", "protocol": "h2c", "tenant": "none"}
+	if got := promCounter(t, "http_requests_total", labels); got < 1 {
+		t.Errorf("http_requests_total%v = %v, want >= 1", labels, got)
+	}
+}
+
+func TestFeatureFlagsGateBehaviorAndRecordEvaluations(t *testing.T) {
+	t.Setenv("FEATURE_FLAGS", "root-error-injection=true,fanout-strategy=sequential")
+	h := newTestHarness(t)
+	t.Cleanup(func() {
+		os.Unsetenv("FEATURE_FLAGS")
+		featureFlags.reload()
+	})
+
+	if rec := h.do(http.MethodGet, "/", nil); rec.Code != http.StatusInternalServerError {
+		t.Errorf("GET / status = %d, want 500 with root-error-injection on", rec.Code)
+	}
+	if rec := h.do(http.MethodGet, "/fanout?n=1", nil); rec.Code != http.StatusOK {
+		t.Fatalf("GET /fanout status = %d, want 200", rec.Code)
+	}
+
+	fanout := h.span("/fanout")
+	if v, ok := spanAttr(fanout.Attributes, "feature_flag.fanout-strategy"); !ok || v.AsString() != "sequential" {
+		t.Errorf("feature_flag.fanout-strategy = %v, want sequential", v.AsString())
+	}
+	if len(fanout.Events) == 0 || fanout.Events[0].Name != "feature_flag" {
+		t.Fatalf("fanout span events = %v, want a feature_flag event first", fanout.Events)
+	}
+	if v, ok := spanAttr(fanout.Events[0].Attributes, "feature_flag.provider_name"); !ok || v.AsString() != flagProviderName {
+		t.Errorf("feature_flag.provider_name = %v, want %s", v.AsString(), flagProviderName)
+	}
+
+	got := promCounter(t, "feature_flag_evaluations_total", map[string]string{
+		"flag": flagRootErrorInjection, "variant": "on", "reason": "static",
+	})
+	if got < 1 {
+		t.Errorf("feature_flag_evaluations_total{flag=%q,variant=on} = %v, want >= 1", flagRootErrorInjection, got)
+	}
+}
+
+func TestChaosModeInjectsErrorsUntilStopped(t *testing.T) {
+	h := newTestHarness(t)
+	startChaos(ChaosRequest{ErrorRate: 1}, time.Minute)
+	t.Cleanup(func() { stopChaos(activeChaos.Load(), "test") })
+
+	if rec := h.do(http.MethodGet, "/", nil); rec.Code < 500 {
+		t.Fatalf("GET / status = %d, want a 5xx while chaos is active", rec.Code)
+	}
+	if rec := h.do(http.MethodGet, "/health", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET /health status = %d, want 200; health checks are exempt", rec.Code)
+	}
+	if v, ok := spanAttr(h.span("/").Attributes, "chaos.injected"); !ok || !v.AsBool() {
+		t.Error("span is missing chaos.injected=true")
+	}
+	if got := promCounter(t, "chaos_injections_total", map[string]string{"kind": "error"}); got < 1 {
+		t.Errorf("chaos_injections_total{kind=error} = %v, want >= 1", got)
+	}
+
+	if !stopChaos(activeChaos.Load(), "test") {
+		t.Fatal("stopChaos found no active session")
+	}
+	if rec := h.do(http.MethodGet, "/", nil); rec.Code != http.StatusOK {
+		t.Errorf("GET / status = %d after stopping chaos, want 200", rec.Code)
+	}
+}
+
+func TestOrdersRecordOTelInstruments(t *testing.T) {
+	h := newTestHarness(t)
+
+	body := strings.NewReader(`{"item":"book","quantity":2,"unit_price":10,"payment_method":"paypal"}`)
+	req := httptest.NewRequest(http.MethodPost, "/orders", body)
+	rec := httptest.NewRecorder()
+	h.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", rec.Code, rec.Body)
+	}
+
+	found := map[string]bool{}
+	for _, sm := range h.collectMetrics().ScopeMetrics {
+		for _, m := range sm.Metrics {
+			found[m.Name] = true
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				if m.Name == "orders_created" && (len(data.DataPoints) != 1 || data.DataPoints[0].Value != 1) {
+					t.Errorf("orders_created = %v, want one point of 1", data.DataPoints)
+				}
+			case metricdata.Histogram[float64]:
+				if m.Name == "order_value" && (len(data.DataPoints) != 1 || data.DataPoints[0].Sum != 20) {
+					t.Errorf("order_value = %v, want one point summing to 20", data.DataPoints)
+				}
+			}
+		}
+	}
+	for _, name := range []string{"orders_created", "order_value", "queue_depth"} {
+		if !found[name] {
+			t.Errorf("%s not collected", name)
+		}
+	}
+}
+
+func TestCompressionNegotiatesDeflateAsZlib(t *testing.T) {
+	h := compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "deflate")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if enc := rec.Header().Get("Content-Encoding"); enc != "deflate" {
+		t.Fatalf("Content-Encoding = %q, want deflate", enc)
+	}
+	zr, err := zlib.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("body is not zlib: %v", err)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(zr).Decode(&body); err != nil || body["status"] != "ok" {
+		t.Errorf("decoded body = %v (%v)", body, err)
+	}
+}
+
+func TestTimeoutReplacesCompressedResponseWithPlainProblem(t *testing.T) {
+	h := middleware.Timeout(middleware.TimeoutOptions{Default: 20 * time.Millisecond})(
+		compressionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(50 * time.Millisecond)
+			writeJSON(w, http.StatusOK, map[string]string{"status": "late"})
+		})))
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", rec.Code)
+	}
+	if enc := rec.Header().Get("Content-Encoding"); enc != "" {
+		t.Errorf("Content-Encoding = %q on an uncompressed body", enc)
+	}
+	var problem middleware.ProblemDetails
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil || problem.Code != "request_timeout" {
+		t.Errorf("problem = %+v (%v), want code request_timeout", problem, err)
+	}
+}
+
+func TestWebSocketEchoIsMeasured(t *testing.T) {
+	h := newTestHarness(t)
+	srv := httptest.NewServer(h.router)
+	defer srv.Close()
+
+	before := promCounter(t, "websocket_messages_total", map[string]string{"direction": "received"})
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/echo", http.Header{"Accept-Encoding": {"gzip"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "hello" {
+		t.Fatalf("echo = %q, %v", msg, err)
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.Close()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(h.logEntries("WebSocket disconnected")) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := promCounter(t, "websocket_messages_total", map[string]string{"direction": "received"}); got != before+1 {
+		t.Errorf("websocket_messages_total{received} = %v, want %v", got, before+1)
+	}
+	if logs := h.logEntries("WebSocket disconnected"); len(logs) != 1 || logs[0]["level"] != "INFO" {
+		t.Errorf("want one INFO disconnect log, got %v", logs)
+	}
+}
+
+func TestReadyzFailsWhileDraining(t *testing.T) {
+	h := newTestHarness(t)
+	if rec := h.do(http.MethodGet, "/readyz", nil); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	draining.Store(true)
+	t.Cleanup(func() { draining.Store(false) })
+	if rec := h.do(http.MethodGet, "/readyz", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status while draining = %d, want 503", rec.Code)
+	}
+	if rec := h.do(http.MethodGet, "/health", nil); rec.Code != http.StatusOK {
+		t.Errorf("/health while draining = %d, want 200", rec.Code)
+	}
+}
+
+func TestShutdownWaitsForBackgroundWork(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var finished atomic.Bool
+	goBackground(func() {
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		finished.Store(true)
+	})
+	stopBackground(cancel, 5*time.Second)
+	if !finished.Load() {
+		t.Error("stopBackground returned before the background goroutine finished")
+	}
+
+	srv := serveGRPC("127.0.0.1:0")
+	if srv == nil {
+		t.Fatal("gRPC server did not start")
+	}
+	stopGRPC(srv, 5*time.Second)
+}
+
+func TestRedirectServerIsShutDownWithTheServer(t *testing.T) {
+	if srv := newRedirectServer(serverConfig{Addr: ":8443", RedirectAddr: ":8080"}); srv != nil {
+		t.Error("redirect server created without TLS")
+	}
+	cfg := serverConfig{Addr: ":8443", CertFile: "cert.pem", KeyFile: "key.pem", RedirectAddr: "127.0.0.1:0"}
+	redirect := newRedirectServer(cfg)
+	if redirect == nil {
+		t.Fatal("no redirect server with TLS and HTTPS_REDIRECT_ADDR set")
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- redirect.ListenAndServe() }()
+	time.Sleep(20 * time.Millisecond)
+	if err := redirect.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-serveErr; err != http.ErrServerClosed {
+		t.Errorf("redirect listener returned %v, want ErrServerClosed", err)
+	}
+}
+
+func TestInternalRoutesMoveToDedicatedListener(t *testing.T) {
+	t.Setenv("INTERNAL_ADDR", ":0")
+	t.Setenv("INTERNAL_BASIC_AUTH_USER", "ops")
+	t.Setenv("INTERNAL_BASIC_AUTH_PASSWORD", "pw")
+	h := newTestHarness(t)
+	if rec := h.do(http.MethodGet, "/metrics", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("public /metrics = %d, want 404", rec.Code)
+	}
+
+	internal := newInternalRouter(loadInternalConfig())
+	for _, tt := range []struct {
+		user, password string
+		want           int
+	}{{"", "", http.StatusUnauthorized}, {"ops", "wrong", http.StatusUnauthorized}, {"ops", "pw", http.StatusOK}} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, tt.password)
+		}
+		rec := httptest.NewRecorder()
+		internal.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("internal /metrics as %q = %d, want %d", tt.user, rec.Code, tt.want)
+		}
+	}
+}
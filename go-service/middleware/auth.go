@@ -0,0 +1,229 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// AuthOptions enables API-key and/or HS256 JWT bearer authentication. With neither
+// APIKeys nor JWTSecret set, Auth is a no-op.
+type AuthOptions struct {
+	APIKeys     map[string]string // API key -> subject reported in logs and spans
+	JWTSecret   []byte
+	JWTIssuer   string // required "iss" when set
+	JWTAudience string // required in "aud" when set
+	Public      []string
+	Registerer  prometheus.Registerer
+}
+
+// authError is a rejection reason used as the auth_failures_total label
+type authError string
+
+func (e authError) Error() string { return string(e) }
+
+const (
+	errMissingCredentials authError = "missing_credentials"
+	errInvalidAPIKey      authError = "invalid_api_key"
+	errMalformedToken     authError = "malformed_token"
+	errUnsupportedAlg     authError = "unsupported_algorithm"
+	errInvalidSignature   authError = "invalid_signature"
+	errTokenExpired       authError = "expired"
+	errTokenNotYetValid   authError = "not_yet_valid"
+	errInvalidIssuer      authError = "invalid_issuer"
+	errInvalidAudience    authError = "invalid_audience"
+)
+
+// principal is the authenticated caller; only non-sensitive claims are kept
+type principal struct {
+	Method  string
+	Subject string
+	Issuer  string
+	Scope   string
+}
+
+// Auth rejects requests to non-public routes without a valid X-API-Key header or
+// Authorization: Bearer JWT. Failures return 401 and are counted in auth_failures_total
+// by reason; the outcome is recorded on the span and the subject in the request logs.
+func Auth(opts AuthOptions) Middleware {
+	if len(opts.APIKeys) == 0 && len(opts.JWTSecret) == 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	if opts.Registerer == nil {
+		opts.Registerer = obs.Registry
+	}
+	failures := obs.RegisterOrExisting(opts.Registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_failures_total",
+			Help: "Total number of requests rejected by authentication",
+		},
+		[]string{"reason"},
+	))
+	public := make(map[string]bool, len(opts.Public))
+	for _, route := range opts.Public {
+		public[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if public[RouteTemplate(r)] || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			span := trace.SpanFromContext(r.Context())
+			p, err := opts.authenticate(r)
+			if err != nil {
+				reason := "unknown"
+				var ae authError
+				if errors.As(err, &ae) {
+					reason = string(ae)
+				}
+				failures.WithLabelValues(reason).Inc()
+				span.SetAttributes(
+					attribute.String("auth.outcome", "failure"),
+					attribute.String("auth.failure_reason", reason),
+				)
+				AddLogFields(r.Context(), map[string]interface{}{"auth_outcome": "failure", "auth_failure_reason": reason})
+				obs.WarnContext(r.Context(), "Authentication failed", map[string]interface{}{
+					"reason":      reason,
+					"method":      r.Method,
+					"path":        r.URL.Path,
+					"remote_addr": r.RemoteAddr,
+				})
+				w.Header().Set("WWW-Authenticate", `Bearer realm="go-service"`)
+				WriteProblem(w, r, Problem{Status: http.StatusUnauthorized, Code: "unauthorized", Message: "unauthorized"})
+				return
+			}
+
+			attrs := []attribute.KeyValue{
+				attribute.String("auth.outcome", "success"),
+				attribute.String("auth.method", p.Method),
+				attribute.String("enduser.id", p.Subject),
+			}
+			fields := map[string]interface{}{
+				"auth_outcome": "success",
+				"auth_method":  p.Method,
+				"auth_subject": p.Subject,
+			}
+			if p.Scope != "" {
+				attrs = append(attrs, attribute.String("enduser.scope", p.Scope))
+				fields["auth_scope"] = p.Scope
+			}
+			if p.Issuer != "" {
+				fields["auth_issuer"] = p.Issuer
+			}
+			span.SetAttributes(attrs...)
+			AddLogFields(r.Context(), fields)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (o AuthOptions) authenticate(r *http.Request) (principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" && len(o.APIKeys) > 0 {
+		for candidate, subject := range o.APIKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+				return principal{Method: "api_key", Subject: subject}, nil
+			}
+		}
+		return principal{}, errInvalidAPIKey
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && len(o.JWTSecret) > 0 {
+		return o.verifyJWT(strings.TrimSpace(token), time.Now())
+	}
+	return principal{}, errMissingCredentials
+}
+
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+	Scope     string          `json:"scope"`
+}
+
+// verifyJWT checks an HS256 token's signature, exp/nbf and the configured iss/aud
+func (o AuthOptions) verifyJWT(token string, now time.Time) (principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return principal{}, errMalformedToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return principal{}, errMalformedToken
+	}
+	if header.Alg != "HS256" {
+		return principal{}, errUnsupportedAlg
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return principal{}, errMalformedToken
+	}
+	mac := hmac.New(sha256.New, o.JWTSecret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return principal{}, errInvalidSignature
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return principal{}, errMalformedToken
+	}
+	unix := float64(now.Unix())
+	if claims.ExpiresAt != nil && unix >= *claims.ExpiresAt {
+		return principal{}, errTokenExpired
+	}
+	if claims.NotBefore != nil && unix < *claims.NotBefore {
+		return principal{}, errTokenNotYetValid
+	}
+	if o.JWTIssuer != "" && claims.Issuer != o.JWTIssuer {
+		return principal{}, errInvalidIssuer
+	}
+	if o.JWTAudience != "" && !audienceContains(claims.Audience, o.JWTAudience) {
+		return principal{}, errInvalidAudience
+	}
+	return principal{Method: "jwt", Subject: claims.Subject, Issuer: claims.Issuer, Scope: claims.Scope}, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// audienceContains handles "aud" as either a string or a list of strings
+func audienceContains(raw json.RawMessage, want string) bool {
+	var single string
+	if json.Unmarshal(raw, &single) == nil {
+		return single == want
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) == nil {
+		for _, aud := range list {
+			if aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// Baggage copies allowlisted baggage members (BAGGAGE_LOG_KEYS) from the incoming
+// request onto the server span. It must run inside Tracing, which extracts the baggage.
+func Baggage() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attrs := obs.BaggageAttributes(r.Context()); len(attrs) > 0 {
+				trace.SpanFromContext(r.Context()).SetAttributes(attrs...)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"go-service/obs"
+)
+
+// DebugTraceOptions configures DebugTrace; Header defaults to X-Debug-Trace. Only requests
+// whose header carries exactly Token are debugged, so ordinary clients cannot force every
+// request into the tracing backend; without a Token nothing is.
+type DebugTraceOptions struct {
+	Header string
+	Token  string
+}
+
+// DebugTrace forces sampling and DEBUG logging for requests that ask for it (see
+// obs.WithDebug). It must run before Tracing so the server span is sampled too; the
+// span's sampling.debug attribute marks forced traces.
+func DebugTrace(opts DebugTraceOptions) Middleware {
+	header := opts.Header
+	if header == "" {
+		header = "X-Debug-Trace"
+	}
+	return func(next http.Handler) http.Handler {
+		if opts.Token == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get(header)), []byte(opts.Token)) == 1 {
+				r = r.WithContext(obs.WithDebug(r.Context()))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
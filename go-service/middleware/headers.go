@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"go-service/internal/env"
+	"go-service/obs"
+)
+
+// alwaysExcludedHeaders are never captured, whatever the allowlist says
+var alwaysExcludedHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-admin-token":       true,
+	"x-api-key":           true,
+}
+
+var (
+	capturedRequestHeaders  = headerAllowlist(env.List("OTEL_INSTRUMENTATION_HTTP_CAPTURE_HEADERS_SERVER_REQUEST", ""))
+	capturedResponseHeaders = headerAllowlist(env.List("OTEL_INSTRUMENTATION_HTTP_CAPTURE_HEADERS_SERVER_RESPONSE", ""))
+)
+
+// headerAllowlist normalises header names, dropping sensitive ones
+func headerAllowlist(allowlist []string) []string {
+	var names []string
+	for _, name := range allowlist {
+		name = strings.ToLower(name)
+		if alwaysExcludedHeaders[name] || obs.IsSensitive(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// captureHeaders returns the allowlisted headers present in h as log fields and
+// span attributes named http.{request,response}.header.<name>
+func captureHeaders(direction string, allowlist []string, h http.Header) (map[string]interface{}, []attribute.KeyValue) {
+	if len(allowlist) == 0 {
+		return nil, nil
+	}
+	fields := make(map[string]interface{})
+	var attrs []attribute.KeyValue
+	for _, name := range allowlist {
+		values := h.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		fields[name] = strings.Join(values, ", ")
+		attrs = append(attrs, attribute.StringSlice("http."+direction+".header."+name, values))
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, attrs
+}
+
+// queryFields flattens query parameters into log fields so they pass through redaction
+func queryFields(values url.Values) map[string]interface{} {
+	fields := make(map[string]interface{}, len(values))
+	for k, vs := range values {
+		if len(vs) == 1 {
+			fields[k] = vs[0]
+		} else {
+			fields[k] = vs
+		}
+	}
+	return fields
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IdentityHeader maps a request header to the key used for the span attribute, the
+// baggage member and the log field, e.g. X-User-Id -> enduser.id
+type IdentityHeader struct {
+	Header string
+	Key    string
+}
+
+// IdentityOptions configures Identity. With Hash set, values are replaced by a truncated
+// SHA-256 (an HMAC when HashKey is set) so users can be correlated without being named.
+type IdentityOptions struct {
+	Headers []IdentityHeader
+	Hash    bool
+	HashKey string
+}
+
+type identityKey struct{}
+
+// Identity copies the configured identity headers onto the server span, into the request
+// baggage (so outbound calls carry them to the next service) and into the request logs
+// under "identity". Malformed or oversized values are ignored. It must run inside Tracing.
+func Identity(opts IdentityOptions) Middleware {
+	return func(next http.Handler) http.Handler {
+		if len(opts.Headers) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			var fields map[string]interface{}
+			bag := baggage.FromContext(ctx)
+			span := trace.SpanFromContext(ctx)
+			for _, h := range opts.Headers {
+				value := r.Header.Get(h.Header)
+				if !validRequestID(value) { // same printable, length-bounded rule as request IDs
+					continue
+				}
+				if opts.Hash {
+					value = hashIdentity(opts.HashKey, value)
+				}
+				if fields == nil {
+					fields = make(map[string]interface{}, len(opts.Headers))
+				}
+				fields[h.Key] = value
+				span.SetAttributes(attribute.String(h.Key, value))
+				if m, err := baggage.NewMemberRaw(h.Key, value); err == nil {
+					if b, err := bag.SetMember(m); err == nil {
+						bag = b
+					}
+				}
+			}
+			if fields != nil {
+				ctx = baggage.ContextWithBaggage(context.WithValue(ctx, identityKey{}, fields), bag)
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IdentityFields returns the identity values set by Identity, or nil
+func IdentityFields(ctx context.Context) map[string]interface{} {
+	fields, _ := ctx.Value(identityKey{}).(map[string]interface{})
+	return fields
+}
+
+func hashIdentity(key, value string) string {
+	var sum []byte
+	if key != "" {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(value))
+		sum = mac.Sum(nil)
+	} else {
+		h := sha256.Sum256([]byte(value))
+		sum = h[:]
+	}
+	return hex.EncodeToString(sum[:16])
+}
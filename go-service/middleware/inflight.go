@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InFlightRequest is a request still being served by a Metrics middleware
+type InFlightRequest struct {
+	ID        uint64    `json:"id"`
+	Method    string    `json:"method"`
+	Route     string    `json:"route"`
+	RequestID string    `json:"request_id,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty"`
+	Start     time.Time `json:"start"`
+}
+
+var (
+	// inFlight counts requests currently inside any Metrics middleware
+	inFlight atomic.Int64
+
+	inFlightMu   sync.Mutex
+	inFlightSeq  uint64
+	inFlightReqs = map[uint64]*InFlightRequest{}
+)
+
+// InFlightRequests returns the number of requests currently being served
+func InFlightRequests() int64 {
+	return inFlight.Load()
+}
+
+// InFlight returns the requests currently being served, oldest first
+func InFlight() []InFlightRequest {
+	inFlightMu.Lock()
+	reqs := make([]InFlightRequest, 0, len(inFlightReqs))
+	for _, req := range inFlightReqs {
+		reqs = append(reqs, *req)
+	}
+	inFlightMu.Unlock()
+	sort.Slice(reqs, func(i, j int) bool { return reqs[i].ID < reqs[j].ID })
+	return reqs
+}
+
+// trackInFlight registers r until the returned func is called
+func trackInFlight(r *http.Request) (done func()) {
+	req := &InFlightRequest{
+		Method:    r.Method,
+		Route:     RouteTemplate(r),
+		RequestID: RequestIDFromContext(r.Context()),
+		Start:     time.Now(),
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		req.TraceID = sc.TraceID().String()
+	}
+	inFlight.Add(1)
+	inFlightMu.Lock()
+	inFlightSeq++
+	req.ID = inFlightSeq
+	inFlightReqs[req.ID] = req
+	inFlightMu.Unlock()
+	return func() {
+		inFlightMu.Lock()
+		delete(inFlightReqs, req.ID)
+		inFlightMu.Unlock()
+		inFlight.Add(-1)
+	}
+}
+
+// serveLabelled runs the handler with pprof labels naming its route and request ID, so a
+// goroutine dump attributes a blocked handler, and any goroutine it started, to a request
+func serveLabelled(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	labels := []string{"http.route", RouteTemplate(r)}
+	if id := RequestIDFromContext(r.Context()); id != "" {
+		labels = append(labels, "request_id", id)
+	}
+	pprof.Do(r.Context(), pprof.Labels(labels...), func(ctx context.Context) {
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// LoggingOptions configures Logging; nil header lists fall back to the
+// OTEL_INSTRUMENTATION_HTTP_CAPTURE_HEADERS_SERVER_* variables
+type LoggingOptions struct {
+	RequestHeaders  []string
+	ResponseHeaders []string
+}
+
+type logFieldsKey struct{}
+
+// requestLogFields collects fields added by inner middleware and handlers for the completion log
+type requestLogFields struct {
+	mu     sync.Mutex
+	fields map[string]interface{}
+}
+
+// AddLogFields adds fields to the "HTTP request completed" log of the request in ctx.
+// It is a no-op outside Logging.
+func AddLogFields(ctx context.Context, fields map[string]interface{}) {
+	holder, ok := ctx.Value(logFieldsKey{}).(*requestLogFields)
+	if !ok {
+		return
+	}
+	holder.mu.Lock()
+	defer holder.mu.Unlock()
+	for k, v := range fields {
+		holder.fields[k] = v
+	}
+}
+
+// Logging logs each request on arrival and completion, at WARN for 4xx and ERROR for 5xx.
+// Allowlisted headers are added to both the log fields and the active span, and
+// allowlisted baggage members (BAGGAGE_LOG_KEYS) and Identity values to the log fields.
+// Requests whose logs are quiet (see Quiet) are not logged; handlers still log as usual.
+func Logging(opts LoggingOptions) Middleware {
+	requestHeaders := capturedRequestHeaders
+	if opts.RequestHeaders != nil {
+		requestHeaders = headerAllowlist(opts.RequestHeaders)
+	}
+	responseHeaders := capturedResponseHeaders
+	if opts.ResponseHeaders != nil {
+		responseHeaders = headerAllowlist(opts.ResponseHeaders)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if obs.Quiet(r.Context()).Logs {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			requestID := RequestIDFromContext(r.Context())
+
+			// Log incoming request; query parameters pass through the redactor
+			fields := map[string]interface{}{
+				"remote_addr":    r.RemoteAddr,
+				"client_address": ClientAddress(r),
+				"method":         r.Method,
+				"path":           r.URL.Path,
+				"scheme":         Scheme(r),
+				"user_agent":     r.UserAgent(),
+			}
+			if requestID != "" {
+				fields["request_id"] = requestID
+			}
+			tenant := TenantFromContext(r.Context())
+			if tenant != "" {
+				fields["tenant"] = tenant
+			}
+			identity := IdentityFields(r.Context())
+			if identity != nil {
+				fields["identity"] = identity
+			}
+			if r.URL.RawQuery != "" {
+				fields["query"] = queryFields(r.URL.Query())
+			}
+			span := trace.SpanFromContext(r.Context())
+			if headers, attrs := captureHeaders("request", requestHeaders, r.Header); headers != nil {
+				fields["request_headers"] = headers
+				span.SetAttributes(attrs...)
+			}
+			obs.InfoContext(r.Context(), "Incoming HTTP request", fields)
+
+			extra := &requestLogFields{fields: map[string]interface{}{}}
+			wrapped := newStatusRecorder(w)
+			next.ServeHTTP(wrapped, r.WithContext(context.WithValue(r.Context(), logFieldsKey{}, extra)))
+
+			statusCode := wrapped.statusCode
+			logFunc := obs.InfoContext
+			if statusCode >= 500 {
+				logFunc = obs.ErrorContext
+			} else if statusCode >= 400 {
+				logFunc = obs.WarnContext
+			}
+
+			completed := map[string]interface{}{
+				"remote_addr":      r.RemoteAddr,
+				"client_address":   ClientAddress(r),
+				"method":           r.Method,
+				"path":             r.URL.Path,
+				"scheme":           Scheme(r),
+				"status":           statusCode,
+				"duration_seconds": time.Since(start).Seconds(),
+			}
+			if requestID != "" {
+				completed["request_id"] = requestID
+			}
+			if tenant != "" {
+				completed["tenant"] = tenant
+			}
+			if identity != nil {
+				completed["identity"] = identity
+			}
+			extra.mu.Lock()
+			for k, v := range extra.fields {
+				if _, exists := completed[k]; !exists {
+					completed[k] = v
+				}
+			}
+			extra.mu.Unlock()
+			if headers, attrs := captureHeaders("response", responseHeaders, wrapped.Header()); headers != nil {
+				completed["response_headers"] = headers
+				span.SetAttributes(attrs...)
+			}
+			logFunc(r.Context(), "HTTP request completed", completed)
+		})
+	}
+}
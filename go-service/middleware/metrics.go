@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// MetricsOptions configures Metrics; zero values use obs.Registry and the default buckets.
+// SizeBuckets are the body size buckets in bytes, 64B to 1MiB by default.
+type MetricsOptions struct {
+	Registerer  prometheus.Registerer
+	Buckets     []float64
+	SizeBuckets []float64
+}
+
+// Metrics records http_requests_total, http_request_duration_seconds and
+// http_requests_in_flight, labelled by route template to keep /users/{id} bounded and by
+// negotiated protocol (http/1.1, h2, h2c) and by the bounded tenant label set by Tenant.
+// Durations of sampled requests carry trace_id and client_address exemplars.
+// Request bytes read by the handler and response bytes written are recorded in
+// http_request_body_size_bytes / http_response_body_size_bytes by route and set as the
+// http.request.body.size / http.response.body.size span attributes.
+// Requests whose metrics are quiet (see Quiet) are only counted in flight.
+// Every request is listed by InFlight while it runs, and its handler carries http.route and
+// request_id pprof labels.
+// Several Metrics middlewares sharing a registerer share the same series.
+func Metrics(opts MetricsOptions) Middleware {
+	if opts.Registerer == nil {
+		opts.Registerer = obs.Registry
+	}
+	if opts.Buckets == nil {
+		opts.Buckets = prometheus.DefBuckets
+	}
+	if opts.SizeBuckets == nil {
+		opts.SizeBuckets = prometheus.ExponentialBuckets(64, 4, 8)
+	}
+
+	requests := obs.RegisterOrExisting(opts.Registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests",
+		},
+		[]string{"method", "endpoint", "status", "protocol", "tenant"},
+	))
+	duration := obs.RegisterOrExisting(opts.Registerer, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request duration in seconds",
+			Buckets: opts.Buckets,
+		},
+		[]string{"method", "endpoint", "protocol", "tenant"},
+	))
+	requestSize := obs.RegisterOrExisting(opts.Registerer, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_body_size_bytes",
+			Help:    "HTTP request body bytes read by the handler",
+			Buckets: opts.SizeBuckets,
+		},
+		[]string{"method", "endpoint"},
+	))
+	responseSize := obs.RegisterOrExisting(opts.Registerer, prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_response_body_size_bytes",
+			Help:    "HTTP response body bytes written",
+			Buckets: opts.SizeBuckets,
+		},
+		[]string{"method", "endpoint"},
+	))
+	obs.RegisterOrExisting(opts.Registerer, prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests currently being served",
+		},
+		func() float64 { return float64(inFlight.Load()) },
+	))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer trackInFlight(r)()
+			if obs.Quiet(r.Context()).Metrics {
+				serveLabelled(next, w, r)
+				return
+			}
+			start := time.Now()
+			wrapped := newStatusRecorder(w)
+			body := &countingBody{ReadCloser: r.Body}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = body
+			}
+			serveLabelled(next, wrapped, r)
+
+			endpoint := RouteTemplate(r)
+			elapsed := time.Since(start).Seconds()
+			protocol := Protocol(r)
+			tenant := TenantLabel(r.Context())
+			requests.WithLabelValues(r.Method, endpoint, strconv.Itoa(wrapped.statusCode), protocol, tenant).Inc()
+			requestSize.WithLabelValues(r.Method, endpoint).Observe(float64(body.bytesRead))
+			responseSize.WithLabelValues(r.Method, endpoint).Observe(float64(wrapped.bytesWritten))
+			trace.SpanFromContext(r.Context()).SetAttributes(
+				semconv.HTTPRequestBodySize(int(body.bytesRead)),
+				semconv.HTTPResponseBodySize(int(wrapped.bytesWritten)),
+			)
+			observer := duration.WithLabelValues(r.Method, endpoint, protocol, tenant)
+			if sc := trace.SpanContextFromContext(r.Context()); sc.IsSampled() {
+				observer.(prometheus.ExemplarObserver).ObserveWithExemplar(elapsed, prometheus.Labels{
+					"trace_id":       sc.TraceID().String(),
+					"client_address": ClientAddress(r),
+				})
+			} else {
+				observer.Observe(elapsed)
+			}
+		})
+	}
+}
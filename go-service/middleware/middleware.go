@@ -0,0 +1,131 @@
+// Package middleware provides composable HTTP middleware for request logging,
+// RED metrics, tracing, panic recovery and request IDs.
+//
+// Each middleware is independent so services can opt in per router or per route:
+//
+//	r.Use(middleware.Chain(
+//		middleware.Tracing("my-service"),
+//		middleware.RequestID(middleware.RequestIDOptions{}),
+//		middleware.Logging(middleware.LoggingOptions{}),
+//		middleware.Metrics(middleware.MetricsOptions{}),
+//		middleware.Recovery(),
+//	))
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware wraps a handler. It is an alias so values can be passed straight to mux.Router.Use.
+type Middleware = func(http.Handler) http.Handler
+
+// Chain composes middleware so the first one listed is the outermost
+func Chain(mws ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			next = mws[i](next)
+		}
+		return next
+	}
+}
+
+// RouteTemplate returns the matched mux route template, falling back to the raw path
+func RouteTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return r.URL.Path
+}
+
+// Scheme reports the scheme the client used to reach this server
+func Scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Protocol reports the negotiated HTTP protocol: "h2" over TLS, "h2c" over cleartext,
+// otherwise "http/1.1" or "http/1.0"
+func Protocol(r *http.Request) string {
+	switch {
+	case r.ProtoMajor == 2 && r.TLS != nil:
+		return "h2"
+	case r.ProtoMajor == 2:
+		return "h2c"
+	case r.ProtoMajor == 1 && r.ProtoMinor == 0:
+		return "http/1.0"
+	default:
+		return "http/1.1"
+	}
+}
+
+// statusRecorder wraps http.ResponseWriter to capture the status code and body bytes written
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode   int
+	wroteHeader  bool
+	bytesWritten int64
+}
+
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+}
+
+func (rw *statusRecorder) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.statusCode = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *statusRecorder) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytesWritten += int64(n)
+	return n, err
+}
+
+// Flush supports streaming handlers behind the recorder
+func (rw *statusRecorder) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack supports protocol upgrades such as WebSockets
+func (rw *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("underlying ResponseWriter does not support hijacking")
+	}
+	rw.wroteHeader = true
+	rw.statusCode = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *statusRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// countingBody wraps a request body to count the bytes the handler reads
+type countingBody struct {
+	io.ReadCloser
+	bytesRead int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytesRead += int64(n)
+	return n, err
+}
@@ -0,0 +1,573 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel/baggage"
+
+	"go-service/obs"
+)
+
+func TestStatusRecorderCapturesStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{"implicit 200", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }, http.StatusOK},
+		{"no body", func(w http.ResponseWriter, r *http.Request) {}, http.StatusOK},
+		{"explicit", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusTeapot) }, http.StatusTeapot},
+		{"first wins", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newStatusRecorder(httptest.NewRecorder())
+			tt.handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.statusCode != tt.want {
+				t.Errorf("statusCode = %d, want %d", rec.statusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusRecorderExposesFlusher(t *testing.T) {
+	inner := httptest.NewRecorder()
+	var w http.ResponseWriter = newStatusRecorder(inner)
+	f, ok := w.(http.Flusher)
+	if !ok {
+		t.Fatal("statusRecorder does not implement http.Flusher")
+	}
+	f.Flush()
+	if !inner.Flushed {
+		t.Error("Flush not forwarded")
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(mark("a"), mark("b"), mark("c"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := strings.Join(order, ","); got != "a,b,c,handler" {
+		t.Errorf("order = %s", got)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	var seen string
+	h := RequestID(RequestIDOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name, incoming string
+		reuse          bool
+	}{
+		{"reuses valid id", "abc-123", true},
+		{"generates when missing", "", false},
+		{"rejects whitespace", "has space", false},
+		{"rejects oversized", strings.Repeat("x", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.incoming != "" {
+				req.Header.Set("X-Request-Id", tt.incoming)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			echoed := rec.Header().Get("X-Request-Id")
+			if echoed == "" || echoed != seen {
+				t.Fatalf("echoed %q, context %q", echoed, seen)
+			}
+			if (echoed == tt.incoming) != tt.reuse {
+				t.Errorf("reuse = %v, want %v (got %q)", echoed == tt.incoming, tt.reuse, echoed)
+			}
+		})
+	}
+}
+
+func TestRecoveryReturns500(t *testing.T) {
+	h := Recovery()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+	var problem ProblemDetails
+	if err := json.NewDecoder(rec.Body).Decode(&problem); err != nil || problem.Code != "internal" {
+		t.Errorf("problem = %+v (%v), want code internal", problem, err)
+	}
+}
+
+func TestRecoveryKeepsStartedResponse(t *testing.T) {
+	h := Recovery()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("late")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("status = %d, want the already-written 202", rec.Code)
+	}
+}
+
+func TestTimeoutReplacesLateResponse(t *testing.T) {
+	mw := Timeout(TimeoutOptions{
+		Default:    10 * time.Millisecond,
+		Routes:     map[string]time.Duration{"/fast": 0},
+		Registerer: prometheus.NewRegistry(),
+	})
+	slow := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	rec := httptest.NewRecorder()
+	slow.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("late response status = %d, want 504", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("late response Content-Type = %q, want problem details", ct)
+	}
+
+	exempt := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("exempt route got a deadline")
+		}
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	rec = httptest.NewRecorder()
+	exempt.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("exempt route status = %d, want 418", rec.Code)
+	}
+}
+
+func TestTimeoutLeavesFlushedResponseAlone(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	mw := Timeout(TimeoutOptions{Default: 10 * time.Millisecond, Registerer: reg})
+	stream := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	rec := httptest.NewRecorder()
+	stream.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if rec.Code != http.StatusOK || !rec.Flushed {
+		t.Errorf("flushed response status = %d (flushed %v), want 200", rec.Code, rec.Flushed)
+	}
+	if n := testutil.CollectAndCount(reg, "http_request_timeouts_total"); n != 0 {
+		t.Errorf("timeouts recorded for a flushed response: %d", n)
+	}
+}
+
+func TestDebugTraceRequiresToken(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+		value string
+		want  bool
+	}{
+		{"no token configured", "", "1", false},
+		{"wrong token", "s3cret", "1", false},
+		{"matching token", "s3cret", "s3cret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var debugged bool
+			h := DebugTrace(DebugTraceOptions{Token: tt.token})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				debugged = obs.DebugEnabled(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Debug-Trace", tt.value)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if debugged != tt.want {
+				t.Errorf("debugged = %v, want %v", debugged, tt.want)
+			}
+		})
+	}
+}
+
+func signHS256(t *testing.T, secret string, claims map[string]interface{}) string {
+	t.Helper()
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	body, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := header + "." + base64.RawURLEncoding.EncodeToString(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthAcceptsValidCredentialsAndCountsFailures(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	var reached bool
+	handler := Logging(LoggingOptions{})(Auth(AuthOptions{
+		APIKeys:     map[string]string{"k-123": "ci-bot"},
+		JWTSecret:   []byte("s3cret"),
+		JWTAudience: "go-service",
+		Public:      []string{"/health"},
+		Registerer:  reg,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	})))
+
+	exp := float64(time.Now().Add(time.Hour).Unix())
+	tests := []struct {
+		name   string
+		path   string
+		header http.Header
+		want   int
+	}{
+		{"public", "/health", nil, http.StatusOK},
+		{"missing", "/users", nil, http.StatusUnauthorized},
+		{"api key", "/users", http.Header{"X-Api-Key": {"k-123"}}, http.StatusOK},
+		{"wrong api key", "/users", http.Header{"X-Api-Key": {"nope"}}, http.StatusUnauthorized},
+		{"jwt", "/users", http.Header{"Authorization": {"Bearer " + signHS256(t, "s3cret", map[string]interface{}{"sub": "alice", "aud": []string{"go-service"}, "exp": exp})}}, http.StatusOK},
+		{"expired jwt", "/users", http.Header{"Authorization": {"Bearer " + signHS256(t, "s3cret", map[string]interface{}{"sub": "alice", "aud": "go-service", "exp": 1})}}, http.StatusUnauthorized},
+		{"bad signature", "/users", http.Header{"Authorization": {"Bearer " + signHS256(t, "other", map[string]interface{}{"sub": "alice"})}}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached = false
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if reached != (tt.want == http.StatusOK) {
+				t.Errorf("handler reached = %v", reached)
+			}
+			if ct := rec.Header().Get("Content-Type"); tt.want == http.StatusUnauthorized && ct != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want problem details", ct)
+			}
+		})
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reasons := map[string]float64{}
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			reasons[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+		}
+	}
+	for _, reason := range []string{"missing_credentials", "invalid_api_key", "expired", "invalid_signature"} {
+		if reasons[reason] != 1 {
+			t.Errorf("auth_failures_total{reason=%q} = %v, want 1", reason, reasons[reason])
+		}
+	}
+}
+
+func TestClientIPOnlyTrustsConfiguredProxies(t *testing.T) {
+	opts := ClientIPOptions{TrustedProxies: []netip.Prefix{netip.MustParsePrefix("172.16.0.0/12")}}
+	tests := []struct {
+		name   string
+		peer   string
+		header string
+		value  string
+		want   string
+	}{
+		{"no headers", "172.18.0.1:5000", "", "", "172.18.0.1"},
+		{"untrusted peer ignores XFF", "203.0.113.9:5000", "X-Forwarded-For", "198.51.100.1", "203.0.113.9"},
+		{"trusted peer uses XFF", "172.18.0.1:5000", "X-Forwarded-For", "198.51.100.1", "198.51.100.1"},
+		{"spoofed left-most hop skipped", "172.18.0.1:5000", "X-Forwarded-For", "10.9.9.9, 198.51.100.1, 172.18.0.5", "198.51.100.1"},
+		{"Forwarded with IPv6", "172.18.0.1:5000", "Forwarded", `for="[2001:db8::17]:4711";proto=https`, "2001:db8::17"},
+		{"X-Real-IP", "172.18.0.1:5000", "X-Real-IP", "198.51.100.7", "198.51.100.7"},
+		{"obfuscated hop falls back to proxy", "172.18.0.1:5000", "Forwarded", "for=unknown", "172.18.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := ClientIP(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientAddress(r)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.peer
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("ClientAddress = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentityPropagatesAndHashesHeaders(t *testing.T) {
+	headers := []IdentityHeader{{Header: "X-User-Id", Key: "enduser.id"}, {Header: "X-Session-Id", Key: "session.id"}}
+	serve := func(opts IdentityOptions, r *http.Request) (fields map[string]interface{}, bag baggage.Baggage) {
+		h := Identity(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fields = IdentityFields(r.Context())
+			bag = baggage.FromContext(r.Context())
+		}))
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		return fields, bag
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-User-Id", "alice")
+	r.Header.Set("X-Session-Id", "bad value") // not a printable token, ignored
+	fields, bag := serve(IdentityOptions{Headers: headers}, r)
+	if fields["enduser.id"] != "alice" || fields["session.id"] != nil {
+		t.Errorf("fields = %v", fields)
+	}
+	if bag.Member("enduser.id").Value() != "alice" {
+		t.Errorf("baggage = %v", bag)
+	}
+
+	fields, bag = serve(IdentityOptions{Headers: headers, Hash: true, HashKey: "k"}, r)
+	mac := hmac.New(sha256.New, []byte("k"))
+	mac.Write([]byte("alice"))
+	want := hex.EncodeToString(mac.Sum(nil)[:16])
+	if fields["enduser.id"] != want || bag.Member("enduser.id").Value() != want {
+		t.Errorf("hashed identity = %v / %v, want %s", fields["enduser.id"], bag.Member("enduser.id").Value(), want)
+	}
+}
+
+func TestSLOTrackerCountsGoodEventsAndBurnRate(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	tracker := NewSLOTracker(SLOOptions{
+		Registerer: reg,
+		Objectives: []SLOObjective{
+			{Name: "availability", Target: 0.9},
+			{Name: "latency", Target: 0.9, Latency: 20 * time.Millisecond},
+		},
+		Skip: func(route string) bool { return route == "/health" },
+	})
+	at := time.Unix(1700000000, 0)
+	tracker.now = func() time.Time { return at }
+
+	h := tracker.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/slow":
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+	for _, path := range []string{"/ok", "/ok", "/slow", "/fail", "/health"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	status := tracker.Status()
+	// availability: 3 of 4 good, latency: 2 of 4; /health is skipped
+	for i, want := range []struct{ good, total uint64 }{{3, 4}, {2, 4}} {
+		w := status[i].Windows[0]
+		if w.Window != "5m" || w.Good != want.good || w.Total != want.total {
+			t.Errorf("%s 5m window = %+v, want %d/%d", status[i].Name, w, want.good, want.total)
+		}
+	}
+	// 25% errors against a 10% budget burns 2.5x
+	if burn := status[0].Windows[0].BurnRate; burn < 2.49 || burn > 2.51 {
+		t.Errorf("availability burn rate = %v, want 2.5", burn)
+	}
+
+	// An hour later the 5m and 30m windows are empty but the 6h window still counts
+	at = at.Add(time.Hour)
+	status = tracker.Status()
+	if w := status[0].Windows; w[0].Total != 0 || w[3].Window != "6h" || w[3].Total != 4 {
+		t.Errorf("windows after an hour = %+v", w)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	found := map[string]bool{}
+	for _, mf := range families {
+		found[mf.GetName()] = true
+	}
+	for _, name := range []string{"slo_events_total", "slo_good_events_total", "slo_objective_ratio", "slo_burn_rate"} {
+		if !found[name] {
+			t.Errorf("%s not registered", name)
+		}
+	}
+}
+
+func TestTenantLabelsAreBoundedByAllowlist(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	h := Chain(
+		Tenant(TenantOptions{Allowed: []string{"acme"}}),
+		Metrics(MetricsOptions{Registerer: reg}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, id := range []string{"acme", "acme", "globex", "initech", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if id != "" {
+			req.Header.Set("X-Tenant-Id", id)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	got := map[string]float64{}
+	for _, mf := range families {
+		if mf.GetName() != "http_requests_total" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "tenant" {
+					got[lp.GetValue()] += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	want := map[string]float64{"acme": 2, TenantOther: 2, TenantNone: 1}
+	if len(got) != len(want) {
+		t.Fatalf("tenant label values = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("tenant %q = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestMetricsRecordBodySizes(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	h := Metrics(MetricsOptions{Registerer: reg})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+		w.Write(body)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader("hello")))
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	sums := map[string]float64{}
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			if hist := m.GetHistogram(); hist != nil {
+				sums[mf.GetName()] += hist.GetSampleSum()
+			}
+		}
+	}
+	if sums["http_request_body_size_bytes"] != 5 || sums["http_response_body_size_bytes"] != 10 {
+		t.Errorf("body sizes = %v, want 5 bytes read and 10 written", sums)
+	}
+}
+
+func TestQuietSuppressesRequestLogsAndMetrics(t *testing.T) {
+	var logs bytes.Buffer
+	obs.SetOutput(&logs)
+	defer obs.SetOutput(os.Stdout)
+
+	reg := prometheus.NewRegistry()
+	h := Chain(
+		Quiet(QuietOptions{Routes: []string{"/health"}, Signals: obs.QuietSignals{Logs: true, Metrics: true}, Registerer: reg}),
+		Logging(LoggingOptions{}),
+		Metrics(MetricsOptions{Registerer: reg}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	if strings.Contains(logs.String(), `"path":"/health"`) || !strings.Contains(logs.String(), `"path":"/users"`) {
+		t.Errorf("want request logs for /users only, got:\n%s", logs.String())
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := map[string]int{}
+	for _, f := range families {
+		series[f.GetName()] = len(f.GetMetric())
+		if f.GetName() == "http_quiet_requests_total" {
+			if m := f.GetMetric()[0]; m.GetLabel()[0].GetValue() != "/health" || m.GetCounter().GetValue() != 1 {
+				t.Errorf("http_quiet_requests_total = %v", m)
+			}
+		}
+	}
+	if series["http_requests_total"] != 1 || series["http_quiet_requests_total"] != 1 {
+		t.Errorf("series per metric = %v, want /users in http_requests_total and /health in http_quiet_requests_total", series)
+	}
+}
+
+func TestTrafficStatsReportsTopKeysOverWindow(t *testing.T) {
+	stats := NewTrafficStats(TrafficStatsOptions{K: 2, Window: 6 * time.Minute})
+	now := time.Unix(6000, 0)
+	stats.now = func() time.Time { return now }
+
+	for i := 0; i < 50; i++ {
+		stats.record("10.0.0.1", "GET /users/{id}", "200")
+	}
+	for i := 0; i < 30; i++ {
+		stats.record("10.0.0.2", "GET /error", "500")
+	}
+	// A long tail of one-off clients must not push the heavy hitters out
+	for i := 0; i < 500; i++ {
+		stats.record("192.168.0."+strconv.Itoa(i), "GET /", "200")
+		if i%100 == 0 {
+			now = now.Add(time.Minute)
+		}
+	}
+
+	snap := stats.Snapshot(5)
+	if snap.Total != 580 || len(snap.Clients) != 2 {
+		t.Fatalf("total = %d, clients = %v", snap.Total, snap.Clients)
+	}
+	for i, want := range []TopKEntry{{Key: "10.0.0.1", Count: 50}, {Key: "10.0.0.2", Count: 30}} {
+		got := snap.Clients[i]
+		if got.Key != want.Key || got.Count < want.Count || got.Count > want.Count+snap.MaxOvercount {
+			t.Errorf("clients[%d] = %+v, want %s with %d (+%d)", i, got, want.Key, want.Count, snap.MaxOvercount)
+		}
+	}
+	if snap.Routes[0].Key != "GET /" || snap.Statuses[0].Key != "200" {
+		t.Errorf("routes = %v, statuses = %v", snap.Routes, snap.Statuses)
+	}
+
+	// Once the window has passed only new traffic counts
+	now = now.Add(7 * time.Minute)
+	stats.record("10.0.0.3", "GET /", "200")
+	if snap := stats.Snapshot(0); snap.Total != 1 || len(snap.Clients) != 1 || snap.Clients[0].Key != "10.0.0.3" {
+		t.Errorf("after the window: %+v", snap)
+	}
+}
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+var appErrorsTotal = obs.RegisterOrExisting(obs.Registry, prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "app_errors_total",
+		Help: "Total number of error responses by application error code and HTTP status",
+	},
+	[]string{"code", "status"},
+))
+
+// Problem is an error response. Code is a stable, machine-readable identifier (and the
+// error.type of the span); Message is safe to show to callers. Err is the underlying
+// cause, which is logged but never rendered.
+type Problem struct {
+	Status    int
+	Code      string
+	Message   string
+	Retryable bool
+	Details   map[string]interface{}
+	Err       error
+}
+
+// ProblemDetails is the RFC 9457 application/problem+json body of every error response,
+// extended with the error code, retryability and the IDs needed to find the request's
+// trace and logs
+type ProblemDetails struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Detail    string                 `json:"detail"`
+	Instance  string                 `json:"instance"`
+	Code      string                 `json:"code"`
+	Retryable bool                   `json:"retryable"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	TraceID   string                 `json:"trace_id,omitempty"`
+}
+
+// WriteProblem renders p as problem details and records it on the request span
+// (error.type / error.code, error status for 5xx), in app_errors_total and in the
+// request's completion log, which Logging already writes at WARN for 4xx and ERROR for
+// 5xx. Handlers and middleware share it so every error response looks the same.
+func WriteProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		semconv.ErrorTypeKey.String(p.Code),
+		attribute.String("error.code", p.Code),
+		attribute.Bool("error.retryable", p.Retryable),
+	)
+	if p.Status >= 500 {
+		if p.Err != nil {
+			span.RecordError(p.Err)
+		}
+		span.SetStatus(codes.Error, p.Message)
+	}
+	appErrorsTotal.WithLabelValues(p.Code, strconv.Itoa(p.Status)).Inc()
+
+	logFields := map[string]interface{}{"error_code": p.Code}
+	if p.Err != nil {
+		logFields["error"] = p.Err.Error()
+	}
+	AddLogFields(ctx, logFields)
+
+	problem := ProblemDetails{
+		Type:      "urn:go-service:error:" + p.Code,
+		Title:     http.StatusText(p.Status),
+		Status:    p.Status,
+		Detail:    p.Message,
+		Instance:  r.URL.Path,
+		Code:      p.Code,
+		Retryable: p.Retryable,
+		Details:   p.Details,
+		RequestID: RequestIDFromContext(ctx),
+	}
+	if sc := span.SpanContext(); sc.IsValid() {
+		problem.TraceID = sc.TraceID().String()
+	}
+	if problem.Title == "" {
+		problem.Title = "Client Closed Request" // 499 has no net/http status text
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-service/obs"
+)
+
+// QuietOptions lists the route templates whose telemetry is noise, such as kubelet probes
+// and Prometheus scrapes, and the signals to suppress for them
+type QuietOptions struct {
+	Routes     []string
+	Signals    obs.QuietSignals
+	Registerer prometheus.Registerer
+}
+
+// Quiet marks requests to the listed routes with obs.WithQuiet, so they are not traced,
+// logged by Logging or measured by Metrics, as configured. Each one is still counted in
+// http_quiet_requests_total, which keeps probe and scrape rates visible. It must run
+// before Tracing so the server span is dropped too.
+func Quiet(opts QuietOptions) Middleware {
+	if len(opts.Routes) == 0 || opts.Signals == (obs.QuietSignals{}) {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	if opts.Registerer == nil {
+		opts.Registerer = obs.Registry
+	}
+	quiet := obs.RegisterOrExisting(opts.Registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_quiet_requests_total",
+			Help: "Total number of requests whose traces, request logs or metrics were suppressed, by route",
+		},
+		[]string{"endpoint"},
+	))
+	routes := map[string]bool{}
+	for _, route := range opts.Routes {
+		routes[route] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			endpoint := RouteTemplate(r)
+			if routes[endpoint] {
+				quiet.WithLabelValues(endpoint).Inc()
+				r = r.WithContext(obs.WithQuiet(r.Context(), opts.Signals))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// Recovery turns a handler panic into a 500, logging the stack and marking the span as failed.
+// Place it inside Logging and Metrics so the 500 is recorded by both.
+func Recovery() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapped := newStatusRecorder(w)
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// The server uses this sentinel to abort a response silently
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				// Written first so the span keeps the panic, not the generic message, as its status
+				if !wrapped.wroteHeader {
+					WriteProblem(wrapped, r, Problem{Status: http.StatusInternalServerError, Code: "internal", Message: "internal error"})
+				}
+				err := fmt.Errorf("panic: %v", rec)
+				span := trace.SpanFromContext(r.Context())
+				span.RecordError(err, trace.WithStackTrace(true))
+				span.SetStatus(codes.Error, err.Error())
+				obs.ErrorContext(r.Context(), "Recovered from handler panic", map[string]interface{}{
+					"method":     r.Method,
+					"path":       r.URL.Path,
+					"panic":      fmt.Sprint(rec),
+					"stack":      string(debug.Stack()),
+					"request_id": RequestIDFromContext(r.Context()),
+				})
+			}()
+			next.ServeHTTP(wrapped, r)
+		})
+	}
+}
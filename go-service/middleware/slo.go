@@ -0,0 +1,227 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-service/obs"
+)
+
+// SLOObjective is one service level objective over HTTP requests. A request is good when
+// it did not fail with a 5xx and, for latency objectives (Latency > 0), completed within
+// Latency.
+type SLOObjective struct {
+	Name    string
+	Target  float64 // fraction of good requests, e.g. 0.999
+	Latency time.Duration
+}
+
+// SLOOptions configures NewSLOTracker; Skip excludes routes (by template) such as health
+// checks from every objective
+type SLOOptions struct {
+	Registerer prometheus.Registerer
+	Objectives []SLOObjective
+	Skip       func(route string) bool
+}
+
+// SLOWindows are the burn-rate windows of the usual multiwindow alert pairs (1h/5m for
+// fast burns, 6h/30m for slow ones)
+var SLOWindows = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+
+// sloBucket counts the events of one minute
+type sloBucket struct {
+	minute      int64
+	good, total uint64
+}
+
+type sloSeries struct {
+	objective SLOObjective
+	mu        sync.Mutex
+	buckets   []sloBucket // ring indexed by minute, covering the longest window
+}
+
+// SLOTracker records good/total events per objective as Prometheus counters, for burn
+// rate alerts in PromQL, and keeps per-minute counts so it can report burn rates itself
+type SLOTracker struct {
+	series []*sloSeries
+	skip   func(string) bool
+	events *prometheus.CounterVec
+	good   *prometheus.CounterVec
+	now    func() time.Time
+}
+
+// SLOWindowStatus is the SLI and burn rate of one objective over one window. A burn rate
+// of 1 spends the error budget exactly over the SLO period.
+type SLOWindowStatus struct {
+	Window   string  `json:"window"`
+	Total    uint64  `json:"total"`
+	Good     uint64  `json:"good"`
+	SLI      float64 `json:"sli"`
+	BurnRate float64 `json:"burn_rate"`
+}
+
+type SLOStatus struct {
+	Name                 string            `json:"name"`
+	Target               float64           `json:"target"`
+	LatencyThresholdMs   int64             `json:"latency_threshold_ms,omitempty"`
+	ErrorBudgetRemaining float64           `json:"error_budget_remaining"` // over the longest window
+	Windows              []SLOWindowStatus `json:"windows"`
+}
+
+// NewSLOTracker registers slo_events_total, slo_good_events_total, slo_objective_ratio and
+// slo_burn_rate for the objectives
+func NewSLOTracker(opts SLOOptions) *SLOTracker {
+	if opts.Registerer == nil {
+		opts.Registerer = obs.Registry
+	}
+	longest := SLOWindows[len(SLOWindows)-1]
+	t := &SLOTracker{
+		skip: opts.Skip,
+		now:  time.Now,
+		events: obs.RegisterOrExisting(opts.Registerer, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "slo_events_total",
+				Help: "Requests counted towards each SLO",
+			},
+			[]string{"slo"},
+		)),
+		good: obs.RegisterOrExisting(opts.Registerer, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "slo_good_events_total",
+				Help: "Requests that met each SLO",
+			},
+			[]string{"slo"},
+		)),
+	}
+	objectiveRatio := obs.RegisterOrExisting(opts.Registerer, prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "slo_objective_ratio",
+			Help: "Target fraction of good requests for each SLO",
+		},
+		[]string{"slo"},
+	))
+	for _, o := range opts.Objectives {
+		t.series = append(t.series, &sloSeries{objective: o, buckets: make([]sloBucket, int(longest/time.Minute))})
+		objectiveRatio.WithLabelValues(o.Name).Set(o.Target)
+		// Initialise the series so rate() works before the first bad request
+		t.events.WithLabelValues(o.Name)
+		t.good.WithLabelValues(o.Name)
+	}
+	opts.Registerer.MustRegister(sloBurnRateCollector{t})
+	return t
+}
+
+// Middleware records every non-skipped request against each objective
+func (t *SLOTracker) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrapped := newStatusRecorder(w)
+			next.ServeHTTP(wrapped, r)
+
+			if t.skip != nil && t.skip(RouteTemplate(r)) {
+				return
+			}
+			t.record(wrapped.statusCode, time.Since(start))
+		})
+	}
+}
+
+func (t *SLOTracker) record(status int, elapsed time.Duration) {
+	minute := t.now().Unix() / 60
+	for _, s := range t.series {
+		good := status < 500 && (s.objective.Latency == 0 || elapsed <= s.objective.Latency)
+		t.events.WithLabelValues(s.objective.Name).Inc()
+		if good {
+			t.good.WithLabelValues(s.objective.Name).Inc()
+		}
+
+		s.mu.Lock()
+		b := &s.buckets[minute%int64(len(s.buckets))]
+		if b.minute != minute {
+			*b = sloBucket{minute: minute}
+		}
+		b.total++
+		if good {
+			b.good++
+		}
+		s.mu.Unlock()
+	}
+}
+
+// window sums the buckets of the last d, including the current minute
+func (s *sloSeries) window(now time.Time, d time.Duration) (good, total uint64) {
+	minute := now.Unix() / 60
+	oldest := minute - int64(d/time.Minute) + 1
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.buckets {
+		if b.minute >= oldest && b.minute <= minute {
+			good += b.good
+			total += b.total
+		}
+	}
+	return good, total
+}
+
+func burnRate(good, total uint64, target float64) (sli, burn float64) {
+	if total == 0 {
+		return 1, 0
+	}
+	sli = float64(good) / float64(total)
+	return sli, (1 - sli) / (1 - target)
+}
+
+// Status reports every objective over each of SLOWindows
+func (t *SLOTracker) Status() []SLOStatus {
+	now := t.now()
+	out := make([]SLOStatus, 0, len(t.series))
+	for _, s := range t.series {
+		status := SLOStatus{Name: s.objective.Name, Target: s.objective.Target, LatencyThresholdMs: s.objective.Latency.Milliseconds()}
+		for _, d := range SLOWindows {
+			good, total := s.window(now, d)
+			sli, burn := burnRate(good, total, s.objective.Target)
+			status.Windows = append(status.Windows, SLOWindowStatus{Window: formatWindow(d), Total: total, Good: good, SLI: sli, BurnRate: burn})
+			status.ErrorBudgetRemaining = 1 - burn
+		}
+		out = append(out, status)
+	}
+	return out
+}
+
+func formatWindow(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	}
+	return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+}
+
+var sloBurnRateDesc = prometheus.NewDesc(
+	"slo_burn_rate",
+	"Error budget burn rate of each SLO over a trailing window, computed in process",
+	[]string{"slo", "window"}, nil,
+)
+
+// sloBurnRateCollector computes slo_burn_rate at scrape time
+type sloBurnRateCollector struct {
+	t *SLOTracker
+}
+
+func (c sloBurnRateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sloBurnRateDesc
+}
+
+func (c sloBurnRateCollector) Collect(ch chan<- prometheus.Metric) {
+	now := c.t.now()
+	for _, s := range c.t.series {
+		for _, d := range SLOWindows {
+			good, total := s.window(now, d)
+			_, burn := burnRate(good, total, s.objective.Target)
+			ch <- prometheus.MustNewConstMetric(sloBurnRateDesc, prometheus.GaugeValue, burn, s.objective.Name, formatWindow(d))
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TenantNone labels requests without a (valid) tenant header
+	TenantNone = "none"
+	// TenantOther labels requests from tenants outside the allowlist
+	TenantOther = "other"
+)
+
+// TenantOptions configures Tenant; Header defaults to X-Tenant-Id. Only Allowed tenants
+// get their own metric label value.
+type TenantOptions struct {
+	Header  string
+	Allowed []string
+}
+
+type tenantKey struct{}
+
+type tenant struct {
+	id    string // as sent, for spans and logs
+	label string // bounded, for metrics
+}
+
+// Tenant reads the tenant header, tags the span with tenant.id and stores the tenant for
+// Logging (the "tenant" field) and Metrics (the tenant label). Metrics only ever see
+// allowlisted IDs, "other" or "none", so unknown tenants cannot grow the series count.
+// It must run inside Tracing.
+func Tenant(opts TenantOptions) Middleware {
+	header := opts.Header
+	if header == "" {
+		header = "X-Tenant-Id"
+	}
+	allowed := make(map[string]bool, len(opts.Allowed))
+	for _, id := range opts.Allowed {
+		allowed[id] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if !validRequestID(id) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			t := tenant{id: id, label: TenantOther}
+			if allowed[id] {
+				t.label = id
+			}
+			trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("tenant.id", id))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, t)))
+		})
+	}
+}
+
+// TenantFromContext returns the tenant ID set by Tenant, or ""
+func TenantFromContext(ctx context.Context) string {
+	t, _ := ctx.Value(tenantKey{}).(tenant)
+	return t.id
+}
+
+// TenantLabel returns the bounded metric label for the tenant in ctx
+func TenantLabel(ctx context.Context) string {
+	t, ok := ctx.Value(tenantKey{}).(tenant)
+	if !ok {
+		return TenantNone
+	}
+	return t.label
+}
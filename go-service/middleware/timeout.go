@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// TimeoutOptions sets request deadlines by route template. Routes not listed use
+// Default; a zero duration means no timeout.
+type TimeoutOptions struct {
+	Default    time.Duration
+	Routes     map[string]time.Duration
+	Registerer prometheus.Registerer
+}
+
+// Timeout cancels the request context once the route's deadline passes. Handlers are
+// expected to return when their context is done; if the deadline has passed by the time
+// they respond, the response is replaced with a 504, the span is marked as an error with
+// timeout=true and http_request_timeouts_total is incremented. It runs synchronously, so
+// Flush and Hijack keep working for streaming routes.
+func Timeout(opts TimeoutOptions) Middleware {
+	if opts.Registerer == nil {
+		opts.Registerer = obs.Registry
+	}
+	timeouts := obs.RegisterOrExisting(opts.Registerer, prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_request_timeouts_total",
+			Help: "Total number of HTTP requests that exceeded their route timeout",
+		},
+		[]string{"method", "endpoint"},
+	))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			endpoint := RouteTemplate(r)
+			limit, ok := opts.Routes[endpoint]
+			if !ok {
+				limit = opts.Default
+			}
+			if limit <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), limit)
+			defer cancel()
+			r = r.WithContext(ctx)
+			tw := &timeoutWriter{ResponseWriter: w, req: r}
+			next.ServeHTTP(tw, r)
+			tw.finish()
+
+			if !tw.timedOut {
+				return
+			}
+			timeouts.WithLabelValues(r.Method, endpoint).Inc()
+			span := trace.SpanFromContext(r.Context())
+			span.SetAttributes(
+				attribute.Bool("timeout", true),
+				attribute.Float64("http.request.timeout_seconds", limit.Seconds()),
+			)
+			span.SetStatus(codes.Error, "request timed out")
+			obs.WarnContext(r.Context(), "Request timed out", map[string]interface{}{
+				"method":          r.Method,
+				"path":            r.URL.Path,
+				"timeout_seconds": limit.Seconds(),
+				"request_id":      RequestIDFromContext(r.Context()),
+			})
+		})
+	}
+}
+
+// timeoutWriter swaps the handler's response for a 504 when it responds after the deadline
+type timeoutWriter struct {
+	http.ResponseWriter
+	req *http.Request
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+// representationHeaders describe the handler's body, and are wrong for the 504 replacing it
+var representationHeaders = []string{
+	"Content-Encoding", "Content-Length", "Content-Range", "Content-Language",
+	"Content-Disposition", "Vary", "ETag", "Last-Modified",
+}
+
+func (tw *timeoutWriter) writeTimeout() {
+	tw.timedOut = true
+	h := tw.ResponseWriter.Header()
+	for _, name := range representationHeaders {
+		h.Del(name)
+	}
+	WriteProblem(tw.ResponseWriter, tw.req, Problem{
+		Status:    http.StatusGatewayTimeout,
+		Code:      "request_timeout",
+		Message:   "request timed out",
+		Retryable: true,
+	})
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	if errors.Is(tw.req.Context().Err(), context.DeadlineExceeded) {
+		tw.writeTimeout()
+		return
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.WriteHeader(http.StatusOK)
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		// Pretend the write succeeded; the handler's body is discarded
+		return len(b), nil
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// finish writes the 504 for handlers that returned after the deadline without responding
+func (tw *timeoutWriter) finish() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.wroteHeader && errors.Is(tw.req.Context().Err(), context.DeadlineExceeded) {
+		tw.wroteHeader = true
+		tw.writeTimeout()
+	}
+}
+
+// Flush sends the headers, so a deadline passing after it leaves the response alone
+func (tw *timeoutWriter) Flush() {
+	tw.WriteHeader(http.StatusOK)
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if f, ok := tw.ResponseWriter.(http.Flusher); ok && !tw.timedOut {
+		f.Flush()
+	}
+}
+
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := tw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("underlying ResponseWriter does not support hijacking")
+	}
+	tw.mu.Lock()
+	tw.wroteHeader = true
+	tw.mu.Unlock()
+	return h.Hijack()
+}
+
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
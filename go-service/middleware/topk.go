@@ -0,0 +1,268 @@
+package middleware
+
+import (
+	"hash/fnv"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TrafficStatsOptions configures NewTrafficStats. K is the largest top-K that can be
+// reported (10 by default) and Window the sliding window (5m by default), tracked as 6
+// slices, so the window advances one slice at a time. Width and Depth size each slice's
+// count-min sketch (1024x4 by default): estimates never undercount and overcount by at
+// most e/Width of the window's requests with probability 1-e^-Depth.
+type TrafficStatsOptions struct {
+	K      int
+	Window time.Duration
+	Width  int
+	Depth  int
+}
+
+// trafficSlices is the number of slices the window is divided into
+const trafficSlices = 6
+
+// Traffic dimensions, also the key prefixes that let one sketch serve all three
+const (
+	trafficClient = "client"
+	trafficRoute  = "route"
+	trafficStatus = "status"
+)
+
+var trafficDimensions = []string{trafficClient, trafficRoute, trafficStatus}
+
+// countMinSketch counts keys in depth rows of width counters; a key's estimate is its
+// smallest counter
+type countMinSketch struct {
+	width  uint64
+	counts [][]uint32
+}
+
+func newCountMinSketch(width, depth int) *countMinSketch {
+	s := &countMinSketch{width: uint64(width), counts: make([][]uint32, depth)}
+	for i := range s.counts {
+		s.counts[i] = make([]uint32, width)
+	}
+	return s
+}
+
+// cells derives one counter per row from two halves of a 64-bit hash (Kirsch-Mitzenmacher)
+func (s *countMinSketch) cells(key string, fn func(row int, col uint64)) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	for i := range s.counts {
+		fn(i, (h1+uint64(i)*h2)%s.width)
+	}
+}
+
+// add counts key once and returns its new estimate
+func (s *countMinSketch) add(key string) uint64 {
+	est := uint64(math.MaxUint64)
+	s.cells(key, func(row int, col uint64) {
+		s.counts[row][col]++
+		if v := uint64(s.counts[row][col]); v < est {
+			est = v
+		}
+	})
+	return est
+}
+
+func (s *countMinSketch) estimate(key string) uint64 {
+	est := uint64(math.MaxUint64)
+	s.cells(key, func(row int, col uint64) {
+		if v := uint64(s.counts[row][col]); v < est {
+			est = v
+		}
+	})
+	return est
+}
+
+func (s *countMinSketch) reset() {
+	for _, row := range s.counts {
+		for i := range row {
+			row[i] = 0
+		}
+	}
+}
+
+// trafficSlice holds one slice of the window: its sketch and, per dimension, the keys with
+// the highest estimates seen in it
+type trafficSlice struct {
+	epoch      int64
+	total      uint64
+	sketch     *countMinSketch
+	candidates map[string]map[string]uint64
+}
+
+// TrafficStats keeps approximate top-K request counts by client address, route and
+// status over a sliding window, in memory bounded by the options rather than by the
+// number of distinct clients
+type TrafficStats struct {
+	k, capacity int
+	window      time.Duration
+	slice       time.Duration
+	width       int
+	now         func() time.Time
+
+	mu     sync.Mutex
+	slices []trafficSlice
+}
+
+// TopKEntry is one key and its estimated request count; Share is of the window's total
+type TopKEntry struct {
+	Key   string  `json:"key"`
+	Count uint64  `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// TrafficSnapshot is the top-K of each dimension over the window. Counts may exceed the
+// true value by up to MaxOvercount.
+type TrafficSnapshot struct {
+	Window       string      `json:"window"`
+	Total        uint64      `json:"total_requests"`
+	MaxOvercount uint64      `json:"max_overcount"`
+	Clients      []TopKEntry `json:"clients"`
+	Routes       []TopKEntry `json:"routes"`
+	Statuses     []TopKEntry `json:"statuses"`
+}
+
+// NewTrafficStats allocates every sketch up front, so memory stays flat however many
+// clients show up
+func NewTrafficStats(opts TrafficStatsOptions) *TrafficStats {
+	if opts.K <= 0 {
+		opts.K = 10
+	}
+	if opts.Window <= 0 {
+		opts.Window = 5 * time.Minute
+	}
+	if opts.Width <= 0 {
+		opts.Width = 1024
+	}
+	if opts.Depth <= 0 {
+		opts.Depth = 4
+	}
+	t := &TrafficStats{
+		k:        opts.K,
+		capacity: opts.K * 4, // headroom so keys near the cut-off are not evicted too eagerly
+		window:   opts.Window,
+		slice:    opts.Window / trafficSlices,
+		width:    opts.Width,
+		now:      time.Now,
+		slices:   make([]trafficSlice, trafficSlices),
+	}
+	if t.slice <= 0 {
+		t.slice = time.Second
+	}
+	for i := range t.slices {
+		t.slices[i] = trafficSlice{epoch: -1, sketch: newCountMinSketch(opts.Width, opts.Depth)}
+	}
+	return t
+}
+
+// Middleware counts every request once it has completed, by ClientAddress, method and
+// route template, and status code. It must run inside ClientIP.
+func (t *TrafficStats) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wrapped := newStatusRecorder(w)
+			next.ServeHTTP(wrapped, r)
+			t.record(ClientAddress(r), r.Method+" "+RouteTemplate(r), strconv.Itoa(wrapped.statusCode))
+		})
+	}
+}
+
+func (t *TrafficStats) record(client, route, status string) {
+	epoch := t.now().UnixNano() / int64(t.slice)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := &t.slices[epoch%int64(len(t.slices))]
+	if s.epoch != epoch {
+		s.epoch, s.total = epoch, 0
+		s.sketch.reset()
+		s.candidates = map[string]map[string]uint64{}
+		for _, dim := range trafficDimensions {
+			s.candidates[dim] = map[string]uint64{}
+		}
+	}
+	s.total++
+	for i, key := range [...]string{client, route, status} {
+		dim := trafficDimensions[i]
+		t.offer(s.candidates[dim], key, s.sketch.add(dim+" "+key))
+	}
+}
+
+// offer keeps key as a candidate if there is room or it now outranks the weakest one
+func (t *TrafficStats) offer(candidates map[string]uint64, key string, est uint64) {
+	if _, ok := candidates[key]; ok || len(candidates) < t.capacity {
+		candidates[key] = est
+		return
+	}
+	weakest, lowest := "", uint64(math.MaxUint64)
+	for k, v := range candidates {
+		if v < lowest {
+			weakest, lowest = k, v
+		}
+	}
+	if est > lowest {
+		delete(candidates, weakest)
+		candidates[key] = est
+	}
+}
+
+// Snapshot reports up to k keys per dimension (at most the configured K), summing each
+// candidate's estimates over the slices still inside the window
+func (t *TrafficStats) Snapshot(k int) TrafficSnapshot {
+	if k <= 0 || k > t.k {
+		k = t.k
+	}
+	epoch := t.now().UnixNano() / int64(t.slice)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var live []*trafficSlice
+	snap := TrafficSnapshot{Window: t.window.String()}
+	for i := range t.slices {
+		if s := &t.slices[i]; s.epoch > epoch-int64(len(t.slices)) && s.epoch <= epoch {
+			live = append(live, s)
+			snap.Total += s.total
+		}
+	}
+	snap.MaxOvercount = uint64(math.Ceil(math.E / float64(t.width) * float64(snap.Total)))
+
+	top := func(dim string) []TopKEntry {
+		counts := map[string]uint64{}
+		for _, s := range live {
+			for key := range s.candidates[dim] {
+				if _, done := counts[key]; done {
+					continue
+				}
+				for _, other := range live {
+					counts[key] += other.sketch.estimate(dim + " " + key)
+				}
+			}
+		}
+		entries := make([]TopKEntry, 0, len(counts))
+		for key, n := range counts {
+			entries = append(entries, TopKEntry{Key: key, Count: n, Share: float64(n) / float64(snap.Total)})
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Count != entries[j].Count {
+				return entries[i].Count > entries[j].Count
+			}
+			return entries[i].Key < entries[j].Key
+		})
+		if len(entries) > k {
+			entries = entries[:k]
+		}
+		return entries
+	}
+	snap.Clients = top(trafficClient)
+	snap.Routes = top(trafficRoute)
+	snap.Statuses = top(trafficStatus)
+	return snap
+}
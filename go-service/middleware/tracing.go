@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing starts a server span per request named after the mux route and records the
+// negotiated protocol on it
+func Tracing(service string) Middleware {
+	traced := otelmux.Middleware(service)
+	return func(next http.Handler) http.Handler {
+		return traced(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			version := "1.1"
+			if r.ProtoMajor == 2 {
+				version = "2"
+			} else if r.ProtoMinor == 0 {
+				version = "1.0"
+			}
+			trace.SpanFromContext(r.Context()).SetAttributes(
+				semconv.NetworkProtocolName("http"),
+				semconv.NetworkProtocolVersion(version),
+				attribute.String("http.protocol", Protocol(r)),
+			)
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// TraceResponse returns the server span's trace context to the client so a trace
+// can be looked up straight from curl output or the browser devtools network tab.
+// It must run inside the tracing middleware so the span already exists.
+func TraceResponse(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc := trace.SpanContextFromContext(r.Context())
+		if sc.IsValid() {
+			traceparent := fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags())
+			h := w.Header()
+			h.Set("X-Trace-Id", sc.TraceID().String())
+			// W3C Trace Context Level 2 response header
+			h.Set("traceresponse", traceparent)
+			// Exposes the trace to browser Performance APIs (PerformanceServerTiming)
+			h.Add("Server-Timing", fmt.Sprintf("traceparent;desc=%q", traceparent))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,46 @@
+package obs
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+
+	"go-service/internal/env"
+)
+
+// baggageKeys are the baggage members copied into log fields and span attributes.
+// Baggage is caller-controlled, so only allowlisted keys are ever copied.
+var baggageKeys = env.List("BAGGAGE_LOG_KEYS", "user.tier,demo.flag,scenario.name")
+
+// BaggageFields returns the allowlisted baggage members present in ctx, or nil if there are none
+func BaggageFields(ctx context.Context) map[string]interface{} {
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return nil
+	}
+	var fields map[string]interface{}
+	for _, key := range baggageKeys {
+		m := bag.Member(key)
+		if m.Key() == "" {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]interface{}, len(baggageKeys))
+		}
+		fields[key] = m.Value()
+	}
+	return fields
+}
+
+// BaggageAttributes returns the allowlisted baggage members in ctx as span attributes
+func BaggageAttributes(ctx context.Context) []attribute.KeyValue {
+	fields := BaggageFields(ctx)
+	attrs := make([]attribute.KeyValue, 0, len(fields))
+	for _, key := range baggageKeys {
+		if v, ok := fields[key]; ok {
+			attrs = append(attrs, attribute.String(key, v.(string)))
+		}
+	}
+	return attrs
+}
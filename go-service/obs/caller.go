@@ -0,0 +1,94 @@
+package obs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+
+	"go-service/internal/env"
+)
+
+// logStackTraces adds a "stacktrace" field to ERROR records; FATAL records always get one
+var logStackTraces = env.Bool("LOG_STACKTRACE", false)
+
+// logFuncs are the entry points skipped when looking for the code that logged
+var logFuncs = map[string]bool{}
+
+func init() {
+	for _, name := range []string{
+		"logEntry", "Log", "Debug", "Info", "Warn", "Error", "Fatal",
+		"LogContext", "DebugContext", "InfoContext", "WarnContext", "ErrorContext", "FatalContext",
+	} {
+		logFuncs["go-service/obs."+name] = true
+	}
+}
+
+// exit is os.Exit, replaced in tests
+var exit = os.Exit
+
+// callerFields returns the "caller" of a log call as dir/file.go:line and, if withStack,
+// the goroutine's stack from there as "stacktrace"
+func callerFields(withStack bool) map[string]interface{} {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	out := map[string]interface{}{}
+	var stack strings.Builder
+	for {
+		frame, more := frames.Next()
+		if !logFuncs[frame.Function] {
+			if _, ok := out["caller"]; !ok {
+				out["caller"] = shortPath(frame.File) + ":" + strconv.Itoa(frame.Line)
+				if !withStack {
+					return out
+				}
+			}
+			stack.WriteString(frame.Function + "\n\t" + frame.File + ":" + strconv.Itoa(frame.Line) + "\n")
+		}
+		if !more {
+			break
+		}
+	}
+	if withStack && stack.Len() > 0 {
+		out["stacktrace"] = stack.String()
+	}
+	return out
+}
+
+// shortPath keeps the last directory of a source path, e.g. go-service/obs/log.go -> obs/log.go
+func shortPath(file string) string {
+	return filepath.Join(filepath.Base(filepath.Dir(file)), filepath.Base(file))
+}
+
+// Fatal logs at FATAL, whatever LOG_LEVEL, with the caller and a stack trace, then flushes
+// the global tracer and meter providers and the log writer and exits with status 1
+func Fatal(message string, fields map[string]interface{}) {
+	Log("FATAL", message, fields)
+	flushAndExit()
+}
+
+// FatalContext is Fatal with request context, as LogContext
+func FatalContext(ctx context.Context, message string, fields map[string]interface{}) {
+	LogContext(ctx, "FATAL", message, fields)
+	flushAndExit()
+}
+
+func flushAndExit() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if tp, ok := otel.GetTracerProvider().(*tracesdk.TracerProvider); ok {
+		tp.Shutdown(ctx)
+	}
+	if mp, ok := otel.GetMeterProvider().(*metricsdk.MeterProvider); ok {
+		mp.Shutdown(ctx)
+	}
+	currentLogWriter().Close(ctx)
+	exit(1)
+}
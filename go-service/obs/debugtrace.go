@@ -0,0 +1,33 @@
+package obs
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type debugKey struct{}
+
+// WithDebug marks ctx for debug tracing: spans started from it are sampled whatever the
+// configured sampler decides, and records logged with it skip LOG_LEVEL and log sampling
+func WithDebug(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugKey{}, true)
+}
+
+// DebugEnabled reports whether ctx was marked by WithDebug
+func DebugEnabled(ctx context.Context) bool {
+	on, _ := ctx.Value(debugKey{}).(bool)
+	return on
+}
+
+// debugSampled is the decision for spans started from a WithDebug context; the attribute
+// tells forced traces apart from ones kept by the regular sampler
+func debugSampled(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	return tracesdk.SamplingResult{
+		Decision:   tracesdk.RecordAndSample,
+		Attributes: []attribute.KeyValue{attribute.Bool("sampling.debug", true)},
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
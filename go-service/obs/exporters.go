@@ -0,0 +1,112 @@
+package obs
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+
+	"go-service/internal/env"
+)
+
+const (
+	exporterOTLP    = "otlp"
+	exporterConsole = "console"
+	exporterNone    = "none"
+)
+
+// exporterKinds reads OTEL_TRACES_EXPORTER / OTEL_METRICS_EXPORTER, a comma-separated
+// list defaulting to otlp, e.g. "otlp,console" to export to both. Duplicates and
+// unsupported entries are dropped; "none" (or an empty result) disables the signal.
+func exporterKinds(envKey string) []string {
+	var kinds []string
+	seen := map[string]bool{}
+	for _, kind := range env.List(envKey, exporterOTLP) {
+		kind = strings.ToLower(kind)
+		switch kind {
+		case exporterOTLP, exporterConsole:
+			if !seen[kind] {
+				seen[kind] = true
+				kinds = append(kinds, kind)
+			}
+		case exporterNone:
+		default:
+			Warn("Unsupported exporter, ignoring", map[string]interface{}{
+				"env":   envKey,
+				"value": kind,
+			})
+		}
+	}
+	return kinds
+}
+
+// exporterDescription is the Settings form of a list of exporter kinds
+func exporterDescription(kinds []string) string {
+	if len(kinds) == 0 {
+		return exporterNone
+	}
+	return strings.Join(kinds, ",")
+}
+
+func hasExporter(kinds []string, kind string) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// newSpanExporters returns one span exporter per kind, and one per collector for otlp.
+// OTLP exporters are created (and recreated) in the background so a missing collector never disables tracing.
+// Console output goes to stderr so stdout stays a clean stream of JSON log lines
+func newSpanExporters(ctx context.Context, kinds []string, otlps []otlpConfig) ([]namedSpanExporter, error) {
+	var exporters []namedSpanExporter
+	for _, kind := range kinds {
+		if kind == exporterConsole {
+			exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stderr), stdouttrace.WithPrettyPrint())
+			if err != nil {
+				return nil, err
+			}
+			exporters = append(exporters, namedSpanExporter{exporterConsole, exp})
+			continue
+		}
+		for _, otlp := range otlps {
+			otlp := otlp
+			exporters = append(exporters, namedSpanExporter{otlp.Endpoint, newReconnectingSpanExporter(otlp.Endpoint, func(ctx context.Context) (tracesdk.SpanExporter, error) {
+				return newTraceExporter(ctx, otlp)
+			})})
+		}
+	}
+	return exporters, nil
+}
+
+// newMetricsExporters returns one metric exporter per kind, and one per collector for otlp
+func newMetricsExporters(ctx context.Context, kinds []string, otlps []otlpConfig, temporality metricsdk.TemporalitySelector) ([]metricsdk.Exporter, error) {
+	var exporters []metricsdk.Exporter
+	for _, kind := range kinds {
+		if kind == exporterConsole {
+			exp, err := stdoutmetric.New(
+				stdoutmetric.WithWriter(os.Stderr),
+				stdoutmetric.WithPrettyPrint(),
+				stdoutmetric.WithTemporalitySelector(temporality),
+			)
+			if err != nil {
+				return nil, err
+			}
+			exporters = append(exporters, exp)
+			continue
+		}
+		for _, otlp := range otlps {
+			otlp := otlp
+			exporters = append(exporters, newReconnectingMetricExporter(otlp.Endpoint, temporality, func(ctx context.Context) (metricsdk.Exporter, error) {
+				return newMetricExporter(ctx, otlp, temporality)
+			}))
+		}
+	}
+	return exporters, nil
+}
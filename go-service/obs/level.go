@@ -0,0 +1,57 @@
+package obs
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"go-service/internal/env"
+)
+
+// levelSeverity orders the levels accepted by LOG_LEVEL and SetLevel
+var levelSeverity = map[string]int32{
+	"DEBUG": 0,
+	"INFO":  1,
+	"WARN":  2,
+	"ERROR": 3,
+	"FATAL": 4,
+}
+
+// minLevel is the severity below which records are discarded
+var minLevel atomic.Int32
+
+func init() {
+	if err := SetLevel(env.String("LOG_LEVEL", "INFO")); err != nil {
+		SetLevel("INFO")
+		Warn("Invalid LOG_LEVEL, using INFO", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// SetLevel changes the minimum level written; it is safe to call at any time
+func SetLevel(level string) error {
+	sev, ok := levelSeverity[strings.ToUpper(strings.TrimSpace(level))]
+	if !ok {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	minLevel.Store(sev)
+	return nil
+}
+
+// Level returns the current minimum level
+func Level() string {
+	sev := minLevel.Load()
+	for name, s := range levelSeverity {
+		if s == sev {
+			return name
+		}
+	}
+	return "INFO"
+}
+
+// enabled reports whether records at level pass the LOG_LEVEL threshold; unknown levels always do
+func enabled(level string) bool {
+	sev, ok := levelSeverity[level]
+	return !ok || sev >= minLevel.Load()
+}
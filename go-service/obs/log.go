@@ -0,0 +1,81 @@
+package obs
+
+import (
+	stdlog "log"
+	"os"
+	"time"
+
+	"go-service/internal/env"
+)
+
+// LogEntry represents a structured log entry - Consistent format across all services
+type LogEntry map[string]interface{}
+
+var (
+	// service is reported in every log line; Init replaces it with Config.ServiceName
+	service = env.String("OTEL_SERVICE_NAME", "go-service")
+	logger  = stdlog.New(os.Stdout, "", 0)
+)
+
+// Log creates a structured log entry with consistent format
+// Core fields at top level, request/context fields nested in "fields" object
+func Log(level, message string, additionalFields map[string]interface{}) {
+	logEntry(level, message, additionalFields, false)
+}
+
+// logEntry is Log; force bypasses the level threshold and log sampling for debug requests
+func logEntry(level, message string, additionalFields map[string]interface{}, force bool) {
+	if !force && (!enabled(level) || memory.Load().shedLog(level) || !defaultLogSampler.allow(level, message)) {
+		return
+	}
+
+	entry := LogEntry{
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+		"level":     level,
+		"service":   service,
+		"message":   message,
+	}
+
+	// Nest additional fields in "fields" object (consistent structure)
+	var fields map[string]interface{}
+	if len(additionalFields) > 0 {
+		fields = defaultRedactor.redact(additionalFields)
+	}
+	// Errors say where they were logged from; caller and stack never replace a field of the same name
+	if sev := levelSeverity[level]; sev >= levelSeverity["ERROR"] {
+		for k, v := range callerFields(level == "FATAL" || logStackTraces) {
+			if fields == nil {
+				fields = map[string]interface{}{}
+			}
+			if _, ok := fields[k]; !ok {
+				fields[k] = v
+			}
+		}
+	}
+	if fields != nil {
+		entry["fields"] = fields
+	}
+
+	// Marshaling and writing happen on the log writer goroutine
+	currentLogWriter().enqueue(logRecord{Level: level, Entry: entry})
+}
+
+// Info logs at INFO level
+func Info(message string, fields map[string]interface{}) {
+	Log("INFO", message, fields)
+}
+
+// Warn logs at WARN level
+func Warn(message string, fields map[string]interface{}) {
+	Log("WARN", message, fields)
+}
+
+// Error logs at ERROR level
+func Error(message string, fields map[string]interface{}) {
+	Log("ERROR", message, fields)
+}
+
+// Debug logs at DEBUG level
+func Debug(message string, fields map[string]interface{}) {
+	Log("DEBUG", message, fields)
+}
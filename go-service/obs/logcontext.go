@@ -0,0 +1,86 @@
+package obs
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/internal/env"
+)
+
+// logSpanEvents attaches WARN, ERROR and FATAL records logged with a context to the active span,
+// so a trace carries its own error details without a log datasource
+var logSpanEvents = env.Bool("LOG_SPAN_EVENTS", true)
+
+// LogContext is Log with request context: the span in ctx is added as trace_id and span_id
+// (unless fields already name a trace), allowlisted baggage members under "baggage",
+// WARN/ERROR records become events on the recording span, and every level, DEBUG
+// included, is written for WithDebug contexts
+func LogContext(ctx context.Context, level, message string, fields map[string]interface{}) {
+	sc := trace.SpanContextFromContext(ctx)
+	_, hasTrace := fields["trace_id"]
+	addTrace := sc.IsValid() && !hasTrace
+	if bag := BaggageFields(ctx); bag != nil || addTrace {
+		withContext := make(map[string]interface{}, len(fields)+3)
+		for k, v := range fields {
+			withContext[k] = v
+		}
+		if bag != nil {
+			withContext["baggage"] = bag
+		}
+		if addTrace {
+			withContext["trace_id"] = sc.TraceID().String()
+			withContext["span_id"] = sc.SpanID().String()
+		}
+		fields = withContext
+	}
+	if logSpanEvents && levelSeverity[level] >= levelSeverity["WARN"] {
+		if span := trace.SpanFromContext(ctx); span.IsRecording() {
+			span.AddEvent(message, trace.WithAttributes(logEventAttributes(level, fields)...))
+		}
+	}
+	logEntry(level, message, fields, DebugEnabled(ctx))
+}
+
+// logEventAttributes converts redacted log fields into span event attributes
+func logEventAttributes(level string, fields map[string]interface{}) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(fields)+1)
+	attrs = append(attrs, attribute.String("log.severity", level))
+	redacted := defaultRedactor.redact(fields)
+	for _, key := range sortedKeys(redacted) {
+		switch v := redacted[key].(type) {
+		case string:
+			attrs = append(attrs, attribute.String(key, v))
+		case bool:
+			attrs = append(attrs, attribute.Bool(key, v))
+		case int:
+			attrs = append(attrs, attribute.Int(key, v))
+		case int64:
+			attrs = append(attrs, attribute.Int64(key, v))
+		case float64:
+			attrs = append(attrs, attribute.Float64(key, v))
+		case []string:
+			attrs = append(attrs, attribute.StringSlice(key, v))
+		default:
+			attrs = append(attrs, attribute.String(key, fieldString(v)))
+		}
+	}
+	return attrs
+}
+
+func InfoContext(ctx context.Context, message string, fields map[string]interface{}) {
+	LogContext(ctx, "INFO", message, fields)
+}
+
+func WarnContext(ctx context.Context, message string, fields map[string]interface{}) {
+	LogContext(ctx, "WARN", message, fields)
+}
+
+func ErrorContext(ctx context.Context, message string, fields map[string]interface{}) {
+	LogContext(ctx, "ERROR", message, fields)
+}
+
+func DebugContext(ctx context.Context, message string, fields map[string]interface{}) {
+	LogContext(ctx, "DEBUG", message, fields)
+}
@@ -0,0 +1,33 @@
+package obs
+
+import (
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"go-service/internal/env"
+)
+
+// fileSink writes JSON lines to a size-rotated file
+type fileSink struct {
+	w *lumberjack.Logger
+}
+
+func newFileSink() *fileSink {
+	return &fileSink{w: &lumberjack.Logger{
+		Filename:   env.String("LOG_FILE", service+".log"),
+		MaxSize:    env.Int("LOG_FILE_MAX_SIZE_MB", 100),
+		MaxBackups: env.Int("LOG_FILE_MAX_BACKUPS", 5),
+		MaxAge:     env.Int("LOG_FILE_MAX_AGE_DAYS", 7),
+		Compress:   env.Bool("LOG_FILE_COMPRESS", false),
+	}}
+}
+
+func (s *fileSink) Write(rec logRecord) error {
+	line := make([]byte, 0, len(rec.Line)+1)
+	line = append(append(line, rec.Line...), '\n')
+	_, err := s.w.Write(line)
+	return err
+}
+
+func (s *fileSink) Close() error {
+	return s.w.Close()
+}
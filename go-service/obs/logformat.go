@@ -0,0 +1,216 @@
+package obs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go-service/internal/env"
+)
+
+// logSerializer renders an entry as the line written by the stdout, file and Loki sinks
+type logSerializer interface {
+	Serialize(entry LogEntry) ([]byte, error)
+}
+
+// logFormats are the LOG_FORMAT choices; every one renders the same entry
+var logFormats = map[string]logSerializer{
+	"json":   jsonFormat{},
+	"ecs":    ecsFormat{},
+	"gcp":    gcpFormat{project: env.String("GOOGLE_CLOUD_PROJECT", "")},
+	"logfmt": logfmtFormat{},
+}
+
+// entryFields splits an entry into its core values and a copy of its nested fields
+func entryFields(entry LogEntry) (timestamp, level, service, message string, fields map[string]interface{}) {
+	fields = map[string]interface{}{}
+	for k, v := range recordFields(entry) {
+		fields[k] = v
+	}
+	return fieldString(entry["timestamp"]), fieldString(entry["level"]), fieldString(entry["service"]), fieldString(entry["message"]), fields
+}
+
+// takeField removes key from fields, reporting whether it was present
+func takeField(fields map[string]interface{}, key string) (interface{}, bool) {
+	v, ok := fields[key]
+	delete(fields, key)
+	return v, ok
+}
+
+// splitCaller takes the "caller" field added to ERROR and FATAL records, as file and line
+func splitCaller(fields map[string]interface{}) (file string, line int, ok bool) {
+	caller, isString := fields["caller"].(string)
+	i := strings.LastIndexByte(caller, ':')
+	if !isString || i < 0 {
+		return "", 0, false
+	}
+	line, err := strconv.Atoi(caller[i+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	delete(fields, "caller")
+	return caller[:i], line, true
+}
+
+// jsonFormat is the service's own layout: core fields at the top, the rest under "fields"
+type jsonFormat struct{}
+
+func (jsonFormat) Serialize(entry LogEntry) ([]byte, error) {
+	return json.Marshal(entry)
+}
+
+// ecsFieldNames maps well-known field names to their Elastic Common Schema equivalents
+var ecsFieldNames = map[string]string{
+	"request_id":     "http.request.id",
+	"method":         "http.request.method",
+	"status":         "http.response.status_code",
+	"path":           "url.path",
+	"scheme":         "url.scheme",
+	"user_agent":     "user_agent.original",
+	"client_address": "client.ip",
+	"remote_addr":    "source.address",
+	"trace_id":       "trace.id",
+	"span_id":        "span.id",
+	"error":          "error.message",
+	"stacktrace":     "error.stack_trace",
+}
+
+// ecsFormat follows the ECS logging spec (dotted top-level keys, ecs.version 8.11);
+// fields without an ECS mapping stay under "fields"
+type ecsFormat struct{}
+
+func (ecsFormat) Serialize(entry LogEntry) ([]byte, error) {
+	timestamp, level, service, message, fields := entryFields(entry)
+	out := map[string]interface{}{
+		"@timestamp":   timestamp,
+		"log.level":    strings.ToLower(level),
+		"message":      message,
+		"service.name": service,
+		"ecs.version":  "8.11.0",
+	}
+	for name, ecsName := range ecsFieldNames {
+		if v, ok := takeField(fields, name); ok {
+			out[ecsName] = v
+		}
+	}
+	if v, ok := takeField(fields, "duration_seconds"); ok {
+		if seconds, ok := v.(float64); ok {
+			out["event.duration"] = int64(seconds * 1e9)
+		}
+	}
+	if file, line, ok := splitCaller(fields); ok {
+		out["log.origin.file.name"] = file
+		out["log.origin.file.line"] = line
+	}
+	if len(fields) > 0 {
+		out["fields"] = fields
+	}
+	return json.Marshal(out)
+}
+
+// gcpSeverities maps levels to Cloud Logging LogSeverity names
+var gcpSeverities = map[string]string{
+	"DEBUG": "DEBUG",
+	"INFO":  "INFO",
+	"WARN":  "WARNING",
+	"ERROR": "ERROR",
+	"FATAL": "CRITICAL",
+}
+
+// gcpFormat is Google Cloud structured logging: severity, the special trace/span keys and
+// an httpRequest object for request logs. GOOGLE_CLOUD_PROJECT is needed to link traces.
+type gcpFormat struct {
+	project string
+}
+
+func (f gcpFormat) Serialize(entry LogEntry) ([]byte, error) {
+	timestamp, level, service, message, fields := entryFields(entry)
+	severity, ok := gcpSeverities[level]
+	if !ok {
+		severity = "DEFAULT"
+	}
+	out := map[string]interface{}{
+		"time":                          timestamp,
+		"severity":                      severity,
+		"message":                       message,
+		"serviceContext":                map[string]string{"service": service},
+		"logging.googleapis.com/labels": map[string]string{"service": service},
+	}
+	if traceID, ok := fields["trace_id"].(string); ok && f.project != "" {
+		delete(fields, "trace_id")
+		out["logging.googleapis.com/trace"] = "projects/" + f.project + "/traces/" + traceID
+	}
+	if spanID, ok := takeField(fields, "span_id"); ok {
+		out["logging.googleapis.com/spanId"] = spanID
+	}
+	if file, line, ok := splitCaller(fields); ok {
+		out["logging.googleapis.com/sourceLocation"] = map[string]interface{}{"file": file, "line": strconv.Itoa(line)}
+	}
+
+	if method, ok := takeField(fields, "method"); ok {
+		req := map[string]interface{}{"requestMethod": method}
+		for name, gcpName := range map[string]string{"path": "requestUrl", "status": "status", "user_agent": "userAgent", "client_address": "remoteIp"} {
+			if v, ok := takeField(fields, name); ok {
+				req[gcpName] = v
+			}
+		}
+		if v, ok := takeField(fields, "duration_seconds"); ok {
+			if seconds, ok := v.(float64); ok {
+				req["latency"] = strconv.FormatFloat(seconds, 'f', 9, 64) + "s"
+			}
+		}
+		out["httpRequest"] = req
+	}
+	if len(fields) > 0 {
+		out["fields"] = fields
+	}
+	return json.Marshal(out)
+}
+
+// logfmtFormat writes key=value pairs: the core keys first, then the fields sorted with
+// nested maps flattened into dotted keys
+type logfmtFormat struct{}
+
+func (logfmtFormat) Serialize(entry LogEntry) ([]byte, error) {
+	timestamp, level, service, message, fields := entryFields(entry)
+	var b bytes.Buffer
+	writeLogfmtPair(&b, "time", timestamp)
+	writeLogfmtPair(&b, "level", strings.ToLower(level))
+	writeLogfmtPair(&b, "service", service)
+	writeLogfmtPair(&b, "msg", message)
+	writeLogfmtFields(&b, "", fields)
+	return b.Bytes(), nil
+}
+
+func writeLogfmtFields(b *bytes.Buffer, prefix string, fields map[string]interface{}) {
+	for _, key := range sortedKeys(fields) {
+		if nested, ok := fields[key].(map[string]interface{}); ok {
+			writeLogfmtFields(b, prefix+key+".", nested)
+			continue
+		}
+		writeLogfmtPair(b, prefix+key, fieldString(fields[key]))
+	}
+}
+
+func writeLogfmtPair(b *bytes.Buffer, key, value string) {
+	if b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	b.WriteString(key)
+	b.WriteByte('=')
+	if value == "" || strings.ContainsAny(value, " =\"\\") || strings.IndexFunc(value, func(r rune) bool { return r < 0x20 }) >= 0 {
+		b.WriteString(strconv.Quote(value))
+		return
+	}
+	b.WriteString(value)
+}
+
+// newLogSerializer returns the LOG_FORMAT serializer, falling back to json for unknown names
+func newLogSerializer(name string) (logSerializer, error) {
+	if f, ok := logFormats[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+	return jsonFormat{}, fmt.Errorf("unsupported LOG_FORMAT %q", name)
+}
@@ -0,0 +1,164 @@
+package obs
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func requestEntry() LogEntry {
+	return LogEntry{
+		"timestamp": "2024-01-02T03:04:05Z",
+		"level":     "WARN",
+		"service":   "go-service",
+		"message":   "HTTP request",
+		"fields": map[string]interface{}{
+			"method":           "GET",
+			"path":             "/users/1",
+			"status":           404,
+			"duration_seconds": 0.25,
+			"trace_id":         "4bf92f3577b34da6a3ce929d0e0e4736",
+			"span_id":          "00f067aa0ba902b7",
+			"route":            "/users/{id}",
+			"db":               map[string]interface{}{"rows": 0},
+			"caller":           "middleware/logging.go:42",
+		},
+	}
+}
+
+func serializeJSON(t *testing.T, f logSerializer) map[string]interface{} {
+	t.Helper()
+	b, err := f.Serialize(requestEntry())
+	if err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, b)
+	}
+	return out
+}
+
+func TestJSONFormatKeepsServiceLayout(t *testing.T) {
+	out := serializeJSON(t, jsonFormat{})
+	fields, _ := out["fields"].(map[string]interface{})
+	if out["level"] != "WARN" || out["message"] != "HTTP request" || fields["route"] != "/users/{id}" {
+		t.Errorf("unexpected JSON layout: %v", out)
+	}
+}
+
+func TestECSFormatMapsFields(t *testing.T) {
+	out := serializeJSON(t, ecsFormat{})
+	want := map[string]interface{}{
+		"@timestamp":                "2024-01-02T03:04:05Z",
+		"log.level":                 "warn",
+		"service.name":              "go-service",
+		"ecs.version":               "8.11.0",
+		"http.request.method":       "GET",
+		"http.response.status_code": float64(404),
+		"url.path":                  "/users/1",
+		"trace.id":                  "4bf92f3577b34da6a3ce929d0e0e4736",
+		"event.duration":            float64(250000000),
+		"log.origin.file.name":      "middleware/logging.go",
+		"log.origin.file.line":      float64(42),
+	}
+	for k, v := range want {
+		if out[k] != v {
+			t.Errorf("%s = %v, want %v", k, out[k], v)
+		}
+	}
+	fields, _ := out["fields"].(map[string]interface{})
+	if fields["route"] != "/users/{id}" || fields["method"] != nil {
+		t.Errorf("unmapped fields should stay under fields, mapped ones should not: %v", fields)
+	}
+}
+
+func TestGCPFormatUsesSpecialKeys(t *testing.T) {
+	out := serializeJSON(t, gcpFormat{project: "demo"})
+	if out["severity"] != "WARNING" {
+		t.Errorf("severity = %v, want WARNING", out["severity"])
+	}
+	if out["logging.googleapis.com/trace"] != "projects/demo/traces/4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace = %v", out["logging.googleapis.com/trace"])
+	}
+	if out["logging.googleapis.com/spanId"] != "00f067aa0ba902b7" {
+		t.Errorf("spanId = %v", out["logging.googleapis.com/spanId"])
+	}
+	if loc, _ := out["logging.googleapis.com/sourceLocation"].(map[string]interface{}); loc["file"] != "middleware/logging.go" || loc["line"] != "42" {
+		t.Errorf("sourceLocation = %v", loc)
+	}
+	req, _ := out["httpRequest"].(map[string]interface{})
+	if req["requestMethod"] != "GET" || req["status"] != float64(404) || req["latency"] != "0.250000000s" {
+		t.Errorf("httpRequest = %v", req)
+	}
+
+	// Without a project the trace ID cannot be linked and stays a plain field
+	out = serializeJSON(t, gcpFormat{})
+	fields, _ := out["fields"].(map[string]interface{})
+	if _, ok := out["logging.googleapis.com/trace"]; ok || fields["trace_id"] == nil {
+		t.Errorf("trace_id should stay in fields without GOOGLE_CLOUD_PROJECT: %v", out)
+	}
+}
+
+func TestLogfmtFormatFlattensAndQuotes(t *testing.T) {
+	b, err := logfmtFormat{}.Serialize(requestEntry())
+	if err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	line := string(b)
+	if !strings.HasPrefix(line, `time=2024-01-02T03:04:05Z level=warn service=go-service msg="HTTP request" `) {
+		t.Errorf("core keys missing or out of order: %s", line)
+	}
+	for _, want := range []string{"db.rows=0", "duration_seconds=0.25", "route=/users/{id}", "status=404"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("missing %q in %s", want, line)
+		}
+	}
+	if strings.Index(line, "db.rows=") > strings.Index(line, "status=") {
+		t.Errorf("fields should be sorted: %s", line)
+	}
+}
+
+func TestNewLogSerializerFallsBackToJSON(t *testing.T) {
+	if f, err := newLogSerializer("ECS"); err != nil || f != (ecsFormat{}) {
+		t.Errorf("ECS should select ecsFormat, got %T %v", f, err)
+	}
+	f, err := newLogSerializer("xml")
+	if err == nil || f != (jsonFormat{}) {
+		t.Errorf("unknown format should fall back to json with an error, got %T %v", f, err)
+	}
+}
+
+func TestContextLogsCarryTraceForECSAndGCP(t *testing.T) {
+	tp := tracesdk.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+	ctx, span := tp.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	traceID, spanID := span.SpanContext().TraceID().String(), span.SpanContext().SpanID().String()
+
+	for _, tt := range []struct {
+		format    logSerializer
+		traceKey  string
+		wantTrace string
+		spanKey   string
+	}{
+		{ecsFormat{}, "trace.id", traceID, "span.id"},
+		{gcpFormat{project: "demo"}, "logging.googleapis.com/trace", "projects/demo/traces/" + traceID, "logging.googleapis.com/spanId"},
+	} {
+		buf := captureLogs(t)
+		previous := defaultLogWriter.Swap(newLogWriter([]logSink{stdoutSink{}}, tt.format, false, 0))
+		InfoContext(ctx, "handled", nil)
+		defaultLogWriter.Store(previous)
+
+		var out map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			t.Fatalf("%T output is not JSON: %v\n%s", tt.format, err, buf)
+		}
+		if out[tt.traceKey] != tt.wantTrace || out[tt.spanKey] != spanID {
+			t.Errorf("%T: %s = %v, %s = %v, want %s and %s", tt.format, tt.traceKey, out[tt.traceKey], tt.spanKey, out[tt.spanKey], tt.wantTrace, spanID)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package obs
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-service/internal/env"
+)
+
+var logRecordsDropped = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "log_records_dropped_total",
+		Help: "Total number of log records dropped before being written",
+	},
+	[]string{"level", "reason"},
+)
+
+func init() {
+	Registry.MustRegister(logRecordsDropped)
+}
+
+// logSampler lets the first Initial records per level+message through each second,
+// then every Thereafter-th one. Levels not in Levels are never sampled.
+type logSampler struct {
+	Initial    int
+	Thereafter int
+	Levels     map[string]bool
+
+	mu      sync.Mutex
+	windows map[string]*logWindow
+	now     func() time.Time
+}
+
+type logWindow struct {
+	second int64
+	count  int
+}
+
+var defaultLogSampler = newLogSampler(
+	env.Int("LOG_SAMPLING_INITIAL", 100),
+	env.Int("LOG_SAMPLING_THEREAFTER", 100),
+	env.String("LOG_SAMPLING_LEVELS", "DEBUG,INFO"),
+)
+
+func newLogSampler(initial, thereafter int, levels string) *logSampler {
+	s := &logSampler{
+		Initial:    initial,
+		Thereafter: thereafter,
+		Levels:     make(map[string]bool),
+		windows:    make(map[string]*logWindow),
+		now:        time.Now,
+	}
+	for _, level := range env.Split(levels) {
+		s.Levels[strings.ToUpper(level)] = true
+	}
+	return s
+}
+
+// allow reports whether a record should be written, counting it as dropped otherwise
+func (s *logSampler) allow(level, message string) bool {
+	if s.Initial <= 0 || !s.Levels[level] {
+		return true
+	}
+	now := s.now().Unix()
+	key := level + "\x00" + message
+
+	s.mu.Lock()
+	w, ok := s.windows[key]
+	if !ok {
+		w = &logWindow{}
+		s.windows[key] = w
+	}
+	if w.second != now {
+		w.second, w.count = now, 0
+	}
+	w.count++
+	n := w.count
+	s.mu.Unlock()
+
+	if n <= s.Initial || (s.Thereafter > 0 && (n-s.Initial)%s.Thereafter == 0) {
+		return true
+	}
+	logRecordsDropped.WithLabelValues(level, "sampled").Inc()
+	return false
+}
@@ -0,0 +1,46 @@
+package obs
+
+import (
+	"testing"
+	"time"
+)
+
+// fixedClock pins the sampler to a single one-second window
+func fixedClock(s *logSampler) *logSampler {
+	at := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return at }
+	return s
+}
+
+func TestLogSamplerInitialThenThereafter(t *testing.T) {
+	s := fixedClock(newLogSampler(3, 5, "INFO"))
+
+	var allowed []int
+	for i := 1; i <= 20; i++ {
+		if s.allow("INFO", "burst") {
+			allowed = append(allowed, i)
+		}
+	}
+	// first 3, then every 5th after that: 8, 13, 18
+	want := []int{1, 2, 3, 8, 13, 18}
+	if len(allowed) != len(want) {
+		t.Fatalf("allowed %v, want %v", allowed, want)
+	}
+	for i := range want {
+		if allowed[i] != want[i] {
+			t.Fatalf("allowed %v, want %v", allowed, want)
+		}
+	}
+}
+
+func TestLogSamplerSkipsUnsampledLevels(t *testing.T) {
+	s := fixedClock(newLogSampler(1, 0, "INFO"))
+	for i := 0; i < 10; i++ {
+		if !s.allow("ERROR", "boom") {
+			t.Fatal("ERROR records must never be sampled out")
+		}
+	}
+	if !s.allow("INFO", "once") || s.allow("INFO", "once") {
+		t.Error("INFO should allow exactly one record per second")
+	}
+}
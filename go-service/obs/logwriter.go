@@ -0,0 +1,199 @@
+package obs
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-service/internal/env"
+)
+
+// logRecord is one entry on its way to the sinks; Line is the entry rendered in LOG_FORMAT
+type logRecord struct {
+	Level string
+	Entry LogEntry
+	Line  []byte
+}
+
+// logSink is a destination for log records, e.g. stdout
+type logSink interface {
+	Write(rec logRecord) error
+	Close() error
+}
+
+// stdoutSink writes lines through the package logger
+type stdoutSink struct{}
+
+func (stdoutSink) Write(rec logRecord) error {
+	logger.Println(string(rec.Line))
+	return nil
+}
+
+func (stdoutSink) Close() error { return nil }
+
+// SetOutput redirects the stdout sink, e.g. to capture log lines in tests
+func SetOutput(w io.Writer) {
+	logger.SetOutput(w)
+}
+
+// newLogSinks builds the LOG_OUTPUT sinks (stdout by default), plus Loki when LOKI_URL is set.
+// Unsupported outputs are returned so they can be reported once the writer is in place.
+func newLogSinks() ([]logSink, []string) {
+	var sinks []logSink
+	var unknown []string
+	for _, output := range env.List("LOG_OUTPUT", "stdout") {
+		switch strings.ToLower(output) {
+		case "stdout":
+			sinks = append(sinks, stdoutSink{})
+		case "file":
+			sinks = append(sinks, newFileSink())
+		case "syslog":
+			sinks = append(sinks, newSyslogSink())
+		case "journald":
+			sinks = append(sinks, newJournaldSink())
+		default:
+			unknown = append(unknown, output)
+		}
+	}
+	if len(sinks) == 0 {
+		sinks = append(sinks, stdoutSink{})
+	}
+	if url := env.String("LOKI_URL", ""); url != "" {
+		sinks = append(sinks, newLokiSink(url))
+	}
+	return sinks, unknown
+}
+
+// logWriter moves marshaling and sink writes off the calling goroutine when async.
+// A full buffer drops the record instead of blocking the request path, and so does a
+// closed writer, e.g. the one initLogging replaced while a caller still held it.
+type logWriter struct {
+	sinks   []logSink
+	format  logSerializer
+	records chan logRecord
+	done    chan struct{}
+	mu      sync.Mutex // serialises sink writes in synchronous mode
+
+	closeMu sync.RWMutex // held for reading while enqueueing, so Close never races a send
+	closed  bool
+	once    sync.Once
+}
+
+// defaultLogWriter writes synchronously to stdout until Init installs the configured sinks
+var defaultLogWriter atomic.Pointer[logWriter]
+
+func init() {
+	defaultLogWriter.Store(newLogWriter([]logSink{stdoutSink{}}, jsonFormat{}, false, 0))
+	Registry.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "log_buffer_length",
+			Help: "Log records waiting in the async writer buffer",
+		},
+		func() float64 { return float64(len(currentLogWriter().records)) },
+	))
+	Registry.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "log_buffer_capacity",
+			Help: "Capacity of the async log writer buffer",
+		},
+		func() float64 { return float64(cap(currentLogWriter().records)) },
+	))
+}
+
+func currentLogWriter() *logWriter {
+	return defaultLogWriter.Load()
+}
+
+// initLogging swaps in the LOG_OUTPUT sinks and LOG_FORMAT serializer, behind an async
+// buffer when LOG_ASYNC is set
+func initLogging() {
+	sinks, unknown := newLogSinks()
+	format, formatErr := newLogSerializer(env.String("LOG_FORMAT", "json"))
+	previous := defaultLogWriter.Swap(newLogWriter(sinks, format, env.Bool("LOG_ASYNC", true), env.Int("LOG_BUFFER_SIZE", 4096)))
+	previous.Close(context.Background())
+	if formatErr != nil {
+		Warn("Falling back to JSON logs", map[string]interface{}{
+			"error": formatErr.Error(),
+		})
+	}
+	if len(unknown) > 0 {
+		Warn("Ignoring unsupported LOG_OUTPUT entries", map[string]interface{}{
+			"outputs": unknown,
+		})
+	}
+}
+
+func newLogWriter(sinks []logSink, format logSerializer, async bool, size int) *logWriter {
+	w := &logWriter{sinks: sinks, format: format, done: make(chan struct{})}
+	if async && size > 0 {
+		w.records = make(chan logRecord, size)
+		go w.run()
+	} else {
+		close(w.done)
+	}
+	return w
+}
+
+// enqueue hands a record to the writer, dropping it when the buffer is full or the writer
+// is closed
+func (w *logWriter) enqueue(rec logRecord) {
+	w.closeMu.RLock()
+	defer w.closeMu.RUnlock()
+	if w.closed {
+		logRecordsDropped.WithLabelValues(rec.Level, "writer_closed").Inc()
+		return
+	}
+	if w.records == nil {
+		w.mu.Lock()
+		w.write(rec)
+		w.mu.Unlock()
+		return
+	}
+	select {
+	case w.records <- rec:
+	default:
+		logRecordsDropped.WithLabelValues(rec.Level, "buffer_full").Inc()
+	}
+}
+
+func (w *logWriter) run() {
+	defer close(w.done)
+	for rec := range w.records {
+		w.write(rec)
+	}
+}
+
+func (w *logWriter) write(rec logRecord) {
+	if rec.Line == nil {
+		rec.Line, _ = w.format.Serialize(rec.Entry)
+	}
+	for _, sink := range w.sinks {
+		if err := sink.Write(rec); err != nil {
+			logRecordsDropped.WithLabelValues(rec.Level, "sink_error").Inc()
+		}
+	}
+}
+
+// Close drains buffered records into the sinks, giving up when ctx expires, then closes
+// them; later calls do nothing
+func (w *logWriter) Close(ctx context.Context) {
+	w.once.Do(func() {
+		w.closeMu.Lock()
+		w.closed = true
+		if w.records != nil {
+			close(w.records)
+		}
+		w.closeMu.Unlock()
+		select {
+		case <-w.done:
+		case <-ctx.Done():
+		}
+		for _, sink := range w.sinks {
+			sink.Close()
+		}
+	})
+}
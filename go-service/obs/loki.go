@@ -0,0 +1,163 @@
+package obs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-service/internal/env"
+)
+
+// lokiMaxPendingBatches bounds memory while Loki is slow or unreachable
+const lokiMaxPendingBatches = 10
+
+var errLokiBacklog = errors.New("loki backlog full")
+
+// lokiSink batches records per level and pushes them to Loki's HTTP push API.
+// Its client is deliberately uninstrumented so shipping logs never produces more telemetry.
+type lokiSink struct {
+	url       string
+	tenant    string
+	labels    map[string]string
+	batchSize int
+	client    *http.Client
+
+	mu       sync.Mutex
+	pending  map[string][][2]string // level -> [timestamp ns, line]
+	count    int
+	flushCh  chan struct{}
+	stop     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+func newLokiSink(baseURL string) *lokiSink {
+	s := &lokiSink{
+		url:    strings.TrimRight(baseURL, "/") + "/loki/api/v1/push",
+		tenant: env.String("LOKI_TENANT_ID", ""),
+		labels: map[string]string{
+			"service": service,
+			"env":     env.String("DEPLOYMENT_ENVIRONMENT", "development"),
+		},
+		batchSize: env.Int("LOKI_BATCH_SIZE", 500),
+		client:    &http.Client{Timeout: env.Duration("LOKI_TIMEOUT", 5*time.Second)},
+		pending:   make(map[string][][2]string),
+		flushCh:   make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	go s.run(env.Duration("LOKI_BATCH_WAIT", time.Second))
+	return s
+}
+
+func (s *lokiSink) Write(rec logRecord) error {
+	ts := strconv.FormatInt(time.Now().UnixNano(), 10)
+	s.mu.Lock()
+	if s.count >= s.batchSize*lokiMaxPendingBatches {
+		s.mu.Unlock()
+		return errLokiBacklog
+	}
+	s.pending[rec.Level] = append(s.pending[rec.Level], [2]string{ts, string(rec.Line)})
+	s.count++
+	full := s.count >= s.batchSize
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (s *lokiSink) run(wait time.Duration) {
+	defer close(s.stopped)
+	ticker := time.NewTicker(wait)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			s.flush()
+			return
+		case <-ticker.C:
+			s.flush()
+		case <-s.flushCh:
+			s.flush()
+		}
+	}
+}
+
+// flush pushes everything pending; a failed push drops the batch and counts it per level
+func (s *lokiSink) flush() {
+	s.mu.Lock()
+	if s.count == 0 {
+		s.mu.Unlock()
+		return
+	}
+	batch := s.pending
+	s.pending = make(map[string][][2]string)
+	s.count = 0
+	s.mu.Unlock()
+
+	push := lokiPush{}
+	for level, values := range batch {
+		labels := map[string]string{"level": strings.ToLower(level)}
+		for k, v := range s.labels {
+			labels[k] = v
+		}
+		push.Streams = append(push.Streams, lokiStream{Stream: labels, Values: values})
+	}
+
+	if err := s.send(push); err != nil {
+		for level, values := range batch {
+			logRecordsDropped.WithLabelValues(level, "loki_error").Add(float64(len(values)))
+		}
+	}
+}
+
+func (s *lokiSink) send(push lokiPush) error {
+	body, err := json.Marshal(push)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", s.tenant)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("loki push returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close pushes the final batch; later calls wait for the same push
+func (s *lokiSink) Close() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	<-s.stopped
+	return nil
+}
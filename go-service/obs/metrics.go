@@ -0,0 +1,132 @@
+package obs
+
+import (
+	"errors"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+
+	"go-service/internal/env"
+)
+
+// Registry holds every Prometheus collector exposed by MetricsHandler.
+// Register application metrics here rather than on the global default registry.
+var Registry = prometheus.NewRegistry()
+
+// serviceBuildInfo is set to 1 for the running service; Init fills in the labels
+var serviceBuildInfo = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "service_build_info",
+		Help: "Always 1; labels identify the running service version and build",
+	},
+	[]string{"service_name", "version", "revision", "goversion"},
+)
+
+// The standard process_*, go_* and go_build_info series, so node-exporter style
+// dashboards work against the service as they would with the default registry
+func init() {
+	Registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	Registry.MustRegister(collectors.NewGoCollector())
+	Registry.MustRegister(collectors.NewBuildInfoCollector())
+	Registry.MustRegister(serviceBuildInfo)
+	Registry.MustRegister(metricsScrapes)
+	Registry.MustRegister(metricsGatherDuration)
+}
+
+// setBuildInfo publishes service_build_info for cfg; revision comes from the VCS stamp
+// the Go toolchain embeds in the binary, when there is one
+func setBuildInfo(cfg Config) {
+	revision := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+	serviceBuildInfo.Reset()
+	serviceBuildInfo.WithLabelValues(cfg.ServiceName, cfg.ServiceVersion, revision, runtime.Version()).Set(1)
+}
+
+var (
+	metricsScrapes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "metrics_scrapes_total",
+			Help: "Total number of /metrics scrapes by whether they were served from the gather cache",
+		},
+		[]string{"cache"},
+	)
+
+	metricsGatherDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "metrics_gather_duration_seconds",
+			Help:    "Time spent gathering Registry for scrapes that missed the cache",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+	)
+
+	// metricsGatherer is shared by every MetricsHandler, so the public and internal
+	// listeners reuse the same snapshot
+	metricsGatherer = newCachingGatherer(Registry, env.Duration("METRICS_CACHE_TTL", time.Second))
+)
+
+// cachingGatherer reuses the last gather for ttl. Concurrent scrapes of an expired cache
+// wait for a single gather instead of each walking every collector.
+type cachingGatherer struct {
+	gatherer prometheus.Gatherer
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	families []*dto.MetricFamily
+	err      error
+	at       time.Time
+}
+
+func newCachingGatherer(g prometheus.Gatherer, ttl time.Duration) *cachingGatherer {
+	return &cachingGatherer{gatherer: g, ttl: ttl, now: time.Now}
+}
+
+func (c *cachingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl > 0 && !c.at.IsZero() && c.now().Sub(c.at) < c.ttl {
+		metricsScrapes.WithLabelValues("hit").Inc()
+		return c.families, c.err
+	}
+	metricsScrapes.WithLabelValues("miss").Inc()
+	start := time.Now()
+	c.families, c.err = c.gatherer.Gather()
+	metricsGatherDuration.Observe(time.Since(start).Seconds())
+	c.at = c.now()
+	return c.families, c.err
+}
+
+// MetricsHandler serves Registry in the Prometheus exposition format, or OpenMetrics
+// (which carries exemplars) when the scraper asks for it. Gathers are cached for
+// METRICS_CACHE_TTL (default 1s, 0 disables) so scrape stampedes cost one gather.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
+
+// RegisterOrExisting registers c, returning the already-registered collector on conflict,
+// so constructors called more than once share the same series
+func RegisterOrExisting[T prometheus.Collector](reg prometheus.Registerer, c T) T {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,245 @@
+// Package obs is the one-call telemetry bootstrap shared by the Go services in this repo:
+// OpenTelemetry tracing and metrics export, the Prometheus registry, and structured logging.
+//
+// Almost everything is configured through the standard OTEL_* variables plus the LOG_*
+// variables documented in the README; Config only carries the service identity.
+package obs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+
+	"go-service/internal/env"
+)
+
+// Config identifies the service; empty fields fall back to environment variables
+type Config struct {
+	ServiceName    string // OTEL_SERVICE_NAME
+	ServiceVersion string // SERVICE_VERSION
+	Environment    string // DEPLOYMENT_ENVIRONMENT, default "development"
+}
+
+func (c Config) withDefaults() Config {
+	if c.ServiceName == "" {
+		c.ServiceName = env.String("OTEL_SERVICE_NAME", "go-service")
+	}
+	if c.ServiceVersion == "" {
+		c.ServiceVersion = env.String("SERVICE_VERSION", "")
+	}
+	if c.Environment == "" {
+		c.Environment = env.String("DEPLOYMENT_ENVIRONMENT", "development")
+	}
+	return c
+}
+
+// Settings is the telemetry configuration Init resolved, for diagnostics endpoints
+type Settings struct {
+	ServiceName        string           `json:"service_name"`
+	Environment        string           `json:"environment"`
+	Sampler            string           `json:"sampler"`
+	Propagators        []string         `json:"propagators"`
+	TracesExporter     string           `json:"traces_exporter"`
+	MetricsExporter    string           `json:"metrics_exporter"`
+	MetricViews        []MetricViewSpec `json:"metric_views"`
+	MetricsTemporality string           `json:"metrics_temporality"`
+	CollectorEndpoint  string           `json:"collector_endpoint,omitempty"` // the first of CollectorEndpoints
+	CollectorEndpoints []string         `json:"collector_endpoints,omitempty"`
+	OTLPProtocol       string           `json:"otlp_protocol,omitempty"`
+	OTLPInsecure       bool             `json:"otlp_insecure,omitempty"`
+	OTLPHeaders        []string         `json:"otlp_header_names,omitempty"` // names only; values may be credentials
+	OTLPCompression    string           `json:"otlp_compression,omitempty"`
+	OTLPTimeout        time.Duration    `json:"otlp_timeout_ns,omitempty"`
+	LogOutputs         []string         `json:"log_outputs"`
+	LogFormat          string           `json:"log_format"`
+	LogAsync           bool             `json:"log_async"`
+	LogBufferSize      int              `json:"log_buffer_size"`
+	LokiURL            string           `json:"loki_url,omitempty"`
+	Pipeline           PipelineSettings `json:"pipeline"`
+	ShedHeapBytes      uint64           `json:"shed_heap_bytes,omitempty"` // 0 when the memory guard is off
+	SpoolDir           string           `json:"spool_dir,omitempty"`       // set only when OTLP batches are spooled
+}
+
+var (
+	settingsMu sync.RWMutex
+	settings   Settings
+)
+
+// CurrentSettings returns what the last Init call resolved
+func CurrentSettings() Settings {
+	settingsMu.RLock()
+	defer settingsMu.RUnlock()
+	return settings
+}
+
+// ShutdownFunc flushes and stops everything Init started
+type ShutdownFunc func(ctx context.Context) error
+
+// Init configures logging, then installs global tracer and meter providers and the
+// text map propagator. On error logging is still usable and the returned shutdown
+// only flushes logs, so callers may log the error and carry on untraced.
+func Init(ctx context.Context, cfg Config) (ShutdownFunc, error) {
+	cfg = cfg.withDefaults()
+	service = cfg.ServiceName
+	initLogging()
+	setBuildInfo(cfg)
+
+	// Profiling is independent of the OTel pipeline, so it keeps running if the rest fails
+	stopProfiling, err := startProfiling(cfg)
+	if err != nil {
+		Error("Failed to start continuous profiling", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	// The memory guard also covers logs, so it runs whatever happens to the OTel pipeline
+	stopMemoryGuard := startMemoryGuard()
+
+	flushLogs := func(ctx context.Context) error {
+		stopMemoryGuard()
+		stopProfiling()
+		currentLogWriter().Close(ctx)
+		return nil
+	}
+
+	traceKinds := exporterKinds("OTEL_TRACES_EXPORTER")
+	metricKinds := exporterKinds("OTEL_METRICS_EXPORTER")
+	s := Settings{
+		ServiceName:     cfg.ServiceName,
+		Environment:     cfg.Environment,
+		TracesExporter:  exporterDescription(traceKinds),
+		MetricsExporter: exporterDescription(metricKinds),
+		LogOutputs:      env.List("LOG_OUTPUT", "stdout"),
+		LogFormat:       strings.ToLower(env.String("LOG_FORMAT", "json")),
+		LogAsync:        env.Bool("LOG_ASYNC", true),
+		LogBufferSize:   env.Int("LOG_BUFFER_SIZE", 4096),
+		LokiURL:         env.String("LOKI_URL", ""),
+		Pipeline:        loadPipelineSettings(),
+		ShedHeapBytes:   memory.Load().threshold,
+	}
+	defer func() {
+		settingsMu.Lock()
+		settings = s
+		settingsMu.Unlock()
+	}()
+
+	var otlps []otlpConfig
+	if hasExporter(traceKinds, exporterOTLP) || hasExporter(metricKinds, exporterOTLP) {
+		var err error
+		otlps, err = loadOTLPConfigs()
+		if err != nil {
+			return flushLogs, err
+		}
+		otlp := otlps[0]
+		for _, c := range otlps {
+			s.CollectorEndpoints = append(s.CollectorEndpoints, c.Endpoint)
+		}
+		s.CollectorEndpoint = otlp.Endpoint
+		s.OTLPProtocol = otlp.Protocol
+		s.OTLPInsecure = otlp.Insecure
+		s.OTLPCompression = otlp.Compression
+		s.OTLPTimeout = otlp.Timeout
+		for name := range otlp.Headers {
+			s.OTLPHeaders = append(s.OTLPHeaders, name)
+		}
+		sort.Strings(s.OTLPHeaders)
+		if spoolDir != "" {
+			if otlp.Protocol == otlpProtocolHTTP {
+				Warn("TELEMETRY_SPOOL_DIR only applies to OTLP over gRPC, not spooling", map[string]interface{}{
+					"otlp_protocol": otlp.Protocol,
+				})
+			} else {
+				s.SpoolDir = spoolDir
+			}
+		}
+	}
+
+	activeSampler.set(newSampler())
+	s.Sampler = activeSampler.Description()
+
+	propagator, propagatorNames := newPropagator()
+	s.Propagators = propagatorNames
+
+	// Initialize trace exporters (none when OTEL_TRACES_EXPORTER=none)
+	traceExps, err := newSpanExporters(ctx, traceKinds, otlps)
+	if err != nil {
+		return flushLogs, err
+	}
+
+	s.MetricsTemporality = temporalityPreference()
+
+	// Initialize metrics exporters (none when OTEL_METRICS_EXPORTER=none)
+	metricExps, err := newMetricsExporters(ctx, metricKinds, otlps, temporalitySelector(s.MetricsTemporality))
+	if err != nil {
+		return flushLogs, err
+	}
+
+	res, err := newResource(ctx, cfg)
+	if err != nil {
+		return flushLogs, err
+	}
+
+	tracerOpts := []tracesdk.TracerProviderOption{
+		tracesdk.WithResource(res),
+		tracesdk.WithSampler(activeSampler),
+	}
+	// One batcher per exporter, so a slow collector never delays or drops another's spans
+	for _, exp := range traceExps {
+		tracerOpts = append(tracerOpts, tracesdk.WithSpanProcessor(newBatchSpanProcessor(exp, s.Pipeline)))
+	}
+	tp := tracesdk.NewTracerProvider(tracerOpts...)
+
+	viewSpecs, err := loadMetricViewSpecs()
+	if err != nil {
+		Error("Failed to load metric views, continuing without them", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	s.MetricViews = viewSpecs
+
+	meterOpts := []metricsdk.Option{
+		metricsdk.WithResource(res),
+		metricsdk.WithView(metricViews(viewSpecs)...),
+	}
+	for _, exp := range metricExps {
+		meterOpts = append(meterOpts, metricsdk.WithReader(newPeriodicReader(exp, s.Pipeline)))
+	}
+	mp := metricsdk.NewMeterProvider(meterOpts...)
+
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+	otel.SetTextMapPropagator(propagator)
+	// Export failures are counted in otel_export_failures_total; keep the SDK's own errors out of stderr
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		Debug("OpenTelemetry SDK error", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}))
+
+	Info("OpenTelemetry SDK initialized", map[string]interface{}{
+		"traces_exporter":  s.TracesExporter,
+		"metrics_exporter": s.MetricsExporter,
+		"otlp_endpoints":   s.CollectorEndpoints,
+		"otlp_protocol":    s.OTLPProtocol,
+		"otlp_insecure":    s.OTLPInsecure,
+		"sampler":          s.Sampler,
+		"propagators":      propagatorNames,
+		"metric_views":     len(viewSpecs),
+		"temporality":      s.MetricsTemporality,
+		"spool_dir":        s.SpoolDir,
+	})
+
+	return func(ctx context.Context) error {
+		// Providers first so their final exports can still log failures
+		err := errors.Join(tp.Shutdown(ctx), mp.Shutdown(ctx))
+		flushLogs(ctx)
+		return err
+	}, nil
+}
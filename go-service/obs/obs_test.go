@@ -0,0 +1,689 @@
+package obs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// captureLogs redirects the stdout sink into a buffer for the duration of the test
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	t.Cleanup(func() { SetOutput(os.Stdout) })
+	return &buf
+}
+
+func decodeLines(t *testing.T, buf *bytes.Buffer) []LogEntry {
+	t.Helper()
+	var entries []LogEntry
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var e LogEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("log line is not JSON: %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestInitWithoutExporters(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	t.Setenv("OTEL_METRICS_EXPORTER", "none")
+	t.Setenv("LOG_ASYNC", "false")
+	buf := captureLogs(t)
+
+	shutdown, err := Init(context.Background(), Config{ServiceName: "obs-test", Environment: "test"})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+
+	s := CurrentSettings()
+	if s.ServiceName != "obs-test" || s.Environment != "test" {
+		t.Errorf("settings identity = %q/%q", s.ServiceName, s.Environment)
+	}
+	if s.TracesExporter != exporterNone || s.MetricsExporter != exporterNone {
+		t.Errorf("exporters = %q/%q, want none/none", s.TracesExporter, s.MetricsExporter)
+	}
+	if s.CollectorEndpoint != "" {
+		t.Errorf("collector endpoint = %q, want empty without OTLP", s.CollectorEndpoint)
+	}
+
+	Info("hello", map[string]interface{}{"k": "v"})
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	entries := decodeLines(t, buf)
+	last := entries[len(entries)-1]
+	if last["message"] != "hello" || last["service"] != "obs-test" || last["level"] != "INFO" {
+		t.Errorf("unexpected entry %v", last)
+	}
+	if fields, _ := last["fields"].(map[string]interface{}); fields["k"] != "v" {
+		t.Errorf("fields not nested: %v", last)
+	}
+}
+
+func TestLoadOTLPConfigEndpoint(t *testing.T) {
+	tests := []struct {
+		name, protocol, endpoint string
+		wantEndpoint, wantPath   string
+		wantInsecure             bool
+	}{
+		{"grpc default", "", "", "otel-collector:4317", "", true},
+		{"http default", "http/protobuf", "", "otel-collector:4318", "", true},
+		{"http url", "http/protobuf", "http://collector:4318/otlp/", "collector:4318", "/otlp", true},
+		{"https url", "grpc", "https://collector:4317", "collector:4317", "", false},
+		{"bare host", "grpc", "collector:9999", "collector:9999", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", tt.protocol)
+			t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", tt.endpoint)
+			configs, err := loadOTLPConfigs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := configs[0]
+			if cfg.Endpoint != tt.wantEndpoint || cfg.BasePath != tt.wantPath || cfg.Insecure != tt.wantInsecure {
+				t.Errorf("got endpoint=%q path=%q insecure=%v", cfg.Endpoint, cfg.BasePath, cfg.Insecure)
+			}
+		})
+	}
+}
+
+func TestExporterFanOut(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "otlp, Console,bogus,otlp")
+	if got := exporterKinds("OTEL_TRACES_EXPORTER"); strings.Join(got, ",") != "otlp,console" {
+		t.Errorf("exporterKinds = %v, want [otlp console]", got)
+	}
+	t.Setenv("OTEL_METRICS_EXPORTER", "none")
+	if got := exporterKinds("OTEL_METRICS_EXPORTER"); exporterDescription(got) != exporterNone {
+		t.Errorf("exporterKinds(none) = %v", got)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "collector-a:4317, https://collector-b:4317,collector-a:4317")
+	configs, err := loadOTLPConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 2 || configs[0].Endpoint != "collector-a:4317" || !configs[0].Insecure ||
+		configs[1].Endpoint != "collector-b:4317" || configs[1].Insecure {
+		t.Fatalf("configs = %+v", configs)
+	}
+
+	spans, err := newSpanExporters(context.Background(), []string{exporterOTLP, exporterConsole}, configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 3 {
+		t.Errorf("got %d span exporters, want one per collector plus console", len(spans))
+	}
+	for _, exp := range spans {
+		exp.Shutdown(context.Background())
+	}
+}
+
+// blockingSpanExporter holds every export until released, so spans pile up in the queue
+type blockingSpanExporter struct {
+	release  chan struct{}
+	exported atomic.Int64
+}
+
+func (e *blockingSpanExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	<-e.release
+	e.exported.Add(int64(len(spans)))
+	return nil
+}
+
+func (e *blockingSpanExporter) Shutdown(ctx context.Context) error { return nil }
+
+// shutdownCounter is an exporter stand-in that counts Shutdown calls
+type shutdownCounter struct{ calls *atomic.Int32 }
+
+func (s shutdownCounter) Shutdown(context.Context) error {
+	s.calls.Add(1)
+	return nil
+}
+
+func TestLogWriterDropsRecordsAfterClose(t *testing.T) {
+	loki := newLokiSink("http://127.0.0.1:1")
+	w := newLogWriter([]logSink{loki}, jsonFormat{}, true, 8)
+	w.enqueue(logRecord{Level: "INFO", Entry: LogEntry{"message": "before close"}})
+
+	w.Close(context.Background())
+	w.Close(context.Background())
+	loki.Close()
+
+	dropped := logRecordsDropped.WithLabelValues("INFO", "writer_closed")
+	before := testutil.ToFloat64(dropped)
+	w.enqueue(logRecord{Level: "INFO", Entry: LogEntry{"message": "after close"}})
+	if got := testutil.ToFloat64(dropped) - before; got != 1 {
+		t.Errorf("records dropped after close = %v, want 1", got)
+	}
+}
+
+func TestExporterConnShutdownStopsAnInFlightReconnect(t *testing.T) {
+	var created, shutdowns atomic.Int32
+	gate := make(chan struct{})
+	conn := newExporterConn("traces", "test:4317", func(context.Context) (shutdownCounter, error) {
+		if created.Add(1) == 1 {
+			return shutdownCounter{}, errors.New("collector down")
+		}
+		<-gate
+		return shutdownCounter{&shutdowns}, nil
+	})
+	for created.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	conn.Shutdown(context.Background())
+	close(gate)
+	for deadline := time.Now().Add(5 * time.Second); shutdowns.Load() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("exporter created after Shutdown was never shut down")
+		}
+	}
+	if _, ok := conn.get(); ok {
+		t.Error("exporter installed after Shutdown")
+	}
+	if err := conn.Shutdown(context.Background()); err != nil || shutdowns.Load() != 1 {
+		t.Errorf("second Shutdown = %v with %d exporter shutdowns, want nil and 1", err, shutdowns.Load())
+	}
+}
+
+func TestBatchSpanProcessorBoundsQueueAndCountsDrops(t *testing.T) {
+	t.Setenv("OTEL_BSP_SCHEDULE_DELAY", "3600000")
+	t.Setenv("OTEL_BSP_MAX_QUEUE_SIZE", "4")
+	t.Setenv("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", "8")
+	t.Setenv("OTEL_METRIC_EXPORT_INTERVAL", "bogus")
+	p := loadPipelineSettings()
+	if p.ScheduleDelay != time.Hour || p.MaxQueueSize != 4 || p.MaxExportBatchSize != 4 || p.MetricExportInterval != time.Minute {
+		t.Fatalf("pipeline settings = %+v", p)
+	}
+
+	exp := &blockingSpanExporter{release: make(chan struct{})}
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSpanProcessor(newBatchSpanProcessor(namedSpanExporter{"bsp-test", exp}, p)))
+	dropped := bspSpansDropped.WithLabelValues("bsp-test")
+	for i := 0; i < 10; i++ {
+		_, span := tp.Tracer("test").Start(context.Background(), "work")
+		span.End()
+	}
+
+	if got := testutil.ToFloat64(dropped); got < 6 {
+		t.Errorf("dropped = %v, want at least the 6 spans beyond the queue capacity", got)
+	}
+	if got := testutil.ToFloat64(bspQueueUtilization.WithLabelValues("bsp-test")); got != 1 {
+		t.Errorf("utilization = %v, want a full queue", got)
+	}
+
+	close(exp.release)
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := exp.exported.Load(); got != 4 {
+		t.Errorf("exported %d spans, want the 4 queued", got)
+	}
+	if got := testutil.ToFloat64(bspQueueSize.WithLabelValues("bsp-test")); got != 0 {
+		t.Errorf("queue size after flush = %v", got)
+	}
+}
+
+func TestSpoolKeepsBatchesWhileCollectorIsUnreachable(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := newSpool("traces", "spool-test", dir, 1<<20, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := func(spans int) *coltracepb.ExportTraceServiceRequest {
+		ss := &tracepb.ScopeSpans{}
+		for i := 0; i < spans; i++ {
+			ss.Spans = append(ss.Spans, &tracepb.Span{Name: fmt.Sprintf("span-%d", i)})
+		}
+		return &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{ScopeSpans: []*tracepb.ScopeSpans{ss}}}}
+	}
+
+	var mu sync.Mutex
+	up := false
+	var delivered []int
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !up {
+			return status.Error(grpccodes.Unavailable, "connection refused")
+		}
+		delivered = append(delivered, countSpoolItems(req.(proto.Message)))
+		return nil
+	}
+	export := func(spans int) error {
+		return sp.interceptor(context.Background())(context.Background(), "/export", batch(spans), &coltracepb.ExportTraceServiceResponse{}, nil, invoker)
+	}
+
+	for _, n := range []int{2, 3} {
+		err := export(n)
+		if err == nil || status.Code(err) == grpccodes.Unavailable {
+			t.Fatalf("spooled export error = %v, want a non-retryable error", err)
+		}
+	}
+	if got := testutil.ToFloat64(spoolSpooled.WithLabelValues("traces", "spool-test")); got != 5 {
+		t.Errorf("spooled items = %v, want 5", got)
+	}
+
+	// A restart picks the batches up from disk
+	sp, err = newSpool("traces", "spool-test", dir, 1<<20, time.Hour)
+	if err != nil || len(sp.files) != 2 {
+		t.Fatalf("reopened spool: %d batches, err %v", len(sp.files), err)
+	}
+
+	mu.Lock()
+	up = true
+	mu.Unlock()
+	if err := export(1); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for sp.pending() || sp.replaying.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("spool not drained")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(delivered) != "[1 2 3]" {
+		t.Errorf("delivered batches = %v, want the live one then the spooled ones oldest first", delivered)
+	}
+	if got := testutil.ToFloat64(spoolReplayed.WithLabelValues("traces", "spool-test")); got != 5 {
+		t.Errorf("replayed items = %v, want 5", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("%d files left in the spool", len(entries))
+	}
+
+	// Beyond the size limit the oldest batches go first
+	small, _ := newSpool("traces", "spool-small", t.TempDir(), 1, time.Hour)
+	small.write(batch(4))
+	if len(small.files) != 0 || testutil.ToFloat64(spoolDropped.WithLabelValues("traces", "spool-small", "size")) != 4 {
+		t.Errorf("oversized batch kept: %d batches", len(small.files))
+	}
+}
+
+func TestSpoolKeepsBatchesWhenReplayIsInterrupted(t *testing.T) {
+	sp, err := newSpool("traces", "spool-interrupt", t.TempDir(), 1<<20, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		sp.write(&coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{{Name: "span"}}}}}}})
+	}
+
+	// The connection closing under the replay fails it with Canceled
+	closing := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		return status.Error(grpccodes.Canceled, "grpc: the client connection is closing")
+	}
+	sp.replay(context.Background(), "/export", nil, closing)
+	if len(sp.files) != 2 {
+		t.Errorf("%d batches left after a cancelled replay, want 2", len(sp.files))
+	}
+
+	// A shut down exporter's replay sends nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sent := 0
+	counting := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		sent++
+		return nil
+	}
+	sp.replay(ctx, "/export", nil, counting)
+	if sent != 0 || len(sp.files) != 2 {
+		t.Errorf("replay after shutdown sent %d batches, %d left", sent, len(sp.files))
+	}
+}
+
+func TestMemoryGuardShedsTelemetryAboveThreshold(t *testing.T) {
+	t.Setenv("TELEMETRY_SHED_HEAP_MB", "100")
+	t.Setenv("TELEMETRY_SHED_SAMPLE_RATIO", "0")
+	t.Setenv("TELEMETRY_SHED_METRIC_INTERVAL_FACTOR", "3")
+	t.Setenv("LOG_ASYNC", "false")
+	g := newMemoryGuard()
+	heap := uint64(50 << 20)
+	g.heap = func() uint64 { return heap }
+	memory.Store(g)
+	t.Cleanup(func() { memory.Store(&memoryGuard{}) })
+	buf := captureLogs(t)
+	initLogging()
+
+	g.check()
+	Info("before", nil)
+	heap = 120 << 20
+	g.check()
+	Info("dropped", nil)
+	Warn("kept", nil)
+
+	entries := decodeLines(t, buf)
+	var messages []string
+	for _, e := range entries {
+		messages = append(messages, e["message"].(string))
+	}
+	if got := strings.Join(messages, "|"); got != "before|Heap above threshold, shedding telemetry|kept" {
+		t.Errorf("logged %q", got)
+	}
+
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSampler(activeSampler))
+	ctx, root := tp.Tracer("test").Start(context.Background(), "root")
+	if root.SpanContext().IsSampled() {
+		t.Error("root span should be shed at ratio 0")
+	}
+	root.End()
+	_, debugSpan := tp.Tracer("test").Start(WithDebug(ctx), "debug")
+	if !debugSpan.SpanContext().IsSampled() {
+		t.Error("debug spans are never shed")
+	}
+	debugSpan.End()
+
+	exp := newSheddingMetricExporter(&countingMetricExporter{}, time.Minute)
+	for i := 0; i < 3; i++ {
+		exp.Export(context.Background(), nil)
+	}
+	if got := exp.(sheddingMetricExporter).Exporter.(*countingMetricExporter).exports; got != 1 {
+		t.Errorf("exported %d times while shedding, want 1 per stretched interval", got)
+	}
+
+	// Hysteresis: just under the threshold keeps shedding, under 90% stops
+	heap = 95 << 20
+	g.check()
+	if !g.shedding.Load() {
+		t.Error("shedding stopped above 90% of the threshold")
+	}
+	heap = 80 << 20
+	g.check()
+	if g.shedding.Load() {
+		t.Error("shedding did not stop below 90% of the threshold")
+	}
+}
+
+type countingMetricExporter struct {
+	metricsdk.Exporter
+	exports int
+}
+
+func (e *countingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.exports++
+	return nil
+}
+
+func TestErrorAndFatalLogsCarryCallerAndStack(t *testing.T) {
+	t.Setenv("LOG_ASYNC", "false")
+	buf := captureLogs(t)
+	initLogging()
+	var code int
+	exit = func(c int) { code = c }
+	t.Cleanup(func() { exit = os.Exit })
+
+	Info("no caller", nil)
+	Error("failed", map[string]interface{}{"k": "v"})
+	Fatal("giving up", nil)
+
+	entries := decodeLines(t, buf)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	if _, ok := entries[0]["fields"]; ok {
+		t.Errorf("INFO records should not carry a caller: %v", entries[0])
+	}
+	errFields := recordFields(entries[1])
+	if caller, _ := errFields["caller"].(string); !strings.HasPrefix(caller, "obs/obs_test.go:") {
+		t.Errorf("caller = %q, want the test file", caller)
+	}
+	if _, ok := errFields["stacktrace"]; ok || errFields["k"] != "v" {
+		t.Errorf("ERROR fields = %v, want caller without stack by default", errFields)
+	}
+	fatal := recordFields(entries[2])
+	if entries[2]["level"] != "FATAL" || !strings.Contains(fmt.Sprint(fatal["stacktrace"]), "TestErrorAndFatalLogsCarryCallerAndStack") {
+		t.Errorf("FATAL entry = %v, want a stack trace from the test", entries[2])
+	}
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+}
+
+func TestParseOTLPHeaders(t *testing.T) {
+	got := parseOTLPHeaders("api-key=secret%20value, x-tenant = demo ,broken,=novalue")
+	if len(got) != 2 || got["api-key"] != "secret value" || got["x-tenant"] != "demo" {
+		t.Errorf("parseOTLPHeaders = %v", got)
+	}
+}
+
+func TestOTLPCompressionTimeoutAndSignalOverrides(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "authorization=Basic abc,x-scope=all")
+	t.Setenv("OTEL_EXPORTER_OTLP_COMPRESSION", "gzip")
+	t.Setenv("OTEL_EXPORTER_OTLP_TIMEOUT", "2500")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_HEADERS", "x-scope=metrics")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_COMPRESSION", "none")
+	t.Setenv("OTEL_EXPORTER_OTLP_METRICS_TIMEOUT", "bogus")
+	configs, err := loadOTLPConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := configs[0]
+	if cfg.Compression != "gzip" || cfg.Timeout != 2500*time.Millisecond {
+		t.Errorf("compression=%q timeout=%v, want gzip/2.5s", cfg.Compression, cfg.Timeout)
+	}
+
+	traces := cfg.forSignal("traces")
+	if traces.Compression != "gzip" || traces.Headers["x-scope"] != "all" {
+		t.Errorf("traces should inherit the general settings: %+v", traces)
+	}
+	metrics := cfg.forSignal("metrics")
+	if metrics.Compression != "none" || metrics.Timeout != 2500*time.Millisecond {
+		t.Errorf("metrics compression=%q timeout=%v, want none and the general timeout", metrics.Compression, metrics.Timeout)
+	}
+	if metrics.Headers["x-scope"] != "metrics" || metrics.Headers["authorization"] != "Basic abc" {
+		t.Errorf("metrics headers = %v", metrics.Headers)
+	}
+	if cfg.Headers["x-scope"] != "all" {
+		t.Error("forSignal must not modify the shared headers")
+	}
+}
+
+func TestRegistryExposesRuntimeAndBuildInfo(t *testing.T) {
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	t.Setenv("OTEL_METRICS_EXPORTER", "none")
+	t.Setenv("LOG_ASYNC", "false")
+	captureLogs(t)
+
+	shutdown, err := Init(context.Background(), Config{ServiceName: "obs-test", ServiceVersion: "1.2.3"})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer shutdown(context.Background())
+
+	families, err := Registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	found := map[string]bool{}
+	for _, mf := range families {
+		found[mf.GetName()] = true
+		if mf.GetName() != "service_build_info" {
+			continue
+		}
+		labels := map[string]string{}
+		for _, lp := range mf.GetMetric()[0].GetLabel() {
+			labels[lp.GetName()] = lp.GetValue()
+		}
+		if labels["service_name"] != "obs-test" || labels["version"] != "1.2.3" || labels["goversion"] == "" {
+			t.Errorf("service_build_info labels = %v", labels)
+		}
+	}
+	for _, name := range []string{"process_cpu_seconds_total", "process_resident_memory_bytes", "go_goroutines", "go_memstats_heap_alloc_bytes", "go_build_info", "service_build_info"} {
+		if !found[name] {
+			t.Errorf("%s missing from Registry", name)
+		}
+	}
+}
+
+type countingGatherer struct{ calls int }
+
+func (g *countingGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.calls++
+	return nil, nil
+}
+
+func TestCachingGathererReusesSnapshotWithinTTL(t *testing.T) {
+	inner := &countingGatherer{}
+	c := newCachingGatherer(inner, time.Second)
+	at := time.Unix(1700000000, 0)
+	c.now = func() time.Time { return at }
+
+	for i := 0; i < 5; i++ {
+		c.Gather()
+	}
+	if inner.calls != 1 {
+		t.Errorf("gathered %d times within the TTL, want 1", inner.calls)
+	}
+	at = at.Add(time.Second)
+	c.Gather()
+	if inner.calls != 2 {
+		t.Errorf("gathered %d times after the TTL, want 2", inner.calls)
+	}
+
+	uncached := newCachingGatherer(inner, 0)
+	uncached.Gather()
+	uncached.Gather()
+	if inner.calls != 4 {
+		t.Errorf("a zero TTL should gather every time, got %d calls", inner.calls)
+	}
+}
+
+func TestRouteSamplerAppliesPerRouteRatios(t *testing.T) {
+	t.Setenv("OTEL_TRACES_SAMPLER", "always_off")
+	t.Setenv("OTEL_TRACES_SAMPLER_ROUTES", `{"/error": 1, "/health": 0, "/users/*": 1, "/users/{id}": 0}`)
+	sampler := newSampler()
+
+	root := func(attrs ...attribute.KeyValue) tracesdk.SamplingDecision {
+		return sampler.ShouldSample(tracesdk.SamplingParameters{
+			ParentContext: context.Background(),
+			TraceID:       trace.TraceID{1},
+			Attributes:    attrs,
+		}).Decision
+	}
+	for name, c := range map[string]struct {
+		attrs []attribute.KeyValue
+		want  tracesdk.SamplingDecision
+	}{
+		"route":             {[]attribute.KeyValue{semconv.HTTPRoute("/error")}, tracesdk.RecordAndSample},
+		"exact over glob":   {[]attribute.KeyValue{semconv.HTTPRoute("/users/{id}"), semconv.HTTPTarget("/users/7")}, tracesdk.Drop},
+		"unrouted path":     {[]attribute.KeyValue{semconv.HTTPTarget("/users/7/orders?page=2")}, tracesdk.Drop},
+		"glob on path":      {[]attribute.KeyValue{semconv.HTTPTarget("/users/search?q=a")}, tracesdk.RecordAndSample},
+		"unmatched route":   {[]attribute.KeyValue{semconv.HTTPRoute("/orders")}, tracesdk.Drop},
+		"no http attribute": {nil, tracesdk.Drop},
+	} {
+		if got := root(c.attrs...); got != c.want {
+			t.Errorf("%s: decision = %v, want %v", name, got, c.want)
+		}
+	}
+
+	// Spans with a parent are left to the configured sampler, whatever their route
+	t.Setenv("OTEL_TRACES_SAMPLER", "parentbased_always_off")
+	sampler = newSampler()
+	parent := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}))
+	res := sampler.ShouldSample(tracesdk.SamplingParameters{
+		ParentContext: parent,
+		TraceID:       trace.TraceID{1},
+		Attributes:    []attribute.KeyValue{semconv.HTTPRoute("/health")},
+	})
+	if res.Decision != tracesdk.RecordAndSample {
+		t.Errorf("sampled parent overridden by the /health rule: %v", res.Decision)
+	}
+
+	t.Setenv("OTEL_TRACES_SAMPLER_ROUTES", `{"/health": 2}`)
+	if _, ok := newSampler().(routeSampler); ok {
+		t.Error("an invalid ratio should disable the route rules")
+	}
+}
+
+func TestQuietContextIsNeverSampled(t *testing.T) {
+	s := &swappableSampler{}
+	s.set(tracesdk.AlwaysSample())
+	quiet := WithQuiet(context.Background(), QuietSignals{Traces: true})
+	if s.ShouldSample(tracesdk.SamplingParameters{ParentContext: quiet}).Decision != tracesdk.Drop {
+		t.Error("quiet request sampled")
+	}
+	if s.ShouldSample(tracesdk.SamplingParameters{ParentContext: WithDebug(quiet)}).Decision != tracesdk.RecordAndSample {
+		t.Error("debug should win over quiet")
+	}
+	logsOnly := WithQuiet(context.Background(), QuietSignals{Logs: true})
+	if s.ShouldSample(tracesdk.SamplingParameters{ParentContext: logsOnly}).Decision != tracesdk.RecordAndSample {
+		t.Error("quiet logs should not drop traces")
+	}
+}
+
+func TestDebugContextForcesSamplingAndLogging(t *testing.T) {
+	s := &swappableSampler{}
+	s.set(tracesdk.NeverSample())
+	params := tracesdk.SamplingParameters{ParentContext: context.Background(), Name: "GET /"}
+	if s.ShouldSample(params).Decision != tracesdk.Drop {
+		t.Fatal("NeverSample should drop ordinary requests")
+	}
+	params.ParentContext = WithDebug(context.Background())
+	if res := s.ShouldSample(params); res.Decision != tracesdk.RecordAndSample || len(res.Attributes) != 1 {
+		t.Errorf("debug request not force-sampled: %+v", res)
+	}
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "none")
+	t.Setenv("OTEL_METRICS_EXPORTER", "none")
+	t.Setenv("LOG_ASYNC", "false")
+	buf := captureLogs(t)
+	shutdown, err := Init(context.Background(), Config{ServiceName: "obs-test"})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	SetLevel("WARN")
+	defer SetLevel("INFO")
+	buf.Reset()
+
+	DebugContext(context.Background(), "suppressed", nil)
+	DebugContext(WithDebug(context.Background()), "forced", nil)
+	shutdown(context.Background())
+
+	var messages []interface{}
+	for _, e := range decodeLines(t, buf) {
+		messages = append(messages, e["message"])
+	}
+	if len(messages) != 1 || messages[0] != "forced" {
+		t.Errorf("logged %v, want only the debug request's DEBUG record", messages)
+	}
+}
@@ -0,0 +1,343 @@
+package obs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"go-service/internal/env"
+)
+
+const (
+	otlpProtocolGRPC = "grpc"
+	otlpProtocolHTTP = "http/protobuf"
+
+	otlpCompressionGzip = "gzip"
+	otlpCompressionNone = "none"
+
+	// defaultOTLPTimeout is the spec default for OTEL_EXPORTER_OTLP_TIMEOUT
+	defaultOTLPTimeout = 10 * time.Second
+)
+
+// otlpConfig is the resolved OTLP exporter configuration shared by all signals
+type otlpConfig struct {
+	Protocol    string
+	Endpoint    string // host:port
+	BasePath    string // URL path prefix for http/protobuf, e.g. "/otlp"
+	Insecure    bool
+	Headers     map[string]string
+	Compression string        // gzip or none
+	Timeout     time.Duration // per export, including retries
+	TLS         *tls.Config   // nil when Insecure
+}
+
+// otlpTLSFiles are the certificate paths used to build the exporter TLS config
+type otlpTLSFiles struct {
+	CACert     string
+	ClientCert string
+	ClientKey  string
+	SkipVerify bool
+}
+
+// loadOTLPConfigs resolves OTEL_EXPORTER_OTLP_PROTOCOL, _ENDPOINT, _HEADERS, _COMPRESSION,
+// _TIMEOUT and TLS settings, returning one config per collector
+// _ENDPOINT may list several comma-separated collectors, which all receive every export
+// and share the other settings. Each may be a bare host:port or a URL; an http:// scheme
+// implies plaintext and https:// implies TLS. Bare endpoints use TLS only when
+// certificates are configured or OTEL_EXPORTER_OTLP_INSECURE=false.
+func loadOTLPConfigs() ([]otlpConfig, error) {
+	files := otlpTLSFiles{
+		CACert:     os.Getenv("OTEL_EXPORTER_OTLP_CERTIFICATE"),
+		ClientCert: os.Getenv("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE"),
+		ClientKey:  os.Getenv("OTEL_EXPORTER_OTLP_CLIENT_KEY"),
+		SkipVerify: env.Bool("OTEL_EXPORTER_OTLP_INSECURE_SKIP_VERIFY", false),
+	}
+	hasTLSFiles := files.CACert != "" || files.ClientCert != "" || files.ClientKey != ""
+
+	cfg := otlpConfig{
+		Protocol: strings.ToLower(env.String("OTEL_EXPORTER_OTLP_PROTOCOL", otlpProtocolGRPC)),
+		Insecure: env.Bool("OTEL_EXPORTER_OTLP_INSECURE", !hasTLSFiles),
+		Headers:  parseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+	}
+	cfg.Compression = parseOTLPCompression("OTEL_EXPORTER_OTLP_COMPRESSION", otlpCompressionNone)
+	cfg.Timeout = parseMilliseconds("OTEL_EXPORTER_OTLP_TIMEOUT", defaultOTLPTimeout)
+	if cfg.Protocol != otlpProtocolGRPC && cfg.Protocol != otlpProtocolHTTP {
+		Warn("Unsupported OTEL_EXPORTER_OTLP_PROTOCOL, using grpc", map[string]interface{}{
+			"value": cfg.Protocol,
+		})
+		cfg.Protocol = otlpProtocolGRPC
+	}
+
+	defaultEndpoint := "otel-collector:4317"
+	if cfg.Protocol == otlpProtocolHTTP {
+		defaultEndpoint = "otel-collector:4318"
+	}
+	var configs []otlpConfig
+	seen := map[string]bool{}
+	for _, endpoint := range env.List("OTEL_EXPORTER_OTLP_ENDPOINT", defaultEndpoint) {
+		c, err := cfg.withEndpoint(endpoint, defaultEndpoint, files)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[c.Endpoint+c.BasePath] {
+			seen[c.Endpoint+c.BasePath] = true
+			configs = append(configs, c)
+		}
+	}
+	return configs, nil
+}
+
+// withEndpoint resolves one collector endpoint and, unless it is plaintext, its TLS config
+func (cfg otlpConfig) withEndpoint(endpoint, defaultEndpoint string, files otlpTLSFiles) (otlpConfig, error) {
+	if strings.Contains(endpoint, "://") {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			Warn("Invalid OTEL_EXPORTER_OTLP_ENDPOINT, using default", map[string]interface{}{
+				"value":   endpoint,
+				"default": defaultEndpoint,
+			})
+			cfg.Endpoint = defaultEndpoint
+		} else {
+			cfg.Endpoint = u.Host
+			cfg.Insecure = u.Scheme != "https"
+			cfg.BasePath = strings.TrimSuffix(u.Path, "/")
+		}
+	} else {
+		cfg.Endpoint = endpoint
+	}
+
+	if cfg.Insecure {
+		return cfg, nil
+	}
+	tlsConfig, err := files.build()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.TLS = tlsConfig
+	return cfg, nil
+}
+
+// build loads the CA bundle and optional client key pair into a tls.Config
+func (f otlpTLSFiles) build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: f.SkipVerify,
+	}
+
+	if f.CACert != "" {
+		pem, err := os.ReadFile(f.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("reading OTLP CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", f.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (f.ClientCert == "") != (f.ClientKey == "") {
+		return nil, errors.New("OTEL_EXPORTER_OTLP_CLIENT_CERTIFICATE and OTEL_EXPORTER_OTLP_CLIENT_KEY must be set together")
+	}
+	if f.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(f.ClientCert, f.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading OTLP client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// parseOTLPHeaders parses the W3C-baggage-style "key1=value1,key2=value2" header list
+func parseOTLPHeaders(raw string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		key, err := url.PathUnescape(strings.TrimSpace(key))
+		if err != nil || key == "" {
+			continue
+		}
+		value, err = url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		headers[key] = value
+	}
+	return headers
+}
+
+// parseOTLPCompression reads a compression variable; only gzip and none are supported
+func parseOTLPCompression(key, fallback string) string {
+	raw := strings.ToLower(strings.TrimSpace(os.Getenv(key)))
+	switch raw {
+	case "":
+		return fallback
+	case otlpCompressionGzip, otlpCompressionNone:
+		return raw
+	default:
+		Warn("Unsupported OTLP compression, ignoring", map[string]interface{}{
+			"variable": key,
+			"value":    raw,
+		})
+		return fallback
+	}
+}
+
+// parseMilliseconds reads a timeout, delay or interval variable, which the OTel spec
+// defines in milliseconds
+func parseMilliseconds(key string, fallback time.Duration) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	ms, err := strconv.Atoi(raw)
+	if err != nil || ms <= 0 {
+		Warn("Invalid duration, expected milliseconds", map[string]interface{}{
+			"variable": key,
+			"value":    raw,
+		})
+		return fallback
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// forSignal applies the per-signal overrides, e.g. OTEL_EXPORTER_OTLP_TRACES_HEADERS for
+// "traces". Signal headers are added to the general ones, replacing any with the same name.
+func (c otlpConfig) forSignal(signal string) otlpConfig {
+	prefix := "OTEL_EXPORTER_OTLP_" + strings.ToUpper(signal) + "_"
+	if extra := parseOTLPHeaders(os.Getenv(prefix + "HEADERS")); len(extra) > 0 {
+		headers := make(map[string]string, len(c.Headers)+len(extra))
+		for k, v := range c.Headers {
+			headers[k] = v
+		}
+		for k, v := range extra {
+			headers[k] = v
+		}
+		c.Headers = headers
+	}
+	c.Compression = parseOTLPCompression(prefix+"COMPRESSION", c.Compression)
+	c.Timeout = parseMilliseconds(prefix+"TIMEOUT", c.Timeout)
+	return c
+}
+
+// signalPath returns the http/protobuf URL path for a signal, e.g. "traces" -> "/v1/traces"
+func (c otlpConfig) signalPath(signal string) string {
+	return c.BasePath + "/v1/" + signal
+}
+
+func newTraceExporter(ctx context.Context, cfg otlpConfig) (tracesdk.SpanExporter, error) {
+	cfg = cfg.forSignal("traces")
+	if cfg.Protocol == otlpProtocolHTTP {
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(cfg.Endpoint),
+			otlptracehttp.WithURLPath(cfg.signalPath("traces")),
+			otlptracehttp.WithHeaders(cfg.Headers),
+			otlptracehttp.WithTimeout(cfg.Timeout),
+		}
+		if cfg.Compression == otlpCompressionGzip {
+			opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		} else {
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(cfg.TLS))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+
+	opts := []otlptracegrpc.Option{
+		otlptracegrpc.WithEndpoint(cfg.Endpoint),
+		otlptracegrpc.WithHeaders(cfg.Headers),
+		otlptracegrpc.WithTimeout(cfg.Timeout),
+	}
+	if cfg.Compression == otlpCompressionGzip {
+		opts = append(opts, otlptracegrpc.WithCompressor(otlpCompressionGzip))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(cfg.TLS)))
+	}
+	sp := spoolFor("traces", cfg.Endpoint)
+	if sp == nil {
+		return otlptracegrpc.New(ctx, opts...)
+	}
+	replayCtx, stopReplay := context.WithCancel(context.Background())
+	opts = append(opts, otlptracegrpc.WithDialOption(grpc.WithChainUnaryInterceptor(sp.interceptor(replayCtx))))
+	exp, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		stopReplay()
+		return nil, err
+	}
+	return spooledSpanExporter{SpanExporter: exp, stopReplay: stopReplay}, nil
+}
+
+func newMetricExporter(ctx context.Context, cfg otlpConfig, temporality metricsdk.TemporalitySelector) (metricsdk.Exporter, error) {
+	cfg = cfg.forSignal("metrics")
+	if cfg.Protocol == otlpProtocolHTTP {
+		opts := []otlpmetrichttp.Option{
+			otlpmetrichttp.WithEndpoint(cfg.Endpoint),
+			otlpmetrichttp.WithURLPath(cfg.signalPath("metrics")),
+			otlpmetrichttp.WithHeaders(cfg.Headers),
+			otlpmetrichttp.WithTimeout(cfg.Timeout),
+			otlpmetrichttp.WithTemporalitySelector(temporality),
+		}
+		if cfg.Compression == otlpCompressionGzip {
+			opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		} else {
+			opts = append(opts, otlpmetrichttp.WithTLSClientConfig(cfg.TLS))
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithEndpoint(cfg.Endpoint),
+		otlpmetricgrpc.WithHeaders(cfg.Headers),
+		otlpmetricgrpc.WithTimeout(cfg.Timeout),
+		otlpmetricgrpc.WithTemporalitySelector(temporality),
+	}
+	if cfg.Compression == otlpCompressionGzip {
+		opts = append(opts, otlpmetricgrpc.WithCompressor(otlpCompressionGzip))
+	}
+	if cfg.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	} else {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(cfg.TLS)))
+	}
+	sp := spoolFor("metrics", cfg.Endpoint)
+	if sp == nil {
+		return otlpmetricgrpc.New(ctx, opts...)
+	}
+	replayCtx, stopReplay := context.WithCancel(context.Background())
+	opts = append(opts, otlpmetricgrpc.WithDialOption(grpc.WithChainUnaryInterceptor(sp.interceptor(replayCtx))))
+	exp, err := otlpmetricgrpc.New(ctx, opts...)
+	if err != nil {
+		stopReplay()
+		return nil, err
+	}
+	return spooledMetricExporter{Exporter: exp, stopReplay: stopReplay}, nil
+}
@@ -0,0 +1,187 @@
+package obs
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// PipelineSettings tunes the batch span processors and periodic metric readers. Names
+// and defaults follow the OTel spec: OTEL_BSP_* and OTEL_METRIC_EXPORT_*, in milliseconds.
+type PipelineSettings struct {
+	ScheduleDelay        time.Duration `json:"bsp_schedule_delay_ns"`
+	ExportTimeout        time.Duration `json:"bsp_export_timeout_ns"`
+	MaxQueueSize         int           `json:"bsp_max_queue_size"`
+	MaxExportBatchSize   int           `json:"bsp_max_export_batch_size"`
+	MetricExportInterval time.Duration `json:"metric_export_interval_ns"`
+	MetricExportTimeout  time.Duration `json:"metric_export_timeout_ns"`
+}
+
+func loadPipelineSettings() PipelineSettings {
+	p := PipelineSettings{
+		ScheduleDelay:        parseMilliseconds("OTEL_BSP_SCHEDULE_DELAY", 5*time.Second),
+		ExportTimeout:        parseMilliseconds("OTEL_BSP_EXPORT_TIMEOUT", 30*time.Second),
+		MaxQueueSize:         parsePositiveInt("OTEL_BSP_MAX_QUEUE_SIZE", 2048),
+		MaxExportBatchSize:   parsePositiveInt("OTEL_BSP_MAX_EXPORT_BATCH_SIZE", 512),
+		MetricExportInterval: parseMilliseconds("OTEL_METRIC_EXPORT_INTERVAL", time.Minute),
+		MetricExportTimeout:  parseMilliseconds("OTEL_METRIC_EXPORT_TIMEOUT", 30*time.Second),
+	}
+	// The spec requires the batch to fit in the queue
+	if p.MaxExportBatchSize > p.MaxQueueSize {
+		Warn("OTEL_BSP_MAX_EXPORT_BATCH_SIZE exceeds the queue size, using the queue size", map[string]interface{}{
+			"max_export_batch_size": p.MaxExportBatchSize,
+			"max_queue_size":        p.MaxQueueSize,
+		})
+		p.MaxExportBatchSize = p.MaxQueueSize
+	}
+	return p
+}
+
+func parsePositiveInt(key string, fallback int) int {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		Warn("Invalid value, expected a positive integer", map[string]interface{}{
+			"variable": key,
+			"value":    raw,
+		})
+		return fallback
+	}
+	return n
+}
+
+var (
+	bspQueueSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "otel_bsp_queue_size",
+			Help: "Ended spans waiting in the batch span processor for export, by exporter",
+		},
+		[]string{"exporter"},
+	)
+
+	bspQueueCapacity = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "otel_bsp_queue_capacity",
+			Help: "Maximum number of spans the batch span processor queues, by exporter",
+		},
+		[]string{"exporter"},
+	)
+
+	bspQueueUtilization = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "otel_bsp_queue_utilization_ratio",
+			Help: "Fraction of the batch span processor queue in use, by exporter",
+		},
+		[]string{"exporter"},
+	)
+
+	bspSpansDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "otel_bsp_spans_dropped_total",
+			Help: "Total number of sampled spans dropped because the batch span processor queue was full",
+		},
+		[]string{"exporter"},
+	)
+
+	bspSpansExported = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "otel_bsp_spans_exported_total",
+			Help: "Total number of spans handed to the exporter, whether or not the export succeeded",
+		},
+		[]string{"exporter"},
+	)
+)
+
+func init() {
+	Registry.MustRegister(bspQueueSize, bspQueueCapacity, bspQueueUtilization, bspSpansDropped, bspSpansExported)
+}
+
+// namedSpanExporter labels an exporter in the otel_bsp_* metrics: the collector endpoint
+// for OTLP, "console" for stdout
+type namedSpanExporter struct {
+	name string
+	tracesdk.SpanExporter
+}
+
+// spanQueue tracks the spans between OnEnd and export. The SDK's batch span processor
+// drops spans silently when its queue is full, so the queue bound is enforced here, where
+// drops can be counted, and the processor's own queue is sized to never overflow.
+type spanQueue struct {
+	name     string
+	capacity int64
+	pending  atomic.Int64
+}
+
+func (q *spanQueue) update(n int64) {
+	bspQueueSize.WithLabelValues(q.name).Set(float64(n))
+	bspQueueUtilization.WithLabelValues(q.name).Set(float64(n) / float64(q.capacity))
+}
+
+// queuedSpanProcessor admits sampled spans into the wrapped batch span processor while
+// the queue has room
+type queuedSpanProcessor struct {
+	tracesdk.SpanProcessor
+	queue *spanQueue
+}
+
+// newBatchSpanProcessor builds a batch span processor for exp that reports its queue size,
+// utilization and drops
+func newBatchSpanProcessor(exp namedSpanExporter, p PipelineSettings) tracesdk.SpanProcessor {
+	q := &spanQueue{name: exp.name, capacity: int64(p.MaxQueueSize)}
+	bspQueueCapacity.WithLabelValues(q.name).Set(float64(q.capacity))
+	q.update(0)
+	bsp := tracesdk.NewBatchSpanProcessor(countingSpanExporter{exp.SpanExporter, q},
+		tracesdk.WithBatchTimeout(p.ScheduleDelay),
+		tracesdk.WithExportTimeout(p.ExportTimeout),
+		tracesdk.WithMaxQueueSize(p.MaxQueueSize),
+		tracesdk.WithMaxExportBatchSize(p.MaxExportBatchSize),
+	)
+	return queuedSpanProcessor{bsp, q}
+}
+
+func (p queuedSpanProcessor) OnEnd(s tracesdk.ReadOnlySpan) {
+	// The batch span processor ignores unsampled spans, so they never occupy the queue
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+	n := p.queue.pending.Add(1)
+	if n > p.queue.capacity {
+		p.queue.pending.Add(-1)
+		bspSpansDropped.WithLabelValues(p.queue.name).Inc()
+		return
+	}
+	p.queue.update(n)
+	p.SpanProcessor.OnEnd(s)
+}
+
+// countingSpanExporter releases exported spans from the queue
+type countingSpanExporter struct {
+	tracesdk.SpanExporter
+	queue *spanQueue
+}
+
+func (e countingSpanExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	bspSpansExported.WithLabelValues(e.queue.name).Add(float64(len(spans)))
+	e.queue.update(e.queue.pending.Add(-int64(len(spans))))
+	return err
+}
+
+// newPeriodicReader builds a periodic metric reader for exp with the configured interval
+// and timeout, stretched by the memory guard while it sheds
+func newPeriodicReader(exp metricsdk.Exporter, p PipelineSettings) metricsdk.Reader {
+	return metricsdk.NewPeriodicReader(newSheddingMetricExporter(exp, p.MetricExportInterval),
+		metricsdk.WithInterval(p.MetricExportInterval),
+		metricsdk.WithTimeout(p.MetricExportTimeout),
+	)
+}
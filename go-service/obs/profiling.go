@@ -0,0 +1,98 @@
+package obs
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/grafana/pyroscope-go"
+
+	"go-service/internal/env"
+)
+
+// profileTypes maps PYROSCOPE_PROFILE_TYPES entries to the SDK's profile types
+var profileTypes = map[string]pyroscope.ProfileType{
+	"cpu":            pyroscope.ProfileCPU,
+	"alloc_objects":  pyroscope.ProfileAllocObjects,
+	"alloc_space":    pyroscope.ProfileAllocSpace,
+	"inuse_objects":  pyroscope.ProfileInuseObjects,
+	"inuse_space":    pyroscope.ProfileInuseSpace,
+	"goroutines":     pyroscope.ProfileGoroutines,
+	"mutex_count":    pyroscope.ProfileMutexCount,
+	"mutex_duration": pyroscope.ProfileMutexDuration,
+	"block_count":    pyroscope.ProfileBlockCount,
+	"block_duration": pyroscope.ProfileBlockDuration,
+}
+
+// startProfiling starts continuous profiling when PYROSCOPE_SERVER_ADDRESS is set.
+// Profiles are tagged with the service name, version and environment so they line up
+// with the traces and metrics of the same deployment. stop is a no-op when disabled.
+func startProfiling(cfg Config) (stop func() error, err error) {
+	stop = func() error { return nil }
+	addr := env.String("PYROSCOPE_SERVER_ADDRESS", "")
+	if addr == "" {
+		return stop, nil
+	}
+
+	var types []pyroscope.ProfileType
+	for _, name := range env.List("PYROSCOPE_PROFILE_TYPES", "cpu,alloc_objects,alloc_space,inuse_objects,inuse_space") {
+		t, ok := profileTypes[strings.ToLower(name)]
+		if !ok {
+			return stop, fmt.Errorf("unknown PYROSCOPE_PROFILE_TYPES entry %q", name)
+		}
+		switch t {
+		case pyroscope.ProfileMutexCount, pyroscope.ProfileMutexDuration:
+			runtime.SetMutexProfileFraction(5)
+		case pyroscope.ProfileBlockCount, pyroscope.ProfileBlockDuration:
+			runtime.SetBlockProfileRate(int(time.Millisecond))
+		}
+		types = append(types, t)
+	}
+
+	tags := map[string]string{
+		"service_name": cfg.ServiceName,
+		"environment":  cfg.Environment,
+	}
+	if cfg.ServiceVersion != "" {
+		tags["version"] = cfg.ServiceVersion
+	}
+
+	profiler, err := pyroscope.Start(pyroscope.Config{
+		ApplicationName:   env.String("PYROSCOPE_APPLICATION_NAME", cfg.ServiceName),
+		ServerAddress:     addr,
+		TenantID:          env.String("PYROSCOPE_TENANT_ID", ""),
+		BasicAuthUser:     env.String("PYROSCOPE_BASIC_AUTH_USER", ""),
+		BasicAuthPassword: env.String("PYROSCOPE_BASIC_AUTH_PASSWORD", ""),
+		UploadRate:        env.Duration("PYROSCOPE_UPLOAD_RATE", 15*time.Second),
+		Tags:              tags,
+		ProfileTypes:      types,
+		Logger:            profilingLogger{},
+	})
+	if err != nil {
+		return stop, err
+	}
+
+	Info("Continuous profiling started", map[string]interface{}{
+		"server":        addr,
+		"profile_types": len(types),
+	})
+	return profiler.Stop, nil
+}
+
+// profilingLogger routes the Pyroscope SDK's own logs through Log; upload errors surface as WARN
+type profilingLogger struct{}
+
+func (profilingLogger) Infof(format string, args ...interface{}) {
+	Debug("Pyroscope: "+fmt.Sprintf(format, args...), nil)
+}
+
+func (profilingLogger) Debugf(format string, args ...interface{}) {
+	Debug("Pyroscope: "+fmt.Sprintf(format, args...), nil)
+}
+
+func (profilingLogger) Errorf(format string, args ...interface{}) {
+	Warn("Pyroscope error", map[string]interface{}{
+		"error": fmt.Sprintf(format, args...),
+	})
+}
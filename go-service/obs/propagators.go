@@ -0,0 +1,58 @@
+package obs
+
+import (
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+
+	"go-service/internal/env"
+)
+
+// defaultPropagators matches the OTel spec default for OTEL_PROPAGATORS
+const defaultPropagators = "tracecontext,baggage"
+
+// newPropagator builds the composite propagator from OTEL_PROPAGATORS
+// Returns the propagator and the names that were actually enabled
+func newPropagator() (propagation.TextMapPropagator, []string) {
+	var propagators []propagation.TextMapPropagator
+	var enabled []string
+
+	for _, name := range strings.Split(env.String("OTEL_PROPAGATORS", defaultPropagators), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		var p propagation.TextMapPropagator
+		switch name {
+		case "":
+			continue
+		case "none":
+			// Explicitly disables propagation
+			return propagation.NewCompositeTextMapPropagator(), []string{"none"}
+		case "tracecontext":
+			p = propagation.TraceContext{}
+		case "baggage":
+			p = propagation.Baggage{}
+		case "b3":
+			p = b3.New(b3.WithInjectEncoding(b3.B3SingleHeader))
+		case "b3multi":
+			p = b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader))
+		case "jaeger":
+			p = jaeger.Jaeger{}
+		default:
+			Warn("Unknown propagator in OTEL_PROPAGATORS, ignoring", map[string]interface{}{
+				"value": name,
+			})
+			continue
+		}
+		propagators = append(propagators, p)
+		enabled = append(enabled, name)
+	}
+
+	if len(propagators) == 0 {
+		return propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		), strings.Split(defaultPropagators, ",")
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), enabled
+}
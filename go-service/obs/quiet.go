@@ -0,0 +1,38 @@
+package obs
+
+import (
+	"context"
+
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QuietSignals are the signals suppressed for a request marked by WithQuiet
+type QuietSignals struct {
+	Traces  bool `json:"traces"`
+	Logs    bool `json:"logs"`
+	Metrics bool `json:"metrics"`
+}
+
+type quietKey struct{}
+
+// WithQuiet marks ctx as noise, such as a health probe or metrics scrape: with Traces no
+// span started from it is sampled, and Logs and Metrics tell the request logging and
+// metrics middleware to skip it. WithDebug still wins for traces.
+func WithQuiet(ctx context.Context, signals QuietSignals) context.Context {
+	return context.WithValue(ctx, quietKey{}, signals)
+}
+
+// Quiet returns the signals suppressed for ctx by WithQuiet, none by default
+func Quiet(ctx context.Context) QuietSignals {
+	signals, _ := ctx.Value(quietKey{}).(QuietSignals)
+	return signals
+}
+
+// quietDropped is the decision for spans started from a context whose traces are quiet
+func quietDropped(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	return tracesdk.SamplingResult{
+		Decision:   tracesdk.Drop,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
@@ -0,0 +1,258 @@
+package obs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+
+	"go-service/internal/env"
+)
+
+// errExporterUnavailable is returned for exports attempted before an exporter could be created
+var errExporterUnavailable = errors.New("exporter not connected")
+
+var (
+	exporterReconnectAfter = env.Int("OTEL_EXPORTER_RECONNECT_AFTER", 5)
+	exporterMaxBackoff     = env.Duration("OTEL_EXPORTER_RETRY_MAX_BACKOFF", time.Minute)
+
+	otelExportFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "otel_export_failures_total",
+			Help: "Total number of failed or dropped OTLP export batches by signal and collector",
+		},
+		[]string{"signal", "endpoint"},
+	)
+
+	otelExporterConnected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "otel_exporter_connected",
+			Help: "Whether the last OTLP export for a signal to a collector succeeded (1) or failed (0)",
+		},
+		[]string{"signal", "endpoint"},
+	)
+
+	otelExporterReconnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "otel_exporter_reconnects_total",
+			Help: "Total number of times an OTLP exporter was recreated by signal and collector",
+		},
+		[]string{"signal", "endpoint"},
+	)
+)
+
+func init() {
+	Registry.MustRegister(otelExportFailures)
+	Registry.MustRegister(otelExporterConnected)
+	Registry.MustRegister(otelExporterReconnects)
+}
+
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// exporterConn owns an exporter that is (re)created in the background with
+// exponential backoff, both when creation fails at startup and after
+// exporterReconnectAfter consecutive export failures.
+type exporterConn[T shutdowner] struct {
+	signal   string
+	endpoint string
+	create   func(context.Context) (T, error)
+
+	mu           sync.Mutex
+	current      T
+	ready        bool
+	failures     int
+	reconnecting bool
+	done         chan struct{}
+	doneOnce     sync.Once
+}
+
+func newExporterConn[T shutdowner](signal, endpoint string, create func(context.Context) (T, error)) *exporterConn[T] {
+	c := &exporterConn[T]{signal: signal, endpoint: endpoint, create: create, done: make(chan struct{})}
+	exp, err := create(context.Background())
+	if err != nil {
+		Error("Failed to create exporter, retrying in background", map[string]interface{}{
+			"signal":   signal,
+			"endpoint": endpoint,
+			"error":    err.Error(),
+		})
+		otelExporterConnected.WithLabelValues(signal, endpoint).Set(0)
+		c.reconnecting = true
+		go c.reconnect()
+		return c
+	}
+	c.current, c.ready = exp, true
+	otelExporterConnected.WithLabelValues(signal, endpoint).Set(1)
+	return c
+}
+
+func (c *exporterConn[T]) get() (T, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current, c.ready
+}
+
+// observe records the outcome of an export and schedules a reconnect after repeated failures
+func (c *exporterConn[T]) observe(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err == nil {
+		if c.failures > 0 {
+			Info("Telemetry export recovered", map[string]interface{}{
+				"signal":          c.signal,
+				"endpoint":        c.endpoint,
+				"failed_attempts": c.failures,
+			})
+		}
+		c.failures = 0
+		otelExporterConnected.WithLabelValues(c.signal, c.endpoint).Set(1)
+		return
+	}
+
+	otelExportFailures.WithLabelValues(c.signal, c.endpoint).Inc()
+	otelExporterConnected.WithLabelValues(c.signal, c.endpoint).Set(0)
+	c.failures++
+	if c.failures == 1 {
+		Warn("Telemetry export failing", map[string]interface{}{
+			"signal":   c.signal,
+			"endpoint": c.endpoint,
+			"error":    err.Error(),
+		})
+	}
+	if c.ready && !c.reconnecting && exporterReconnectAfter > 0 && c.failures%exporterReconnectAfter == 0 {
+		c.reconnecting = true
+		go c.reconnect()
+	}
+}
+
+// reconnect creates a fresh exporter, backing off until it succeeds or Shutdown is called
+func (c *exporterConn[T]) reconnect() {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		exp, err := c.create(context.Background())
+		if err == nil {
+			c.mu.Lock()
+			select {
+			case <-c.done:
+				// Shut down while connecting: nothing would ever shut this exporter down
+				c.reconnecting = false
+				c.mu.Unlock()
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				exp.Shutdown(ctx)
+				cancel()
+				return
+			default:
+			}
+			old, hadOld := c.current, c.ready
+			c.current, c.ready, c.reconnecting = exp, true, false
+			c.mu.Unlock()
+
+			otelExporterReconnects.WithLabelValues(c.signal, c.endpoint).Inc()
+			Info("Exporter recreated", map[string]interface{}{
+				"signal":   c.signal,
+				"endpoint": c.endpoint,
+				"attempts": attempt,
+			})
+			if hadOld {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				old.Shutdown(ctx)
+				cancel()
+			}
+			return
+		}
+
+		Warn("Exporter creation failed", map[string]interface{}{
+			"signal":          c.signal,
+			"endpoint":        c.endpoint,
+			"attempt":         attempt,
+			"error":           err.Error(),
+			"backoff_seconds": backoff.Seconds(),
+		})
+		select {
+		case <-c.done:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > exporterMaxBackoff {
+			backoff = exporterMaxBackoff
+		}
+	}
+}
+
+// Shutdown stops reconnecting and shuts the current exporter down; later calls, and exports
+// after it, find no exporter
+func (c *exporterConn[T]) Shutdown(ctx context.Context) error {
+	c.doneOnce.Do(func() { close(c.done) })
+	c.mu.Lock()
+	exp, ok := c.current, c.ready
+	c.ready = false
+	c.mu.Unlock()
+	if ok {
+		return exp.Shutdown(ctx)
+	}
+	return nil
+}
+
+// reconnectingSpanExporter delegates to whichever span exporter is currently connected
+type reconnectingSpanExporter struct {
+	*exporterConn[tracesdk.SpanExporter]
+}
+
+func newReconnectingSpanExporter(endpoint string, create func(context.Context) (tracesdk.SpanExporter, error)) tracesdk.SpanExporter {
+	return reconnectingSpanExporter{newExporterConn("traces", endpoint, create)}
+}
+
+func (e reconnectingSpanExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	exp, ok := e.get()
+	if !ok {
+		e.observe(errExporterUnavailable)
+		return errExporterUnavailable
+	}
+	err := exp.ExportSpans(ctx, spans)
+	e.observe(err)
+	return err
+}
+
+// reconnectingMetricExporter delegates to whichever metric exporter is currently connected.
+// Temporality and aggregation come from config so they are known before the first connection.
+type reconnectingMetricExporter struct {
+	*exporterConn[metricsdk.Exporter]
+	temporality metricsdk.TemporalitySelector
+}
+
+func newReconnectingMetricExporter(endpoint string, temporality metricsdk.TemporalitySelector, create func(context.Context) (metricsdk.Exporter, error)) metricsdk.Exporter {
+	return reconnectingMetricExporter{newExporterConn("metrics", endpoint, create), temporality}
+}
+
+func (e reconnectingMetricExporter) Temporality(k metricsdk.InstrumentKind) metricdata.Temporality {
+	return e.temporality(k)
+}
+
+func (e reconnectingMetricExporter) Aggregation(k metricsdk.InstrumentKind) metricsdk.Aggregation {
+	return metricsdk.DefaultAggregationSelector(k)
+}
+
+func (e reconnectingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	exp, ok := e.get()
+	if !ok {
+		e.observe(errExporterUnavailable)
+		return errExporterUnavailable
+	}
+	err := exp.Export(ctx, rm)
+	e.observe(err)
+	return err
+}
+
+func (e reconnectingMetricExporter) ForceFlush(ctx context.Context) error {
+	if exp, ok := e.get(); ok {
+		return exp.ForceFlush(ctx)
+	}
+	return nil
+}
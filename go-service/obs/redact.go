@@ -0,0 +1,77 @@
+package obs
+
+import (
+	"regexp"
+	"strings"
+
+	"go-service/internal/env"
+)
+
+// redactedValue replaces the value of any sensitive log field
+const redactedValue = "[REDACTED]"
+
+var defaultRedactor = newRedactor(
+	env.String("LOG_REDACT_FIELDS", "authorization,proxy-authorization,cookie,set-cookie,x-admin-token"),
+	env.String("LOG_REDACT_PATTERN", `(?i)(password|passwd|secret|token|api[_-]?key|credential)`),
+)
+
+func init() {
+	if defaultRedactor.err != nil {
+		Warn("Invalid LOG_REDACT_PATTERN, redacting listed fields only", map[string]interface{}{
+			"error": defaultRedactor.err.Error(),
+		})
+	}
+}
+
+// redactor masks log fields whose key is listed exactly (case-insensitive) or matches pattern
+type redactor struct {
+	fields  map[string]bool
+	pattern *regexp.Regexp
+	err     error
+}
+
+func newRedactor(fields, pattern string) *redactor {
+	r := &redactor{fields: make(map[string]bool)}
+	for _, f := range env.Split(fields) {
+		r.fields[strings.ToLower(f)] = true
+	}
+	if pattern != "" {
+		r.pattern, r.err = regexp.Compile(pattern)
+	}
+	return r
+}
+
+func (r *redactor) sensitive(key string) bool {
+	return r.fields[strings.ToLower(key)] || (r.pattern != nil && r.pattern.MatchString(key))
+}
+
+// redact returns fields with sensitive values masked, recursing into nested maps.
+// The input is never modified since callers may reuse their field maps.
+func (r *redactor) redact(fields map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if r.sensitive(k) {
+			out[k] = redactedValue
+			continue
+		}
+		switch nested := v.(type) {
+		case map[string]interface{}:
+			out[k] = r.redact(nested)
+		case map[string]string:
+			m := make(map[string]interface{}, len(nested))
+			for nk, nv := range nested {
+				m[nk] = nv
+			}
+			out[k] = r.redact(m)
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// IsSensitive reports whether logs mask values stored under name, so callers can
+// avoid emitting the same data elsewhere (e.g. as span attributes)
+func IsSensitive(name string) bool {
+	return defaultRedactor.sensitive(name)
+}
@@ -0,0 +1,38 @@
+package obs
+
+import "testing"
+
+func TestRedactorMasksListedAndMatchingKeys(t *testing.T) {
+	r := newRedactor("authorization,cookie", `(?i)token`)
+	in := map[string]interface{}{
+		"Authorization": "Bearer abc",
+		"user_token":    "xyz",
+		"path":          "/users",
+		"query":         map[string]interface{}{"cookie": "c", "page": "2"},
+	}
+	out := r.redact(in)
+
+	if out["Authorization"] != redactedValue || out["user_token"] != redactedValue {
+		t.Errorf("sensitive values leaked: %v", out)
+	}
+	if out["path"] != "/users" {
+		t.Errorf("path changed: %v", out["path"])
+	}
+	nested := out["query"].(map[string]interface{})
+	if nested["cookie"] != redactedValue || nested["page"] != "2" {
+		t.Errorf("nested map not redacted: %v", nested)
+	}
+	if in["Authorization"] != "Bearer abc" {
+		t.Error("redact modified its input")
+	}
+}
+
+func TestRedactorInvalidPatternFallsBackToList(t *testing.T) {
+	r := newRedactor("password", "(")
+	if r.err == nil {
+		t.Fatal("expected compile error")
+	}
+	if !r.sensitive("PASSWORD") || r.sensitive("token") {
+		t.Error("list-only redaction not applied")
+	}
+}
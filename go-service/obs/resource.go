@@ -0,0 +1,61 @@
+package obs
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+)
+
+// k8sEnvAttributes maps downward-API env vars (set in the pod spec) to resource attributes
+var k8sEnvAttributes = []struct {
+	env  string
+	attr func(string) attribute.KeyValue
+}{
+	{"K8S_POD_NAME", semconv.K8SPodName},
+	{"K8S_POD_UID", semconv.K8SPodUID},
+	{"K8S_NAMESPACE_NAME", semconv.K8SNamespaceName},
+	{"K8S_NODE_NAME", semconv.K8SNodeName},
+	{"K8S_DEPLOYMENT_NAME", semconv.K8SDeploymentName},
+	{"K8S_CLUSTER_NAME", semconv.K8SClusterName},
+	{"K8S_CONTAINER_NAME", semconv.K8SContainerName},
+}
+
+// newResource describes where this process runs: service identity, host, container,
+// process, OS and Kubernetes attributes, with OTEL_RESOURCE_ATTRIBUTES and
+// OTEL_SERVICE_NAME applied last so operators can override anything
+func newResource(ctx context.Context, cfg Config) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		semconv.ServiceNameKey.String(cfg.ServiceName),
+		semconv.DeploymentEnvironment(cfg.Environment),
+	}
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, semconv.ServiceVersion(cfg.ServiceVersion))
+	}
+	for _, k := range k8sEnvAttributes {
+		if v := os.Getenv(k.env); v != "" {
+			attrs = append(attrs, k.attr(v))
+		}
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithOS(),
+		resource.WithContainer(),
+		resource.WithProcess(),
+		resource.WithAttributes(attrs...),
+		resource.WithFromEnv(),
+	)
+	// Some detectors (e.g. container ID outside a container) fail routinely; keep what was found
+	if errors.Is(err, resource.ErrPartialResource) || errors.Is(err, resource.ErrSchemaURLConflict) {
+		Warn("Resource detection incomplete", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return res, nil
+	}
+	return res, err
+}
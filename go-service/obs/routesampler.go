@@ -0,0 +1,142 @@
+package obs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// routeRule samples root server spans whose route matches Route at Ratio. Route is a mux
+// path template ("/users/{id}") or a request path, and may contain path.Match wildcards
+// ("/debug/*").
+type routeRule struct {
+	Route string
+	Ratio float64
+}
+
+// loadRouteRules reads OTEL_TRACES_SAMPLER_ROUTES (inline JSON) or
+// OTEL_TRACES_SAMPLER_ROUTES_FILE, an object of route to ratio:
+//
+//	{"/error": 1, "/health": 0.01, "/users/{id}": 0.25}
+//
+// No config means no rules
+func loadRouteRules() ([]routeRule, error) {
+	raw := os.Getenv("OTEL_TRACES_SAMPLER_ROUTES")
+	if file := os.Getenv("OTEL_TRACES_SAMPLER_ROUTES_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading route sampling file: %w", err)
+		}
+		raw = string(data)
+	}
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var ratios map[string]float64
+	if err := json.Unmarshal([]byte(raw), &ratios); err != nil {
+		return nil, fmt.Errorf("parsing route sampling rules: %w", err)
+	}
+	rules := make([]routeRule, 0, len(ratios))
+	for route, ratio := range ratios {
+		if ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("route sampling rule %q: ratio must be between 0 and 1", route)
+		}
+		if _, err := path.Match(route, ""); err != nil {
+			return nil, fmt.Errorf("route sampling rule %q: %w", route, err)
+		}
+		rules = append(rules, routeRule{route, ratio})
+	}
+	// Exact routes win over wildcards, then longer patterns over shorter ones
+	sort.Slice(rules, func(i, j int) bool {
+		wi, wj := strings.ContainsAny(rules[i].Route, "*?["), strings.ContainsAny(rules[j].Route, "*?[")
+		if wi != wj {
+			return !wi
+		}
+		if len(rules[i].Route) != len(rules[j].Route) {
+			return len(rules[i].Route) > len(rules[j].Route)
+		}
+		return rules[i].Route < rules[j].Route
+	})
+	return rules, nil
+}
+
+// routeSampler applies a per-route ratio to root spans and leaves everything else, including
+// every span with a parent, to the configured sampler. With the default parent-based
+// sampler a route's decision is therefore inherited by the whole trace below it, and a
+// sampled upstream caller is never overridden.
+type routeSampler struct {
+	rules    []routeRule
+	samplers []tracesdk.Sampler
+	fallback tracesdk.Sampler
+}
+
+// newRouteSampler wraps fallback with the rules from the environment; invalid config is
+// logged and ignored
+func newRouteSampler(fallback tracesdk.Sampler) tracesdk.Sampler {
+	rules, err := loadRouteRules()
+	if err != nil {
+		Error("Failed to load route sampling rules, continuing without them", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return fallback
+	}
+	if len(rules) == 0 {
+		return fallback
+	}
+	s := routeSampler{rules: rules, fallback: fallback}
+	for _, rule := range rules {
+		s.samplers = append(s.samplers, tracesdk.TraceIDRatioBased(rule.Ratio))
+	}
+	return s
+}
+
+func (s routeSampler) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	if !trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		if i := s.match(p); i >= 0 {
+			return s.samplers[i].ShouldSample(p)
+		}
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+// match returns the index of the first rule matching the span's http.route, or its
+// http.target for unrouted requests, or -1
+func (s routeSampler) match(p tracesdk.SamplingParameters) int {
+	var route, target string
+	for _, kv := range p.Attributes {
+		switch kv.Key {
+		case semconv.HTTPRouteKey:
+			route = kv.Value.AsString()
+		case semconv.HTTPTargetKey:
+			target, _, _ = strings.Cut(kv.Value.AsString(), "?")
+		}
+	}
+	for _, candidate := range []string{route, target} {
+		if candidate == "" {
+			continue
+		}
+		for i, rule := range s.rules {
+			if ok, _ := path.Match(rule.Route, candidate); ok {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func (s routeSampler) Description() string {
+	rules := make([]string, len(s.rules))
+	for i, rule := range s.rules {
+		rules[i] = rule.Route + "=" + strconv.FormatFloat(rule.Ratio, 'g', -1, 64)
+	}
+	return "RouteSampler{" + strings.Join(rules, ",") + ";" + s.fallback.Description() + "}"
+}
@@ -0,0 +1,113 @@
+package obs
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+
+	"go-service/internal/env"
+)
+
+// defaultSampler matches the SDK default when OTEL_TRACES_SAMPLER is unset
+const defaultSampler = "parentbased_always_on"
+
+// newSampler builds the trace sampler from OTEL_TRACES_SAMPLER / OTEL_TRACES_SAMPLER_ARG,
+// overridden for root spans of the routes in OTEL_TRACES_SAMPLER_ROUTES
+func newSampler() tracesdk.Sampler {
+	return newRouteSampler(newConfiguredSampler())
+}
+
+// newConfiguredSampler builds the sampler named by OTEL_TRACES_SAMPLER. Unknown samplers
+// and invalid ratios fall back to the SDK default with a warning
+func newConfiguredSampler() tracesdk.Sampler {
+	name := strings.ToLower(strings.TrimSpace(env.String("OTEL_TRACES_SAMPLER", defaultSampler)))
+	arg := os.Getenv("OTEL_TRACES_SAMPLER_ARG")
+
+	ratio := 1.0
+	if arg != "" {
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil || v < 0 || v > 1 {
+			Warn("Invalid OTEL_TRACES_SAMPLER_ARG, using 1.0", map[string]interface{}{
+				"value": arg,
+			})
+		} else {
+			ratio = v
+		}
+	}
+
+	switch name {
+	case "always_on":
+		return tracesdk.AlwaysSample()
+	case "always_off":
+		return tracesdk.NeverSample()
+	case "traceidratio":
+		return tracesdk.TraceIDRatioBased(ratio)
+	case "parentbased_always_on":
+		return tracesdk.ParentBased(tracesdk.AlwaysSample())
+	case "parentbased_always_off":
+		return tracesdk.ParentBased(tracesdk.NeverSample())
+	case "parentbased_traceidratio":
+		return tracesdk.ParentBased(tracesdk.TraceIDRatioBased(ratio))
+	default:
+		Warn("Unknown OTEL_TRACES_SAMPLER, using default", map[string]interface{}{
+			"value":   name,
+			"default": defaultSampler,
+		})
+		return tracesdk.ParentBased(tracesdk.AlwaysSample())
+	}
+}
+
+// swappableSampler delegates to a sampler that ReloadSampler can replace at runtime,
+// except for WithDebug contexts, which are always sampled, WithQuiet contexts, which never
+// are, and root spans shed by the memory guard
+type swappableSampler struct {
+	current atomic.Pointer[samplerBox]
+}
+
+type samplerBox struct{ tracesdk.Sampler }
+
+func (s *swappableSampler) set(sampler tracesdk.Sampler) {
+	s.current.Store(&samplerBox{sampler})
+}
+
+func (s *swappableSampler) ShouldSample(p tracesdk.SamplingParameters) tracesdk.SamplingResult {
+	if p.ParentContext != nil {
+		if DebugEnabled(p.ParentContext) {
+			return debugSampled(p)
+		}
+		if Quiet(p.ParentContext).Traces {
+			return quietDropped(p)
+		}
+	}
+	res := s.current.Load().ShouldSample(p)
+	if res.Decision == tracesdk.RecordAndSample && memory.Load().shedSpan(p) {
+		return tracesdk.SamplingResult{Decision: tracesdk.Drop, Tracestate: res.Tracestate}
+	}
+	return res
+}
+
+func (s *swappableSampler) Description() string {
+	return s.current.Load().Description()
+}
+
+// activeSampler is installed on the tracer provider by Init
+var activeSampler = func() *swappableSampler {
+	s := &swappableSampler{}
+	s.set(tracesdk.ParentBased(tracesdk.AlwaysSample()))
+	return s
+}()
+
+// ReloadSampler re-reads OTEL_TRACES_SAMPLER, OTEL_TRACES_SAMPLER_ARG and the route rules
+// and swaps the sampler used by the tracer provider, returning the new description
+func ReloadSampler() string {
+	activeSampler.set(newSampler())
+	description := activeSampler.Description()
+
+	settingsMu.Lock()
+	settings.Sampler = description
+	settingsMu.Unlock()
+	return description
+}
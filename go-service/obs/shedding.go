@@ -0,0 +1,201 @@
+package obs
+
+import (
+	"context"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/internal/env"
+)
+
+var (
+	telemetryShedding = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "telemetry_shedding_active",
+		Help: "Whether telemetry is being shed because the heap is above TELEMETRY_SHED_HEAP_MB (1) or not (0)",
+	})
+
+	telemetryShed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_shed_total",
+			Help: "Root spans not sampled, logs dropped and metric exports skipped under memory pressure",
+		},
+		[]string{"signal"},
+	)
+
+	telemetryShedTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_shed_transitions_total",
+			Help: "Total number of times telemetry shedding started or stopped",
+		},
+		[]string{"state"},
+	)
+)
+
+func init() {
+	Registry.MustRegister(telemetryShedding, telemetryShed, telemetryShedTransitions)
+}
+
+// memoryGuard sheds telemetry while the heap is above threshold: root spans are sampled
+// at ratio on top of the configured sampler, DEBUG and INFO logs are dropped and only
+// every metricFactor-th metric export is sent. Shedding stops once the heap falls below
+// 90% of the threshold, so it does not flap around it.
+type memoryGuard struct {
+	threshold    uint64 // bytes; 0 disables the guard
+	ratio        float64
+	metricFactor int
+	sampler      tracesdk.Sampler
+	heap         func() uint64
+
+	mu       sync.Mutex
+	shedding atomic.Bool
+}
+
+// memory holds the process-wide guard, replaced by Init; the zero guard never sheds
+var memory atomic.Pointer[memoryGuard]
+
+func init() {
+	memory.Store(&memoryGuard{})
+}
+
+func newMemoryGuard() *memoryGuard {
+	ratio := 0.1
+	raw := env.String("TELEMETRY_SHED_SAMPLE_RATIO", "")
+	if raw != "" {
+		if v, err := strconv.ParseFloat(raw, 64); err == nil && v >= 0 && v <= 1 {
+			ratio = v
+		} else {
+			Warn("Invalid TELEMETRY_SHED_SAMPLE_RATIO, using 0.1", map[string]interface{}{
+				"value": raw,
+			})
+		}
+	}
+	return &memoryGuard{
+		threshold:    uint64(env.Int("TELEMETRY_SHED_HEAP_MB", 0)) << 20,
+		ratio:        ratio,
+		metricFactor: parsePositiveInt("TELEMETRY_SHED_METRIC_INTERVAL_FACTOR", 4),
+		sampler:      tracesdk.TraceIDRatioBased(ratio),
+		heap:         heapObjectBytes,
+	}
+}
+
+// startMemoryGuard installs the guard and checks the heap every
+// TELEMETRY_SHED_CHECK_INTERVAL; stop is a no-op when TELEMETRY_SHED_HEAP_MB is unset
+func startMemoryGuard() (stop func()) {
+	g := newMemoryGuard()
+	memory.Store(g)
+	telemetryShedding.Set(0)
+	if g.threshold == 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	ticker := time.NewTicker(env.Duration("TELEMETRY_SHED_CHECK_INTERVAL", 5*time.Second))
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				g.check()
+			}
+		}
+	}()
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
+
+// heapObjectBytes reads the live and not-yet-swept heap objects without stopping the world
+func heapObjectBytes() uint64 {
+	samples := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(samples)
+	if samples[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return samples[0].Value.Uint64()
+}
+
+// check compares the heap with the threshold and logs every change of state
+func (g *memoryGuard) check() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	heap := g.heap()
+	fields := map[string]interface{}{
+		"heap_bytes":      heap,
+		"threshold_bytes": g.threshold,
+	}
+	switch {
+	case !g.shedding.Load() && heap >= g.threshold:
+		g.shedding.Store(true)
+		telemetryShedding.Set(1)
+		telemetryShedTransitions.WithLabelValues("started").Inc()
+		fields["sample_ratio"] = g.ratio
+		fields["metric_interval_factor"] = g.metricFactor
+		Warn("Heap above threshold, shedding telemetry", fields)
+	case g.shedding.Load() && heap < g.threshold/10*9:
+		g.shedding.Store(false)
+		telemetryShedding.Set(0)
+		telemetryShedTransitions.WithLabelValues("stopped").Inc()
+		Warn("Heap back below threshold, telemetry restored", fields)
+	}
+}
+
+// shedSpan reports whether a span the sampler kept should be dropped. Only root spans
+// are shed, so sampled traces stay complete.
+func (g *memoryGuard) shedSpan(p tracesdk.SamplingParameters) bool {
+	if !g.shedding.Load() || trace.SpanContextFromContext(p.ParentContext).IsValid() {
+		return false
+	}
+	if g.sampler.ShouldSample(p).Decision == tracesdk.RecordAndSample {
+		return false
+	}
+	telemetryShed.WithLabelValues("traces").Inc()
+	return true
+}
+
+// shedLog reports whether a record at level should be dropped; WARN and above, and levels
+// outside levelSeverity, are always kept
+func (g *memoryGuard) shedLog(level string) bool {
+	if !g.shedding.Load() {
+		return false
+	}
+	if sev, ok := levelSeverity[level]; !ok || sev >= levelSeverity["WARN"] {
+		return false
+	}
+	telemetryShed.WithLabelValues("logs").Inc()
+	logRecordsDropped.WithLabelValues(level, "memory_pressure").Inc()
+	return true
+}
+
+// sheddingMetricExporter skips exports while shedding until interval*metricFactor has
+// passed since the last one. With cumulative temporality nothing is lost; with delta the
+// skipped collections are.
+type sheddingMetricExporter struct {
+	metricsdk.Exporter
+	interval time.Duration
+	last     *atomic.Int64 // unix nanoseconds of the last export
+}
+
+func newSheddingMetricExporter(exp metricsdk.Exporter, interval time.Duration) metricsdk.Exporter {
+	return sheddingMetricExporter{exp, interval, new(atomic.Int64)}
+}
+
+func (e sheddingMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	now := time.Now()
+	g := memory.Load()
+	if g.shedding.Load() && now.Sub(time.Unix(0, e.last.Load())) < e.interval*time.Duration(g.metricFactor) {
+		telemetryShed.WithLabelValues("metrics").Inc()
+		return nil
+	}
+	e.last.Store(now.UnixNano())
+	return e.Exporter.Export(ctx, rm)
+}
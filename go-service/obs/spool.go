@@ -0,0 +1,435 @@
+package obs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"go-service/internal/env"
+)
+
+var (
+	spoolDir      = env.String("TELEMETRY_SPOOL_DIR", "")
+	spoolMaxBytes = int64(env.Int("TELEMETRY_SPOOL_MAX_MB", 64)) << 20
+	spoolMaxAge   = env.Duration("TELEMETRY_SPOOL_MAX_AGE", time.Hour)
+
+	spoolSpooled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_spool_spooled_items_total",
+			Help: "Total number of spans and metric data points written to the on-disk spool while the collector was unreachable",
+		},
+		[]string{"signal", "endpoint"},
+	)
+
+	spoolReplayed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_spool_replayed_items_total",
+			Help: "Total number of spooled spans and metric data points delivered once the collector was reachable again",
+		},
+		[]string{"signal", "endpoint"},
+	)
+
+	spoolDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "telemetry_spool_dropped_items_total",
+			Help: "Total number of spooled items discarded: over the size limit, older than the age limit, rejected by the collector or unreadable",
+		},
+		[]string{"signal", "endpoint", "reason"},
+	)
+
+	spoolSize = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telemetry_spool_size_bytes",
+			Help: "Bytes of telemetry waiting in the on-disk spool",
+		},
+		[]string{"signal", "endpoint"},
+	)
+
+	spoolBatches = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "telemetry_spool_batches",
+			Help: "Export batches waiting in the on-disk spool",
+		},
+		[]string{"signal", "endpoint"},
+	)
+)
+
+func init() {
+	Registry.MustRegister(spoolSpooled, spoolReplayed, spoolDropped, spoolSize, spoolBatches)
+}
+
+// spoolReplayTimeout bounds each replayed export
+const spoolReplayTimeout = 10 * time.Second
+
+// spoolFile is one spooled export request, named <unix nanos>-<seq>-<items>.pb
+type spoolFile struct {
+	path    string
+	size    int64
+	created time.Time
+	items   int
+}
+
+// spool keeps OTLP gRPC export requests on disk while the collector is unreachable and
+// replays them, oldest first, after the next export that gets through. It works below
+// the exporter, on the serialized request, so traces and metrics share one code path and
+// a batch survives a restart. The oldest batches are dropped beyond maxBytes and on replay
+// once older than maxAge.
+type spool struct {
+	signal   string
+	endpoint string
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+
+	mu        sync.Mutex
+	files     []spoolFile // oldest first
+	bytes     int64
+	seq       uint64
+	replaying atomic.Bool
+}
+
+var (
+	spoolsMu sync.Mutex
+	spools   = map[string]*spool{}
+)
+
+// spoolFor returns the spool for a signal and collector, shared by every exporter created
+// for it across reconnects, or nil when TELEMETRY_SPOOL_DIR is unset or unusable
+func spoolFor(signal, endpoint string) *spool {
+	if spoolDir == "" {
+		return nil
+	}
+	spoolsMu.Lock()
+	defer spoolsMu.Unlock()
+	key := signal + " " + endpoint
+	if s, ok := spools[key]; ok {
+		return s
+	}
+	dir := filepath.Join(spoolDir, spoolDirName(endpoint), signal)
+	s, err := newSpool(signal, endpoint, dir, spoolMaxBytes, spoolMaxAge)
+	if err != nil {
+		Error("Failed to open telemetry spool, exporting without it", map[string]interface{}{
+			"signal":   signal,
+			"endpoint": endpoint,
+			"error":    err.Error(),
+		})
+		return nil
+	}
+	spools[key] = s
+	return s
+}
+
+// spoolDirName makes an endpoint such as "otel-collector:4317" safe as a directory name
+func spoolDirName(endpoint string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, endpoint)
+}
+
+// newSpool opens dir, picking up batches left by a previous run
+func newSpool(signal, endpoint, dir string, maxBytes int64, maxAge time.Duration) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{signal: signal, endpoint: endpoint, dir: dir, maxBytes: maxBytes, maxAge: maxAge}
+	for _, entry := range entries {
+		f, ok := parseSpoolFile(dir, entry.Name())
+		if !ok {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			f.size = info.Size()
+		}
+		s.files = append(s.files, f)
+		s.bytes += f.size
+	}
+	sort.Slice(s.files, func(i, j int) bool { return s.files[i].path < s.files[j].path })
+	if len(s.files) > 0 {
+		Info("Found spooled telemetry from a previous run", map[string]interface{}{
+			"signal":   signal,
+			"endpoint": endpoint,
+			"batches":  len(s.files),
+			"bytes":    s.bytes,
+		})
+	}
+	s.updateGauges()
+	return s, nil
+}
+
+func parseSpoolFile(dir, name string) (spoolFile, bool) {
+	parts := strings.Split(strings.TrimSuffix(name, ".pb"), "-")
+	if !strings.HasSuffix(name, ".pb") || len(parts) != 3 {
+		return spoolFile{}, false
+	}
+	nanos, err1 := strconv.ParseInt(parts[0], 10, 64)
+	items, err2 := strconv.Atoi(parts[2])
+	if err1 != nil || err2 != nil {
+		return spoolFile{}, false
+	}
+	return spoolFile{path: filepath.Join(dir, name), created: time.Unix(0, nanos), items: items}, true
+}
+
+// interceptor returns a gRPC unary client interceptor for one exporter's connection. A
+// request that fails because the collector is unreachable is spooled, and the error
+// returned is not retryable, so the exporter does not resend what the spool already holds.
+// Replays it starts stop once replayCtx is done, i.e. when that exporter shuts down.
+func (s *spool) interceptor(replayCtx context.Context) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return s.intercept(replayCtx, ctx, method, req, reply, cc, invoker, opts...)
+	}
+}
+
+func (s *spool) intercept(replayCtx, ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err == nil {
+		if replayCtx.Err() == nil && s.pending() && s.replaying.CompareAndSwap(false, true) {
+			go s.replay(replayCtx, method, cc, invoker)
+		}
+		return nil
+	}
+	msg, ok := req.(proto.Message)
+	if !ok || !collectorUnreachable(err) {
+		return err
+	}
+	if werr := s.write(msg); werr != nil {
+		Warn("Failed to spool telemetry batch", map[string]interface{}{
+			"signal":   s.signal,
+			"endpoint": s.endpoint,
+			"error":    werr.Error(),
+		})
+		return err
+	}
+	return fmt.Errorf("collector unreachable (%s), batch spooled for replay", status.Code(err))
+}
+
+// collectorUnreachable reports whether err means the collector could not be reached, as
+// opposed to rejecting the data
+func collectorUnreachable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// replayInterrupted reports whether a replayed batch failed because the replay itself was
+// stopped, e.g. by the exporter shutting down and closing the connection, rather than
+// because the collector rejected it, so the batch is kept for the next replay
+func replayInterrupted(err error) bool {
+	return status.Code(err) == codes.Canceled || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func (s *spool) pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.files) > 0
+}
+
+// write stores msg and trims the spool to maxBytes, oldest batches first
+func (s *spool) write(msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	items := countSpoolItems(msg)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	now := time.Now()
+	name := strconv.FormatInt(now.UnixNano(), 10) + "-" + strconv.FormatUint(s.seq, 10) + "-" + strconv.Itoa(items) + ".pb"
+	f := spoolFile{path: filepath.Join(s.dir, name), size: int64(len(data)), created: now, items: items}
+	// Written under a temporary name so a crash never leaves a partial batch to replay
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	s.files = append(s.files, f)
+	s.bytes += f.size
+	spoolSpooled.WithLabelValues(s.signal, s.endpoint).Add(float64(items))
+	for s.bytes > s.maxBytes && len(s.files) > 0 {
+		s.removeLocked(0, "size")
+	}
+	s.updateGauges()
+	return nil
+}
+
+// removeLocked deletes files[i]; a non-empty reason counts its items as dropped
+func (s *spool) removeLocked(i int, reason string) {
+	f := s.files[i]
+	os.Remove(f.path)
+	s.files = append(s.files[:i], s.files[i+1:]...)
+	s.bytes -= f.size
+	if reason != "" {
+		spoolDropped.WithLabelValues(s.signal, s.endpoint, reason).Add(float64(f.items))
+	}
+}
+
+func (s *spool) updateGauges() {
+	spoolSize.WithLabelValues(s.signal, s.endpoint).Set(float64(s.bytes))
+	spoolBatches.WithLabelValues(s.signal, s.endpoint).Set(float64(len(s.files)))
+}
+
+// replay sends spooled batches over the connection that just succeeded until the spool is
+// empty, the collector becomes unreachable again or ctx is done. The caller sets
+// s.replaying.
+func (s *spool) replay(ctx context.Context, method string, cc *grpc.ClientConn, invoker grpc.UnaryInvoker) {
+	defer s.replaying.Store(false)
+	var batches, items int
+	for ctx.Err() == nil {
+		s.mu.Lock()
+		if len(s.files) == 0 {
+			s.mu.Unlock()
+			break
+		}
+		f := s.files[0]
+		if time.Since(f.created) > s.maxAge {
+			s.removeLocked(0, "age")
+			s.updateGauges()
+			s.mu.Unlock()
+			continue
+		}
+		s.mu.Unlock()
+
+		err := s.send(ctx, f, method, cc, invoker)
+		if err != nil && (collectorUnreachable(err) || replayInterrupted(err)) {
+			break
+		}
+		reason := ""
+		switch {
+		case errors.Is(err, errSpoolCorrupt):
+			reason = "corrupt"
+		case err != nil:
+			reason = "rejected"
+		default:
+			batches++
+			items += f.items
+			spoolReplayed.WithLabelValues(s.signal, s.endpoint).Add(float64(f.items))
+		}
+		s.mu.Lock()
+		if len(s.files) > 0 && s.files[0].path == f.path {
+			s.removeLocked(0, reason)
+			s.updateGauges()
+		}
+		s.mu.Unlock()
+	}
+	if batches > 0 {
+		Info("Replayed spooled telemetry", map[string]interface{}{
+			"signal":   s.signal,
+			"endpoint": s.endpoint,
+			"batches":  batches,
+			"items":    items,
+		})
+	}
+}
+
+// spooledSpanExporter stops the replays started over its connection before shutting down
+type spooledSpanExporter struct {
+	tracesdk.SpanExporter
+	stopReplay context.CancelFunc
+}
+
+func (e spooledSpanExporter) Shutdown(ctx context.Context) error {
+	e.stopReplay()
+	return e.SpanExporter.Shutdown(ctx)
+}
+
+// spooledMetricExporter is spooledSpanExporter for metrics
+type spooledMetricExporter struct {
+	metricsdk.Exporter
+	stopReplay context.CancelFunc
+}
+
+func (e spooledMetricExporter) Shutdown(ctx context.Context) error {
+	e.stopReplay()
+	return e.Exporter.Shutdown(ctx)
+}
+
+var errSpoolCorrupt = errors.New("spooled batch is unreadable")
+
+func (s *spool) send(ctx context.Context, f spoolFile, method string, cc *grpc.ClientConn, invoker grpc.UnaryInvoker) error {
+	req, reply := s.newMessages()
+	data, err := os.ReadFile(f.path)
+	if err != nil || proto.Unmarshal(data, req) != nil {
+		return errSpoolCorrupt
+	}
+	ctx, cancel := context.WithTimeout(ctx, spoolReplayTimeout)
+	defer cancel()
+	return invoker(ctx, method, req, reply, cc)
+}
+
+// newMessages returns an empty export request and response for the spool's signal
+func (s *spool) newMessages() (req, reply proto.Message) {
+	if s.signal == "metrics" {
+		return &colmetricpb.ExportMetricsServiceRequest{}, &colmetricpb.ExportMetricsServiceResponse{}
+	}
+	return &coltracepb.ExportTraceServiceRequest{}, &coltracepb.ExportTraceServiceResponse{}
+}
+
+// countSpoolItems counts the spans or metric data points in an export request
+func countSpoolItems(msg proto.Message) int {
+	n := 0
+	switch req := msg.(type) {
+	case *coltracepb.ExportTraceServiceRequest:
+		for _, rs := range req.GetResourceSpans() {
+			for _, ss := range rs.GetScopeSpans() {
+				n += len(ss.GetSpans())
+			}
+		}
+	case *colmetricpb.ExportMetricsServiceRequest:
+		for _, rm := range req.GetResourceMetrics() {
+			for _, sm := range rm.GetScopeMetrics() {
+				for _, m := range sm.GetMetrics() {
+					n += dataPointCount(m)
+				}
+			}
+		}
+	}
+	return n
+}
+
+func dataPointCount(m *metricpb.Metric) int {
+	switch {
+	case m.GetGauge() != nil:
+		return len(m.GetGauge().GetDataPoints())
+	case m.GetSum() != nil:
+		return len(m.GetSum().GetDataPoints())
+	case m.GetHistogram() != nil:
+		return len(m.GetHistogram().GetDataPoints())
+	case m.GetExponentialHistogram() != nil:
+		return len(m.GetExponentialHistogram().GetDataPoints())
+	case m.GetSummary() != nil:
+		return len(m.GetSummary().GetDataPoints())
+	}
+	return 0
+}
@@ -0,0 +1,258 @@
+package obs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go-service/internal/env"
+)
+
+// syslogSDID is the structured-data element carrying log fields (32473 is the documentation PEN)
+const syslogSDID = "fields@32473"
+
+// syslogFacilities maps SYSLOG_FACILITY names to RFC 5424 facility codes
+var syslogFacilities = map[string]int{
+	"user": 1, "daemon": 3,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSeverity maps log levels to RFC 5424 severities, which journald shares as PRIORITY
+func syslogSeverity(level string) int {
+	switch level {
+	case "FATAL":
+		return 2
+	case "ERROR":
+		return 3
+	case "WARN":
+		return 4
+	case "DEBUG":
+		return 7
+	default:
+		return 6
+	}
+}
+
+// recordFields returns the nested "fields" map of an entry, if any
+func recordFields(entry LogEntry) map[string]interface{} {
+	fields, _ := entry["fields"].(map[string]interface{})
+	return fields
+}
+
+// fieldString renders a field value; non-scalar values are JSON-encoded
+func fieldString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case fmt.Stringer:
+		return val.String()
+	case int, int64, float64, bool:
+		return fmt.Sprint(val)
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// syslogSink sends RFC 5424 messages with the log fields as structured data.
+// TCP connections use octet-counting framing (RFC 6587).
+type syslogSink struct {
+	network  string
+	addr     string
+	facility int
+	hostname string
+	appName  string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newSyslogSink() *syslogSink {
+	facility, ok := syslogFacilities[strings.ToLower(env.String("SYSLOG_FACILITY", "local0"))]
+	if !ok {
+		facility = syslogFacilities["local0"]
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	return &syslogSink{
+		network:  env.String("SYSLOG_NETWORK", "udp"),
+		addr:     env.String("SYSLOG_ADDR", "localhost:514"),
+		facility: facility,
+		hostname: hostname,
+		appName:  service,
+	}
+}
+
+// sdEscape escapes an SD-PARAM value per RFC 5424 section 6.3.3
+func sdEscape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
+
+// sdName keeps only characters allowed in an SD-NAME, truncated to 32
+func sdName(k string) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, k)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return name
+}
+
+func (s *syslogSink) format(rec logRecord) []byte {
+	pri := s.facility*8 + syslogSeverity(rec.Level)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d - ", pri, time.Now().UTC().Format(time.RFC3339Nano), s.hostname, s.appName, os.Getpid())
+
+	fields := recordFields(rec.Entry)
+	if len(fields) == 0 {
+		b.WriteString("-")
+	} else {
+		b.WriteString("[" + syslogSDID)
+		for _, k := range sortedKeys(fields) {
+			fmt.Fprintf(&b, ` %s="%s"`, sdName(k), sdEscape(fieldString(fields[k])))
+		}
+		b.WriteString("]")
+	}
+	b.WriteString(" ")
+	b.WriteString(fieldString(rec.Entry["message"]))
+	return b.Bytes()
+}
+
+func (s *syslogSink) Write(rec logRecord) error {
+	msg := s.format(rec)
+	if s.network == "tcp" {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// One redial per record so a restarted syslog daemon is picked up again
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			conn, err := net.DialTimeout(s.network, s.addr, 2*time.Second)
+			if err != nil {
+				return err
+			}
+			s.conn = conn
+		}
+		if _, err := s.conn.Write(msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return fmt.Errorf("syslog write to %s failed", s.addr)
+}
+
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// journaldSink speaks the journald native protocol over its datagram socket
+type journaldSink struct {
+	mu   sync.Mutex
+	conn net.Conn
+	addr string
+}
+
+func newJournaldSink() *journaldSink {
+	return &journaldSink{addr: env.String("JOURNALD_SOCKET", "/run/systemd/journal/socket")}
+}
+
+// journalKey upper-cases a field name into the [A-Z0-9_] alphabet journald accepts
+func journalKey(k string) string {
+	key := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, k)
+	return strings.TrimLeft(key, "_0123456789")
+}
+
+// writeJournalField appends KEY=value, switching to the length-prefixed form for multi-line values
+func writeJournalField(b *bytes.Buffer, key, value string) {
+	if key == "" {
+		return
+	}
+	if !strings.Contains(value, "\n") {
+		b.WriteString(key + "=" + value + "\n")
+		return
+	}
+	b.WriteString(key + "\n")
+	var size [8]byte
+	n := uint64(len(value))
+	for i := 0; i < 8; i++ {
+		size[i] = byte(n >> (8 * i))
+	}
+	b.Write(size[:])
+	b.WriteString(value + "\n")
+}
+
+func (s *journaldSink) Write(rec logRecord) error {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", fieldString(rec.Entry["message"]))
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(syslogSeverity(rec.Level)))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", service)
+	fields := recordFields(rec.Entry)
+	for _, k := range sortedKeys(fields) {
+		writeJournalField(&b, journalKey(k), fieldString(fields[k]))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		conn, err := net.Dial("unixgram", s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if _, err := s.conn.Write(b.Bytes()); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *journaldSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package obs
+
+import (
+	"strings"
+
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"go-service/internal/env"
+)
+
+const (
+	temporalityCumulative = "cumulative"
+	temporalityDelta      = "delta"
+	temporalityLowMemory  = "lowmemory"
+)
+
+// temporalityPreference reads OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE,
+// defaulting to cumulative for unset or unknown values
+func temporalityPreference() string {
+	pref := strings.ToLower(strings.TrimSpace(env.String("OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE", temporalityCumulative)))
+	switch pref {
+	case temporalityCumulative, temporalityDelta, temporalityLowMemory:
+		return pref
+	default:
+		Warn("Unknown metrics temporality preference, using cumulative", map[string]interface{}{
+			"value": pref,
+		})
+		return temporalityCumulative
+	}
+}
+
+// temporalitySelector maps a preference to the per-instrument selection defined by the OTLP spec
+func temporalitySelector(pref string) metricsdk.TemporalitySelector {
+	switch pref {
+	case temporalityDelta:
+		return func(kind metricsdk.InstrumentKind) metricdata.Temporality {
+			switch kind {
+			case metricsdk.InstrumentKindCounter, metricsdk.InstrumentKindObservableCounter, metricsdk.InstrumentKindHistogram:
+				return metricdata.DeltaTemporality
+			default:
+				return metricdata.CumulativeTemporality
+			}
+		}
+	case temporalityLowMemory:
+		return func(kind metricsdk.InstrumentKind) metricdata.Temporality {
+			switch kind {
+			case metricsdk.InstrumentKindCounter, metricsdk.InstrumentKindHistogram:
+				return metricdata.DeltaTemporality
+			default:
+				return metricdata.CumulativeTemporality
+			}
+		}
+	default:
+		return metricsdk.DefaultTemporalitySelector
+	}
+}
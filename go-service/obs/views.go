@@ -0,0 +1,108 @@
+package obs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	metricsdk "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// MetricViewSpec is one operator-supplied view, loaded from OTEL_METRIC_VIEWS (inline JSON)
+// or OTEL_METRIC_VIEWS_FILE. Instrument may contain * and ? wildcards, except when renaming.
+//
+//	[{"instrument": "http.server.*", "attributes": ["http.method", "http.route"]},
+//	 {"instrument": "db.sql.latency", "rename": "sql_latency", "buckets": [1, 5, 25, 100]},
+//	 {"instrument": "rpc.server.requests_per_rpc", "drop": true}]
+type MetricViewSpec struct {
+	Instrument string    `json:"instrument"`
+	Meter      string    `json:"meter,omitempty"`
+	Rename     string    `json:"rename,omitempty"`
+	Drop       bool      `json:"drop,omitempty"`
+	Buckets    []float64 `json:"buckets,omitempty"`
+	Attributes []string  `json:"attributes,omitempty"`
+}
+
+// loadMetricViewSpecs reads view specs from the environment; no config means no views
+func loadMetricViewSpecs() ([]MetricViewSpec, error) {
+	raw := os.Getenv("OTEL_METRIC_VIEWS")
+	if path := os.Getenv("OTEL_METRIC_VIEWS_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading metric views file: %w", err)
+		}
+		raw = string(data)
+	}
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var specs []MetricViewSpec
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, fmt.Errorf("parsing metric views: %w", err)
+	}
+	return specs, nil
+}
+
+// newView converts a spec into an SDK view, rejecting combinations the SDK would silently drop
+func (s MetricViewSpec) newView() (metricsdk.View, error) {
+	if s.Instrument == "" {
+		return nil, errors.New("metric view requires an instrument name")
+	}
+	if s.Rename != "" && strings.ContainsAny(s.Instrument, "*?") {
+		return nil, fmt.Errorf("metric view %q: cannot rename a wildcard match", s.Instrument)
+	}
+	if s.Drop && (s.Rename != "" || len(s.Buckets) > 0 || len(s.Attributes) > 0) {
+		return nil, fmt.Errorf("metric view %q: drop cannot be combined with other options", s.Instrument)
+	}
+	if !sort.Float64sAreSorted(s.Buckets) {
+		return nil, fmt.Errorf("metric view %q: buckets must be in increasing order", s.Instrument)
+	}
+
+	criteria := metricsdk.Instrument{Name: s.Instrument}
+	if s.Meter != "" {
+		criteria.Scope = instrumentation.Scope{Name: s.Meter}
+	}
+
+	mask := metricsdk.Stream{Name: s.Rename}
+	switch {
+	case s.Drop:
+		mask.Aggregation = metricsdk.AggregationDrop{}
+	case len(s.Buckets) > 0:
+		mask.Aggregation = metricsdk.AggregationExplicitBucketHistogram{Boundaries: s.Buckets}
+	}
+	if len(s.Attributes) > 0 {
+		mask.AttributeFilter = attribute.NewAllowKeysFilter(toAttributeKeys(s.Attributes)...)
+	}
+	return metricsdk.NewView(criteria, mask), nil
+}
+
+func toAttributeKeys(names []string) []attribute.Key {
+	keys := make([]attribute.Key, len(names))
+	for i, n := range names {
+		keys[i] = attribute.Key(n)
+	}
+	return keys
+}
+
+// metricViews builds the views to register on the MeterProvider; invalid specs are
+// skipped with a warning so one typo doesn't disable the rest
+func metricViews(specs []MetricViewSpec) []metricsdk.View {
+	views := make([]metricsdk.View, 0, len(specs))
+	for _, spec := range specs {
+		view, err := spec.newView()
+		if err != nil {
+			Warn("Ignoring invalid metric view", map[string]interface{}{
+				"error": err.Error(),
+			})
+			continue
+		}
+		views = append(views, view)
+	}
+	return views
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// apiParam is a query parameter of a documented route
+type apiParam struct {
+	Name, Description string
+}
+
+// apiRoute documents one method of a route for /openapi.json; Status is the success
+// status, 200 unless set
+type apiRoute struct {
+	Method, Path, Tag, Summary string
+	Query                      []apiParam
+	Body                       bool
+	Status                     string
+}
+
+// apiRoutes describes every route of the public and internal routers. The spec is built
+// by walking the router, so it only lists what is mounted on that listener, and
+// TestOpenAPIDocumentsEveryRoute fails when a route is added without an entry here.
+var apiRoutes = []apiRoute{
+	{Method: "GET", Path: "/", Tag: "health", Summary: "Service banner"},
+	{Method: "GET", Path: "/health", Tag: "health", Summary: "Liveness"},
+	{Method: "GET", Path: "/readyz", Tag: "health", Summary: "Readiness; 503 while draining"},
+	{Method: "GET", Path: "/health/dependencies", Tag: "health", Summary: "Probe downstream services and backends"},
+	{Method: "GET", Path: "/openapi.json", Tag: "docs", Summary: "This OpenAPI document"},
+	{Method: "GET", Path: "/docs", Tag: "docs", Summary: "Swagger UI for this API"},
+	{Method: "GET", Path: "/slow", Tag: "demo", Summary: "Injected latency", Query: []apiParam{
+		{"ms", "Fixed delay in milliseconds"},
+		{"p50", "Median of a log-normal delay, with p99"},
+		{"p99", "99th percentile of a log-normal delay, with p50"},
+	}},
+	{Method: "GET", Path: "/users", Tag: "users", Summary: "List users"},
+	{Method: "POST", Path: "/users", Tag: "users", Summary: "Create a user", Body: true, Status: "201"},
+	{Method: "GET", Path: "/users/{id}", Tag: "users", Summary: "Get a user"},
+	{Method: "PUT", Path: "/users/{id}", Tag: "users", Summary: "Update a user", Body: true},
+	{Method: "DELETE", Path: "/users/{id}", Tag: "users", Summary: "Delete a user", Status: "204"},
+	{Method: "GET", Path: "/cache/{key}", Tag: "cache", Summary: "Read a cached value"},
+	{Method: "PUT", Path: "/cache/{key}", Tag: "cache", Summary: "Write a cached value", Body: true},
+	{Method: "POST", Path: "/cache/{key}", Tag: "cache", Summary: "Write a cached value", Body: true},
+	{Method: "DELETE", Path: "/cache/{key}", Tag: "cache", Summary: "Delete a cached value", Status: "204"},
+	{Method: "POST", Path: "/events", Tag: "demo", Summary: "Publish an event to Kafka", Body: true, Status: "202"},
+	{Method: "GET", Path: "/call-node", Tag: "downstream", Summary: "Call the TypeScript service", Query: []apiParam{{"path", "Downstream path, e.g. /health"}}},
+	{Method: "GET", Path: "/call-elixir", Tag: "downstream", Summary: "Call the Elixir service", Query: []apiParam{{"path", "Downstream path, e.g. /health"}}},
+	{Method: "GET", Path: "/chain", Tag: "downstream", Summary: "Call the TypeScript then the Elixir service"},
+	{Method: "GET", Path: "/scenario/{name}", Tag: "downstream", Summary: "Run a scripted multi-service scenario: checkout, retry-storm or cache-miss-cascade", Query: []apiParam{{"seed", "Picks which scripted failures fire (default 1)"}}},
+	{Method: "GET", Path: "/baggage", Tag: "downstream", Summary: "Set baggage and forward it downstream", Query: []apiParam{
+		{"tier", "user.tier baggage member"},
+		{"flag", "demo.flag baggage member"},
+	}},
+	{Method: "GET", Path: "/fanout", Tag: "demo", Summary: "Parallel worker spans linked to the request", Query: []apiParam{{"n", "Number of workers (1-50)"}}},
+	{Method: "GET", Path: "/compute", Tag: "demo", Summary: "Manual instrumentation example with one span per phase", Query: []apiParam{
+		{"items", "Workload size (1-10000)"},
+		{"fail", "Phase to fail: validate, fetch, transform or render"},
+	}},
+	{Method: "GET", Path: "/graphql", Tag: "demo", Summary: "GraphQL query over GET", Query: []apiParam{
+		{"query", "GraphQL document"},
+		{"operationName", "Operation to run"},
+		{"variables", "JSON-encoded variables"},
+	}},
+	{Method: "POST", Path: "/graphql", Tag: "demo", Summary: "GraphQL query with one span per resolver", Body: true},
+	{Method: "POST", Path: "/orders", Tag: "demo", Summary: "Create a demo order", Body: true, Status: "201"},
+	{Method: "GET", Path: "/ws/echo", Tag: "streaming", Summary: "WebSocket echo", Status: "101"},
+	{Method: "GET", Path: "/stream", Tag: "streaming", Summary: "Server-Sent Events", Query: []apiParam{
+		{"events", "Number of events"},
+		{"interval", "Delay between events, e.g. 100ms"},
+	}},
+	{Method: "GET", Path: "/metrics", Tag: "operations", Summary: "Prometheus metrics"},
+	{Method: "GET", Path: "/slo", Tag: "operations", Summary: "SLIs and burn rates"},
+	{Method: "GET", Path: "/debug/config", Tag: "operations", Summary: "Effective configuration (admin only)"},
+	{Method: "GET", Path: "/debug/topk", Tag: "operations", Summary: "Busiest clients, routes and statuses over the sliding window (admin only)", Query: []apiParam{{"k", "Entries per list, up to TOPK_SIZE"}}},
+	{Method: "POST", Path: "/admin/reload", Tag: "operations", Summary: "Reload configuration (admin only)", Body: true},
+	{Method: "GET", Path: "/admin/chaos", Tag: "operations", Summary: "Show chaos mode (admin only)"},
+	{Method: "POST", Path: "/admin/chaos", Tag: "operations", Summary: "Start chaos mode (admin only)", Body: true},
+	{Method: "DELETE", Path: "/admin/chaos", Tag: "operations", Summary: "Stop chaos mode (admin only)"},
+	{Method: "POST", Path: "/stress/cpu", Tag: "operations", Summary: "Burn CPU (admin only)", Query: []apiParam{{"seconds", "Duration"}, {"goroutines", "Parallelism"}}},
+	{Method: "POST", Path: "/stress/mem", Tag: "operations", Summary: "Allocate and hold memory (admin only)", Query: []apiParam{{"mb", "Megabytes"}, {"hold", "Hold time, e.g. 10s"}}},
+}
+
+type OpenAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       OpenAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                      `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIOperation struct {
+	Tags        []string                   `json:"tags"`
+	Summary     string                     `json:"summary"`
+	OperationID string                     `json:"operationId"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name        string            `json:"name"`
+	In          string            `json:"in"`
+	Description string            `json:"description,omitempty"`
+	Required    bool              `json:"required"`
+	Schema      map[string]string `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Content map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema map[string]interface{} `json:"schema"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]interface{} `json:"schemas"`
+}
+
+// problemDetailsSchema describes the middleware.ProblemDetails body of every error response
+var problemDetailsSchema = map[string]interface{}{
+	"type":     "object",
+	"required": []string{"type", "title", "status", "code", "retryable"},
+	"properties": map[string]interface{}{
+		"type":       map[string]string{"type": "string"},
+		"title":      map[string]string{"type": "string"},
+		"status":     map[string]string{"type": "integer"},
+		"detail":     map[string]string{"type": "string"},
+		"instance":   map[string]string{"type": "string"},
+		"code":       map[string]string{"type": "string"},
+		"retryable":  map[string]string{"type": "boolean"},
+		"details":    map[string]string{"type": "object"},
+		"request_id": map[string]string{"type": "string"},
+		"trace_id":   map[string]string{"type": "string"},
+	},
+}
+
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(?::[^}]*)?\}`)
+
+// buildOpenAPI documents the routes mounted on r, with apiRoutes supplying the details
+func buildOpenAPI(r *mux.Router) (OpenAPIDocument, error) {
+	docs := make(map[string]apiRoute, len(apiRoutes))
+	for _, route := range apiRoutes {
+		docs[route.Method+" "+route.Path] = route
+	}
+	doc := OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    OpenAPIInfo{Title: serviceName, Version: getEnv("SERVICE_VERSION", "dev")},
+		Paths:   map[string]map[string]OpenAPIOperation{},
+		Components: OpenAPIComponents{Schemas: map[string]interface{}{
+			"ProblemDetails": problemDetailsSchema,
+		}},
+	}
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // catch-alls such as /debug/pprof/ are not part of the API
+		}
+		for _, method := range methods {
+			info, ok := docs[method+" "+path]
+			if !ok {
+				info = apiRoute{Method: method, Path: path, Tag: "undocumented"}
+			}
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = map[string]OpenAPIOperation{}
+			}
+			doc.Paths[path][strings.ToLower(method)] = newOpenAPIOperation(info)
+		}
+		return nil
+	})
+	return doc, err
+}
+
+func newOpenAPIOperation(route apiRoute) OpenAPIOperation {
+	status := route.Status
+	if status == "" {
+		status = "200"
+	}
+	code, _ := strconv.Atoi(status)
+	op := OpenAPIOperation{
+		Tags:        []string{route.Tag},
+		Summary:     route.Summary,
+		OperationID: operationID(route.Method, route.Path),
+		Responses: map[string]OpenAPIResponse{
+			status: {Description: http.StatusText(code)},
+			"default": {Description: "Problem details", Content: map[string]OpenAPIMediaType{
+				"application/problem+json": {Schema: map[string]interface{}{"$ref": "#/components/schemas/ProblemDetails"}},
+			}},
+		},
+	}
+	for _, m := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{Name: m[1], In: "path", Required: true, Schema: map[string]string{"type": "string"}})
+	}
+	for _, q := range route.Query {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{Name: q.Name, In: "query", Description: q.Description, Schema: map[string]string{"type": "string"}})
+	}
+	if route.Body {
+		op.RequestBody = &OpenAPIRequestBody{Content: map[string]OpenAPIMediaType{
+			"application/json": {Schema: map[string]interface{}{"type": "object"}},
+		}}
+	}
+	return op
+}
+
+// operationID turns "GET /users/{id}" into "getUsersId"
+func operationID(method, path string) string {
+	words := strings.FieldsFunc(path, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	id := strings.ToLower(method)
+	for _, w := range words {
+		id += strings.ToUpper(w[:1]) + w[1:]
+	}
+	return id
+}
+
+// openAPIHandler serves the document for r, built per request so it always matches the router
+func openAPIHandler(r *mux.Router) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		doc, err := buildOpenAPI(r)
+		if err != nil {
+			writeError(w, req, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, doc)
+	}
+}
+
+// swaggerUIPage loads Swagger UI from a CDN and points it at the relative openapi.json,
+// so it works behind a path prefix
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>go-service API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+func swaggerUIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(swaggerUIPage))
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// orderQueueCapacity bounds the simulated fulfilment backlog; /orders returns 503 when it is full
+const orderQueueCapacity = 100
+
+// orderPaymentMethods keeps the payment.method attribute low-cardinality
+var orderPaymentMethods = map[string]bool{"card": true, "paypal": true, "invoice": true}
+
+type OrderInput struct {
+	Item          string  `json:"item"`
+	Quantity      int     `json:"quantity"`
+	UnitPrice     float64 `json:"unit_price"`
+	PaymentMethod string  `json:"payment_method"`
+}
+
+type OrderCreated struct {
+	ID         int64   `json:"id"`
+	Value      float64 `json:"value"`
+	QueueDepth int     `json:"queue_depth"`
+	TraceID    string  `json:"trace_id"`
+}
+
+// orderService records business metrics through the OTel Meter API, so they are exported
+// over OTLP next to the HTTP-centric Prometheus metrics
+type orderService struct {
+	queue  chan OrderCreated
+	nextID atomic.Int64
+
+	mu     sync.RWMutex // held for reading while enqueueing, so close never races a send
+	closed bool
+
+	created metric.Int64Counter
+	value   metric.Float64Histogram
+}
+
+var (
+	// orders is the service shared by every router, so its worker and queue_depth gauge
+	// exist once per process
+	orders     *orderService
+	ordersOnce sync.Once
+)
+
+// initOrderService creates the process-wide order service the first time; rebuilding the
+// router reuses it rather than starting another worker and registering another callback
+func initOrderService() *orderService {
+	ordersOnce.Do(func() {
+		orders = newOrderService()
+	})
+	return orders
+}
+
+// newOrderService creates the instruments on the current global meter provider and starts
+// the fulfilment worker that drains the queue
+func newOrderService() *orderService {
+	meter := otel.Meter(serviceName)
+	s := &orderService{queue: make(chan OrderCreated, orderQueueCapacity)}
+
+	var err error
+	if s.created, err = meter.Int64Counter("orders_created",
+		metric.WithDescription("Orders accepted by /orders"),
+		metric.WithUnit("{order}"),
+	); err != nil {
+		logWarn("Failed to create orders_created counter", map[string]interface{}{"error": err.Error()})
+	}
+	if s.value, err = meter.Float64Histogram("order_value",
+		metric.WithDescription("Total value of accepted orders"),
+		metric.WithUnit("{USD}"),
+		metric.WithExplicitBucketBoundaries(5, 10, 25, 50, 100, 250, 500, 1000, 2500),
+	); err != nil {
+		logWarn("Failed to create order_value histogram", map[string]interface{}{"error": err.Error()})
+	}
+	if _, err = meter.Int64ObservableGauge("queue_depth",
+		metric.WithDescription("Orders waiting for fulfilment"),
+		metric.WithUnit("{order}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(int64(len(s.queue)), metric.WithAttributes(attribute.String("queue", "fulfilment")))
+			return nil
+		}),
+	); err != nil {
+		logWarn("Failed to create queue_depth gauge", map[string]interface{}{"error": err.Error()})
+	}
+
+	go s.fulfil()
+	return s
+}
+
+// close stops accepting orders; the worker exits once it has drained the queue
+func (s *orderService) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+}
+
+// fulfil simulates slow downstream processing so queue_depth moves under load
+func (s *orderService) fulfil() {
+	for range s.queue {
+		time.Sleep(time.Duration(sampleLatency(50, 500) * float64(time.Millisecond)))
+	}
+}
+
+// enqueue hands an order to the worker without blocking
+func (s *orderService) enqueue(ctx context.Context, order OrderCreated) *AppError {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return unavailable("order_queue_closed", "order queue is closed")
+	}
+	select {
+	case s.queue <- order:
+		return nil
+	default:
+		logWarnContext(ctx, "Order queue full", map[string]interface{}{
+			"queue_capacity": orderQueueCapacity,
+		})
+		return unavailable("order_queue_full", "order queue is full")
+	}
+}
+
+func (s *orderService) createHandler(w http.ResponseWriter, r *http.Request) {
+	in := OrderInput{Item: "widget", Quantity: 1 + rand.Intn(5), UnitPrice: 5 + rand.Float64()*95, PaymentMethod: "card"}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			writeError(w, r, badRequest("invalid JSON body"))
+			return
+		}
+	}
+	if in.Quantity < 1 || in.UnitPrice <= 0 {
+		writeError(w, r, badRequest("quantity and unit_price must be positive"))
+		return
+	}
+	if !orderPaymentMethods[in.PaymentMethod] {
+		writeError(w, r, badRequest("payment_method must be card, paypal or invoice"))
+		return
+	}
+
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+	order := OrderCreated{
+		ID:      s.nextID.Add(1),
+		Value:   float64(in.Quantity) * in.UnitPrice,
+		TraceID: span.SpanContext().TraceID().String(),
+	}
+
+	if appErr := s.enqueue(ctx, order); appErr != nil {
+		writeError(w, r, appErr)
+		return
+	}
+	order.QueueDepth = len(s.queue)
+
+	attrs := metric.WithAttributes(attribute.String("payment.method", in.PaymentMethod))
+	s.created.Add(ctx, 1, attrs)
+	s.value.Record(ctx, order.Value, attrs)
+	span.SetAttributes(
+		attribute.Int64("order.id", order.ID),
+		attribute.Float64("order.value", order.Value),
+		attribute.String("payment.method", in.PaymentMethod),
+	)
+	logInfoContext(ctx, "Order created", map[string]interface{}{
+		"order_id":       order.ID,
+		"item":           in.Item,
+		"quantity":       in.Quantity,
+		"value":          order.Value,
+		"payment_method": in.PaymentMethod,
+	})
+	writeJSON(w, http.StatusCreated, order)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/httpclient"
+)
+
+// maxOutboundBody bounds how much of a downstream response is echoed back
+const maxOutboundBody = 4 << 10
+
+// outboundTarget is a downstream service reachable through its own client and circuit breaker
+type outboundTarget struct {
+	Name    string
+	BaseURL string
+	client  *http.Client
+	breaker *circuitBreaker
+}
+
+var (
+	nodeTarget   = newOutboundTarget("typescript-service", getEnv("NODE_SERVICE_URL", "http://typescript-service:3000"))
+	elixirTarget = newOutboundTarget("elixir-service", getEnv("ELIXIR_SERVICE_URL", "http://elixir-service:4000"))
+)
+
+func newOutboundTarget(name, baseURL string) *outboundTarget {
+	return &outboundTarget{
+		Name:    name,
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		client: httpclient.New(name, httpclient.Options{
+			Timeout:    getEnvDuration("OUTBOUND_TIMEOUT", 5*time.Second),
+			PhaseTrace: getEnv("OUTBOUND_PHASE_TRACE", httpclient.PhaseSpans),
+		}),
+		breaker: newCircuitBreaker(name, loadBreakerConfig()),
+	}
+}
+
+type CallResult struct {
+	Target     string  `json:"target"`
+	URL        string  `json:"url"`
+	Status     int     `json:"status,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+	Body       string  `json:"body,omitempty"`
+	Error      string  `json:"error,omitempty"`
+
+	err error
+}
+
+type ChainResponse struct {
+	TraceID string       `json:"trace_id"`
+	Calls   []CallResult `json:"calls"`
+}
+
+// call issues GET BaseURL+path through the breaker; 5xx responses count as failures
+func (t *outboundTarget) call(ctx context.Context, path string) CallResult {
+	url := t.BaseURL + path
+	result := CallResult{Target: t.Name, URL: url}
+	start := time.Now()
+
+	err := t.breaker.Do(ctx, func() error {
+		if chaosDropCall(ctx, t.Name) {
+			return errChaosDropped
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := t.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutboundBody))
+		io.Copy(io.Discard, resp.Body)
+
+		result.Status = resp.StatusCode
+		result.Body = string(body)
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("%s returned %d", t.Name, resp.StatusCode)
+		}
+		return nil
+	})
+	result.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+
+	if err != nil {
+		result.err = err
+		result.Error = err.Error()
+		logWarnContext(ctx, "Outbound call failed", map[string]interface{}{
+			"target": t.Name,
+			"url":    url,
+			"status": result.Status,
+			"error":  err.Error(),
+		})
+	}
+	return result
+}
+
+// outboundPath reads ?path=, defaulting to the target's root
+func outboundPath(r *http.Request) string {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		return "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+func callStatus(result CallResult) int {
+	switch {
+	case result.err == nil:
+		return http.StatusOK
+	case errors.Is(result.err, errCircuitOpen):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+func callTargetHandler(t *outboundTarget) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result := t.call(r.Context(), outboundPath(r))
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("peer.service", t.Name))
+		writeJSON(w, callStatus(result), result)
+	}
+}
+
+// chainHandler calls each downstream service in turn so one trace spans all three services
+func chainHandler(w http.ResponseWriter, r *http.Request) {
+	path := outboundPath(r)
+	resp := ChainResponse{TraceID: trace.SpanContextFromContext(r.Context()).TraceID().String()}
+	status := http.StatusOK
+	for _, t := range []*outboundTarget{nodeTarget, elixirTarget} {
+		result := t.call(r.Context(), path)
+		resp.Calls = append(resp.Calls, result)
+		if s := callStatus(result); s != http.StatusOK {
+			status = http.StatusBadGateway
+		}
+	}
+	writeJSON(w, status, resp)
+}
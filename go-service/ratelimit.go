@@ -0,0 +1,300 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+
+	"go-service/middleware"
+	"go-service/obs"
+)
+
+// rateLimitClientIdle is how long a client IP bucket is kept after its last request
+const rateLimitClientIdle = 3 * time.Minute
+
+var rateLimitedRequests = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rate_limited_requests_total",
+		Help: "Total number of requests rejected with 429 by the rate limiter",
+	},
+	[]string{"scope", "endpoint"},
+)
+
+func init() {
+	obs.Registry.MustRegister(rateLimitedRequests)
+}
+
+// rateLimitConfig configures the global and per-client-IP token buckets; a zero RPS disables that scope
+type rateLimitConfig struct {
+	GlobalRPS   float64  `json:"global_rps"`
+	GlobalBurst int      `json:"global_burst"`
+	ClientRPS   float64  `json:"per_ip_rps"`
+	ClientBurst int      `json:"per_ip_burst"`
+	Exempt      []string `json:"exempt"`
+}
+
+func loadRateLimitConfig() rateLimitConfig {
+	return rateLimitConfig{
+		GlobalRPS:   getEnvFloat("RATE_LIMIT_RPS", 0),
+		GlobalBurst: getEnvInt("RATE_LIMIT_BURST", 100),
+		ClientRPS:   getEnvFloat("RATE_LIMIT_PER_IP_RPS", 0),
+		ClientBurst: getEnvInt("RATE_LIMIT_PER_IP_BURST", 20),
+		Exempt:      splitList(getEnv("RATE_LIMIT_EXEMPT", "/health,/readyz,/metrics")),
+	}
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimiter holds the shared bucket plus one bucket per client IP
+type rateLimiter struct {
+	mu      sync.Mutex
+	cfg     rateLimitConfig
+	exempt  map[string]bool
+	global  *rate.Limiter
+	clients map[string]*clientLimiter
+
+	globalGauges sync.Once
+	clientGauges sync.Once
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+var (
+	// rateLimits is the limiter shared by every router, so reloads can adjust its limits
+	rateLimits     *rateLimiter
+	rateLimitsOnce sync.Once
+)
+
+// initRateLimiter creates the process-wide limiter the first time, with both scopes
+// disabled until update is called; rebuilding the router reuses it rather than starting
+// another eviction goroutine and registering its gauges again
+func initRateLimiter() *rateLimiter {
+	rateLimitsOnce.Do(func() {
+		rateLimits = newRateLimiter(rateLimitConfig{})
+	})
+	return rateLimits
+}
+
+func newRateLimiter(cfg rateLimitConfig) *rateLimiter {
+	rl := &rateLimiter{clients: make(map[string]*clientLimiter), stop: make(chan struct{})}
+	rl.update(cfg)
+	go rl.evictIdle()
+	return rl
+}
+
+// close stops evicting idle client buckets; the limiter keeps enforcing its limits
+func (rl *rateLimiter) close() {
+	rl.stopOnce.Do(func() { close(rl.stop) })
+}
+
+// update applies new rates, bursts and exempt routes; existing client buckets are adjusted
+// in place and disabling a scope drops its buckets
+func (rl *rateLimiter) update(cfg rateLimitConfig) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.cfg = cfg
+	rl.exempt = make(map[string]bool, len(cfg.Exempt))
+	for _, route := range cfg.Exempt {
+		rl.exempt[route] = true
+	}
+
+	if cfg.GlobalRPS > 0 {
+		if rl.global == nil {
+			rl.global = rate.NewLimiter(rate.Limit(cfg.GlobalRPS), cfg.GlobalBurst)
+		} else {
+			rl.global.SetLimit(rate.Limit(cfg.GlobalRPS))
+			rl.global.SetBurst(cfg.GlobalBurst)
+		}
+		rl.globalGauges.Do(rl.registerGlobalGauges)
+	} else {
+		rl.global = nil
+	}
+
+	if cfg.ClientRPS > 0 {
+		for _, c := range rl.clients {
+			c.limiter.SetLimit(rate.Limit(cfg.ClientRPS))
+			c.limiter.SetBurst(cfg.ClientBurst)
+		}
+		rl.clientGauges.Do(rl.registerClientGauges)
+	} else {
+		rl.clients = make(map[string]*clientLimiter)
+	}
+}
+
+// exempted reports whether the route template is never rate limited
+func (rl *rateLimiter) exempted(route string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.exempt[route]
+}
+
+func (rl *rateLimiter) registerGlobalGauges() {
+	obs.RegisterOrExisting(obs.Registry, prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "rate_limiter_tokens",
+			Help:        "Tokens currently available in the rate limiter bucket",
+			ConstLabels: prometheus.Labels{"scope": "global"},
+		},
+		func() float64 {
+			rl.mu.Lock()
+			defer rl.mu.Unlock()
+			if rl.global == nil {
+				return 0
+			}
+			return rl.global.Tokens()
+		},
+	))
+}
+
+func (rl *rateLimiter) registerClientGauges() {
+	obs.RegisterOrExisting(obs.Registry, prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "rate_limiter_tracked_clients",
+			Help: "Number of client IPs with an active rate limiter bucket",
+		},
+		func() float64 {
+			rl.mu.Lock()
+			defer rl.mu.Unlock()
+			return float64(len(rl.clients))
+		},
+	))
+	obs.RegisterOrExisting(obs.Registry, prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "rate_limiter_tokens",
+			Help:        "Tokens currently available in the rate limiter bucket",
+			ConstLabels: prometheus.Labels{"scope": "client_min"},
+		},
+		rl.minClientTokens,
+	))
+}
+
+func (rl *rateLimiter) clientBucket(ip string) *rate.Limiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	c, ok := rl.clients[ip]
+	if !ok {
+		c = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(rl.cfg.ClientRPS), rl.cfg.ClientBurst)}
+		rl.clients[ip] = c
+	}
+	c.lastSeen = time.Now()
+	return c.limiter
+}
+
+// minClientTokens reports the emptiest client bucket, i.e. the client closest to being throttled
+func (rl *rateLimiter) minClientTokens() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	min := float64(rl.cfg.ClientBurst)
+	for _, c := range rl.clients {
+		if t := c.limiter.Tokens(); t < min {
+			min = t
+		}
+	}
+	return min
+}
+
+func (rl *rateLimiter) evictIdle() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rl.stop:
+			return
+		case <-ticker.C:
+		}
+		rl.mu.Lock()
+		for ip, c := range rl.clients {
+			if time.Since(c.lastSeen) > rateLimitClientIdle {
+				delete(rl.clients, ip)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// allow takes a token from each enabled bucket, returning the scope that refused and how long to wait
+func (rl *rateLimiter) allow(ip string) (string, time.Duration) {
+	rl.mu.Lock()
+	global, clientEnabled := rl.global, rl.cfg.ClientRPS > 0
+	rl.mu.Unlock()
+
+	now := time.Now()
+	var globalRes *rate.Reservation
+	if global != nil {
+		globalRes = global.ReserveN(now, 1)
+		if delay := globalRes.DelayFrom(now); delay > 0 {
+			globalRes.CancelAt(now)
+			return "global", delay
+		}
+	}
+	if clientEnabled {
+		res := rl.clientBucket(ip).ReserveN(now, 1)
+		if delay := res.DelayFrom(now); delay > 0 {
+			res.CancelAt(now)
+			if globalRes != nil {
+				globalRes.CancelAt(now)
+			}
+			return "client", delay
+		}
+	}
+	return "", 0
+}
+
+// rateLimitMiddleware returns 429 with Retry-After when a bucket is empty.
+// It runs inside middleware.Logging so throttled requests are logged and counted.
+// Every router shares initRateLimiter's limiter, set to cfg here, so limits can be changed
+// later through it, even if both scopes start disabled.
+func rateLimitMiddleware(cfg rateLimitConfig) func(http.Handler) http.Handler {
+	rl := initRateLimiter()
+	rl.update(cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			endpoint := middleware.RouteTemplate(r)
+			if rl.exempted(endpoint) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ip := middleware.ClientAddress(r)
+			scope, delay := rl.allow(ip)
+			if scope == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			retryAfter := int(math.Ceil(delay.Seconds()))
+			rateLimitedRequests.WithLabelValues(scope, endpoint).Inc()
+			trace.SpanFromContext(r.Context()).AddEvent("rate_limited", trace.WithAttributes(
+				attribute.String("rate_limit.scope", scope),
+				attribute.Int("rate_limit.retry_after_seconds", retryAfter),
+			))
+			// Not logWarnContext: the rate_limited event above already marks the span
+			logWarn("Request rate limited", map[string]interface{}{
+				"scope":               scope,
+				"client_ip":           ip,
+				"method":              r.Method,
+				"path":                r.URL.Path,
+				"retry_after_seconds": retryAfter,
+			})
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeError(w, r, &AppError{
+				Status:    http.StatusTooManyRequests,
+				Code:      "rate_limited",
+				Message:   "rate limit exceeded",
+				Retryable: true,
+				Details:   map[string]interface{}{"scope": scope, "retry_after_seconds": retryAfter},
+			})
+		})
+	}
+}
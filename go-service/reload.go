@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"go-service/obs"
+)
+
+// reloadableKeys are the settings re-applied by a config reload; everything else needs a restart
+var reloadableKeys = []string{
+	"LOG_LEVEL",
+	"JOBS_FAILURE_PERCENT",
+	"OTEL_TRACES_SAMPLER",
+	"OTEL_TRACES_SAMPLER_ARG",
+	"OTEL_TRACES_SAMPLER_ROUTES",
+	"OTEL_TRACES_SAMPLER_ROUTES_FILE",
+	"RATE_LIMIT_RPS",
+	"RATE_LIMIT_BURST",
+	"RATE_LIMIT_PER_IP_RPS",
+	"RATE_LIMIT_PER_IP_BURST",
+	"RATE_LIMIT_EXEMPT",
+	"FEATURE_FLAGS",
+	"FEATURE_FLAGS_FILE",
+}
+
+// reloadFile is an optional KEY=VALUE file re-read on every reload
+var reloadFile = getEnv("RELOAD_CONFIG_FILE", "")
+
+var reloadMu sync.Mutex
+
+// configChange is one entry of the "Config reloaded" diff
+type configChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// ReloadResponse is returned by POST /admin/reload
+type ReloadResponse struct {
+	Changed map[string]configChange `json:"changed"`
+}
+
+func isReloadable(key string) bool {
+	for _, k := range reloadableKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func snapshotReloadable() map[string]string {
+	values := make(map[string]string, len(reloadableKeys))
+	for _, key := range reloadableKeys {
+		values[key] = os.Getenv(key)
+	}
+	return values
+}
+
+// readReloadFile parses RELOAD_CONFIG_FILE; blank lines and # comments are skipped
+func readReloadFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, line)
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return values, scanner.Err()
+}
+
+// applyReloadable pushes the current environment into the components that support live changes
+func applyReloadable() error {
+	if err := obs.SetLevel(getEnv("LOG_LEVEL", "INFO")); err != nil {
+		return err
+	}
+	jobFailurePercent.Store(int64(getEnvInt("JOBS_FAILURE_PERCENT", 10)))
+	obs.ReloadSampler()
+	initRateLimiter().update(loadRateLimitConfig())
+	return featureFlags.reload()
+}
+
+// reloadConfig re-reads RELOAD_CONFIG_FILE, applies overrides on top and re-applies the
+// reloadable settings. On failure the previous values are restored.
+func reloadConfig(source string, overrides map[string]string) (map[string]configChange, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	values := map[string]string{}
+	if reloadFile != "" {
+		fileValues, err := readReloadFile(reloadFile)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range fileValues {
+			if !isReloadable(key) {
+				logWarn("Ignoring non-reloadable key in config file", map[string]interface{}{
+					"key":  key,
+					"file": reloadFile,
+				})
+				continue
+			}
+			values[key] = value
+		}
+	}
+	for key, value := range overrides {
+		if !isReloadable(key) {
+			return nil, fmt.Errorf("%s cannot be reloaded; reloadable keys are %s", key, strings.Join(reloadableKeys, ", "))
+		}
+		values[key] = value
+	}
+
+	before := snapshotReloadable()
+	for key, value := range values {
+		os.Setenv(key, value)
+	}
+	if err := applyReloadable(); err != nil {
+		for key, value := range before {
+			os.Setenv(key, value)
+		}
+		applyReloadable()
+		return nil, err
+	}
+
+	changed := map[string]configChange{}
+	diff := map[string]interface{}{}
+	for key, value := range snapshotReloadable() {
+		if value != before[key] {
+			changed[key] = configChange{Old: before[key], New: value}
+			diff[key] = map[string]interface{}{"old": before[key], "new": value}
+		}
+	}
+	keys := make([]string, 0, len(changed))
+	for key := range changed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	logInfo("Config reloaded", map[string]interface{}{
+		"source":       source,
+		"changed_keys": keys,
+		"diff":         diff,
+	})
+	return changed, nil
+}
+
+// watchReload reloads the configuration on every SIGHUP until ctx is cancelled
+func watchReload(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-hup:
+				if _, err := reloadConfig("sighup", nil); err != nil {
+					logError("Config reload failed", map[string]interface{}{
+						"source": "sighup",
+						"error":  err.Error(),
+					})
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// reloadHandler reloads the configuration, optionally applying a JSON object of overrides first
+func reloadHandler(w http.ResponseWriter, r *http.Request) {
+	overrides := map[string]string{}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&overrides); err != nil {
+			writeError(w, r, badRequest("body must be a JSON object of string values"))
+			return
+		}
+	}
+
+	changed, err := reloadConfig("admin", overrides)
+	if err != nil {
+		logError("Config reload failed", map[string]interface{}{
+			"source": "admin",
+			"error":  err.Error(),
+		})
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+	writeJSON(w, http.StatusOK, ReloadResponse{Changed: changed})
+}
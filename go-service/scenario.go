@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+var (
+	scenarioRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "scenario_runs_total",
+			Help: "Total number of /scenario runs, by scenario and outcome",
+		},
+		[]string{"scenario", "status"},
+	)
+
+	scenarioStepDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "scenario_step_duration_seconds",
+			Help:    "Duration of each scenario step attempt in seconds, by scenario, step and status",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 12),
+		},
+		[]string{"scenario", "step", "status"},
+	)
+)
+
+func init() {
+	obs.Registry.MustRegister(scenarioRuns, scenarioStepDuration)
+}
+
+// scenarioStep is one scripted step: a GET of Path on Target, or Latency (plus up to Jitter)
+// of simulated work in this service when Target is nil. Copies runs the step concurrently,
+// as a burst of clients would, and each copy makes up to Attempts attempts with exponential
+// Backoff. FailRate fails an attempt on purpose once its work is done, with Error, so the
+// errors in a run depend only on its seed; a copy failing a step that is not Optional ends
+// the scenario.
+type scenarioStep struct {
+	Name     string
+	Target   *outboundTarget
+	Path     string
+	Latency  time.Duration
+	Jitter   time.Duration
+	FailRate float64
+	Error    string
+	Attempts int
+	Backoff  time.Duration
+	Copies   int
+	Optional bool
+}
+
+// scenario is a scripted sequence of steps run under one trace, with Baggage added for
+// every service it reaches
+type scenario struct {
+	Description string
+	Baggage     map[string]string
+	Steps       []scenarioStep
+}
+
+var scenarios = map[string]scenario{
+	"checkout": {
+		Description: "Load a cart, price it in the TypeScript service, reserve stock, authorize payment in the Elixir service and confirm the order",
+		Baggage:     map[string]string{"user.tier": "gold", "checkout.channel": "web"},
+		Steps: []scenarioStep{
+			{Name: "cart.load", Latency: 15 * time.Millisecond, Jitter: 10 * time.Millisecond},
+			{Name: "pricing.quote", Target: nodeTarget, Path: "/"},
+			{Name: "inventory.reserve", Latency: 25 * time.Millisecond, Jitter: 15 * time.Millisecond, FailRate: 0.2, Error: "inventory lock timeout", Attempts: 3, Backoff: 20 * time.Millisecond},
+			{Name: "payment.authorize", Target: elixirTarget, Path: "/", FailRate: 0.1, Error: "card declined"},
+			{Name: "order.confirm", Latency: 10 * time.Millisecond, Jitter: 5 * time.Millisecond},
+		},
+	},
+	"retry-storm": {
+		Description: "Six clients retry an overloaded TypeScript dependency with exponential backoff, multiplying its load, then resync through the Elixir service",
+		Baggage:     map[string]string{"retry.policy": "exponential"},
+		Steps: []scenarioStep{
+			{Name: "catalog.fetch", Target: nodeTarget, Path: "/", FailRate: 0.5, Error: "upstream overloaded", Attempts: 5, Backoff: 25 * time.Millisecond, Copies: 6},
+			{Name: "inventory.sync", Target: elixirTarget, Path: "/", FailRate: 0.3, Error: "upstream overloaded", Attempts: 3, Backoff: 25 * time.Millisecond, Copies: 3},
+		},
+	},
+	"cache-miss-cascade": {
+		Description: "Eight concurrent reads miss a cold cache, all fall through to the Elixir and TypeScript services and a slowing database, then refill the cache",
+		Baggage:     map[string]string{"cache.strategy": "read-through"},
+		Steps: []scenarioStep{
+			{Name: "cache.get", Latency: 2 * time.Millisecond, Jitter: time.Millisecond, FailRate: 1, Error: "cache miss", Copies: 8, Optional: true},
+			{Name: "profile.load", Target: elixirTarget, Path: "/", Copies: 8},
+			{Name: "db.query", Latency: 40 * time.Millisecond, Jitter: 80 * time.Millisecond, FailRate: 0.1, Error: "statement timeout", Attempts: 2, Backoff: 50 * time.Millisecond, Copies: 8},
+			{Name: "recommendations.fetch", Target: nodeTarget, Path: "/", Copies: 8},
+			{Name: "cache.set", Latency: 3 * time.Millisecond, Jitter: time.Millisecond, Copies: 8},
+		},
+	},
+}
+
+type ScenarioStepResult struct {
+	Step       string  `json:"step"`
+	Service    string  `json:"service"`
+	Copies     int     `json:"copies"`
+	Calls      int     `json:"calls"`
+	Failed     int     `json:"failed,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type ScenarioResponse struct {
+	Scenario    string               `json:"scenario"`
+	Description string               `json:"description"`
+	Status      string               `json:"status"`
+	TraceID     string               `json:"trace_id"`
+	Seed        int64                `json:"seed"`
+	Baggage     map[string]string    `json:"baggage"`
+	DurationMs  float64              `json:"duration_ms"`
+	Steps       []ScenarioStepResult `json:"steps"`
+}
+
+// scenarioRand is the random source of one attempt, derived from the run's seed so that
+// concurrent copies draw the same values whatever order they run in
+func scenarioRand(seed int64, step, copy, attempt int) *rand.Rand {
+	return rand.New(rand.NewSource(seed*1_000_003 + int64(step)<<20 + int64(copy)<<10 + int64(attempt)))
+}
+
+// scenarioHandler runs /scenario/{name} under a scenario span: each step copy gets a span
+// of its own, downstream calls carry the scenario baggage and every failed attempt is a
+// span event and a WARN log. ?seed=1 picks which scripted failures fire, so the same seed
+// tells the same story on every run.
+func scenarioHandler(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	sc, ok := scenarios[name]
+	if !ok {
+		names := make([]string, 0, len(scenarios))
+		for n := range scenarios {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		writeError(w, r, newAppError(http.StatusNotFound, "unknown_scenario", "scenario must be one of "+strings.Join(names, ", ")))
+		return
+	}
+	seed := int64(1)
+	if raw := r.URL.Query().Get("seed"); raw != "" {
+		v, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			writeError(w, r, badRequest("seed must be an integer"))
+			return
+		}
+		seed = v
+	}
+
+	ctx := r.Context()
+	bag := baggage.FromContext(ctx)
+	for key, value := range sc.Baggage {
+		if m, err := baggage.NewMemberRaw(key, value); err == nil {
+			bag, _ = bag.SetMember(m)
+		}
+	}
+	if m, err := baggage.NewMemberRaw("scenario.name", name); err == nil {
+		bag, _ = bag.SetMember(m)
+	}
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+
+	ctx, span := otel.Tracer(serviceName).Start(ctx, "scenario."+name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(
+			attribute.String("scenario.name", name),
+			attribute.Int64("scenario.seed", seed),
+		),
+	)
+	span.SetAttributes(obs.BaggageAttributes(ctx)...)
+	defer span.End()
+
+	resp := ScenarioResponse{
+		Scenario:    name,
+		Description: sc.Description,
+		Status:      "ok",
+		TraceID:     span.SpanContext().TraceID().String(),
+		Seed:        seed,
+		Baggage:     map[string]string{},
+	}
+	for _, m := range bag.Members() {
+		resp.Baggage[m.Key()] = m.Value()
+	}
+	start := time.Now()
+	for i, step := range sc.Steps {
+		result := runScenarioStep(ctx, name, seed, i, step)
+		resp.Steps = append(resp.Steps, result)
+		if result.Failed > 0 && !step.Optional {
+			resp.Status = "failed"
+			span.SetStatus(codes.Error, step.Name+": "+result.Error)
+			break
+		}
+	}
+	resp.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	scenarioRuns.WithLabelValues(name, resp.Status).Inc()
+	logInfoContext(ctx, "Scenario finished", map[string]interface{}{
+		"scenario":    name,
+		"status":      resp.Status,
+		"seed":        seed,
+		"steps":       len(resp.Steps),
+		"duration_ms": resp.DurationMs,
+	})
+
+	status := http.StatusOK
+	if resp.Status != "ok" {
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, status, resp)
+}
+
+// runScenarioStep runs every copy of step and waits for all of them
+func runScenarioStep(ctx context.Context, name string, seed int64, index int, step scenarioStep) ScenarioStepResult {
+	copies := step.Copies
+	if copies < 1 {
+		copies = 1
+	}
+	result := ScenarioStepResult{Step: step.Name, Service: serviceName, Copies: copies}
+	if step.Target != nil {
+		result.Service = step.Target.Name
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	start := time.Now()
+	for c := 0; c < copies; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			calls, err := runScenarioCopy(ctx, name, seed, index, c, step)
+			mu.Lock()
+			defer mu.Unlock()
+			result.Calls += calls
+			if err != nil {
+				result.Failed++
+				result.Error = err.Error()
+			}
+		}(c)
+	}
+	wg.Wait()
+	result.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	return result
+}
+
+// runScenarioCopy makes one copy's attempts at step in its own span, returning how many
+// attempts it made and the last error if none succeeded
+func runScenarioCopy(ctx context.Context, name string, seed int64, index, copy int, step scenarioStep) (int, error) {
+	attrs := []attribute.KeyValue{
+		attribute.String("scenario.name", name),
+		attribute.String("scenario.step", step.Name),
+	}
+	if step.Copies > 1 {
+		attrs = append(attrs, attribute.Int("scenario.copy", copy))
+	}
+	if step.Target != nil {
+		attrs = append(attrs, attribute.String("peer.service", step.Target.Name))
+	}
+	ctx, span := otel.Tracer(serviceName).Start(ctx, step.Name,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attrs...),
+	)
+	defer span.End()
+
+	attempts := step.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		rnd := scenarioRand(seed, index, copy, attempt)
+		begin := time.Now()
+		err = scenarioAttempt(ctx, step, rnd)
+		status := "success"
+		if err != nil {
+			status = "error"
+		}
+		scenarioStepDuration.WithLabelValues(name, step.Name, status).Observe(time.Since(begin).Seconds())
+		if err == nil || attempt == attempts || ctx.Err() != nil {
+			break
+		}
+
+		backoff := step.Backoff << (attempt - 1)
+		span.AddEvent("scenario.retry", trace.WithAttributes(
+			attribute.Int("scenario.attempt", attempt),
+			attribute.String("error", err.Error()),
+			attribute.Int64("scenario.backoff_ms", backoff.Milliseconds()),
+		))
+		logWarnContext(ctx, "Scenario step attempt failed, retrying", map[string]interface{}{
+			"scenario":   name,
+			"step":       step.Name,
+			"attempt":    attempt,
+			"backoff_ms": backoff.Milliseconds(),
+			"error":      err.Error(),
+		})
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+	}
+
+	span.SetAttributes(attribute.Int("scenario.attempts", attempt))
+	switch {
+	case err != nil && step.Optional:
+		// An expected outcome such as a cache miss, not an error of the trace
+		span.AddEvent("scenario.optional_failure", trace.WithAttributes(attribute.String("error", err.Error())))
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		logWarnContext(ctx, "Scenario step failed", map[string]interface{}{
+			"scenario": name,
+			"step":     step.Name,
+			"attempts": attempt,
+			"error":    err.Error(),
+		})
+	}
+	return attempt, err
+}
+
+// scenarioAttempt does the step's work once, then fails it at FailRate
+func scenarioAttempt(ctx context.Context, step scenarioStep, rnd *rand.Rand) error {
+	if step.Target != nil {
+		if result := step.Target.call(ctx, step.Path); result.err != nil {
+			return result.err
+		}
+	} else {
+		delay := step.Latency
+		if step.Jitter > 0 {
+			delay += time.Duration(rnd.Int63n(int64(step.Jitter)))
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if rnd.Float64() < step.FailRate {
+		return errors.New(step.Error)
+	}
+	return nil
+}
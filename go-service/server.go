@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+
+	"go-service/middleware"
+)
+
+// serverConfig controls the listener(s) of the HTTP server
+type serverConfig struct {
+	Addr         string
+	CertFile     string
+	KeyFile      string
+	RedirectAddr string // plain HTTP listener redirecting to HTTPS, empty to disable
+	HTTP2        bool   // negotiate h2 via ALPN when serving TLS
+	H2C          bool   // accept cleartext HTTP/2 (prior knowledge or Upgrade: h2c) without TLS
+}
+
+// loadRouteTimeouts reads ROUTE_TIMEOUT_DEFAULT and ROUTE_TIMEOUTS ("/slow=2s,/users/{id}=500ms");
+// route keys are mux path templates and "=0" exempts a route from the default
+func loadRouteTimeouts() middleware.TimeoutOptions {
+	opts := middleware.TimeoutOptions{
+		Default: getEnvDuration("ROUTE_TIMEOUT_DEFAULT", 0),
+		Routes:  map[string]time.Duration{},
+	}
+	for _, entry := range splitList(getEnv("ROUTE_TIMEOUTS", "")) {
+		route, raw, _ := strings.Cut(entry, "=")
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil || d < 0 {
+			logWarn("Ignoring invalid ROUTE_TIMEOUTS entry", map[string]interface{}{
+				"entry": entry,
+			})
+			continue
+		}
+		opts.Routes[strings.TrimSpace(route)] = d
+	}
+	return opts
+}
+
+// loadQuietOptions reads TELEMETRY_QUIET_ROUTES, the route templates whose telemetry is
+// suppressed, and TELEMETRY_QUIET_SIGNALS, any of traces, logs and metrics
+func loadQuietOptions() middleware.QuietOptions {
+	opts := middleware.QuietOptions{Routes: splitList(getEnv("TELEMETRY_QUIET_ROUTES", ""))}
+	for _, signal := range splitList(getEnv("TELEMETRY_QUIET_SIGNALS", "traces,logs")) {
+		switch signal {
+		case "traces":
+			opts.Signals.Traces = true
+		case "logs":
+			opts.Signals.Logs = true
+		case "metrics":
+			opts.Signals.Metrics = true
+		default:
+			logWarn("Ignoring unknown TELEMETRY_QUIET_SIGNALS entry", map[string]interface{}{
+				"entry": signal,
+			})
+		}
+	}
+	return opts
+}
+
+// loadAuthOptions reads AUTH_API_KEYS ("subject:key,...") and the AUTH_JWT_* settings;
+// routes in AUTH_PUBLIC_ROUTES never require credentials
+func loadAuthOptions() middleware.AuthOptions {
+	opts := middleware.AuthOptions{
+		APIKeys:     map[string]string{},
+		JWTSecret:   []byte(getEnv("AUTH_JWT_SECRET", "")),
+		JWTIssuer:   getEnv("AUTH_JWT_ISSUER", ""),
+		JWTAudience: getEnv("AUTH_JWT_AUDIENCE", ""),
+		Public:      splitList(getEnv("AUTH_PUBLIC_ROUTES", "/,/health,/readyz,/health/dependencies,/metrics")),
+	}
+	for _, entry := range splitList(getEnv("AUTH_API_KEYS", "")) {
+		subject, key, ok := strings.Cut(entry, ":")
+		if !ok || key == "" {
+			logWarn("Ignoring invalid AUTH_API_KEYS entry; expected subject:key", nil)
+			continue
+		}
+		opts.APIKeys[key] = subject
+	}
+	return opts
+}
+
+// loadClientIPOptions reads TRUSTED_PROXIES, a list of proxy IPs or CIDRs such as the
+// Docker network ("172.16.0.0/12") whose forwarding headers are believed
+func loadClientIPOptions() middleware.ClientIPOptions {
+	var opts middleware.ClientIPOptions
+	for _, entry := range splitList(getEnv("TRUSTED_PROXIES", "")) {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(entry)
+			if addrErr != nil {
+				logWarn("Ignoring invalid TRUSTED_PROXIES entry", map[string]interface{}{
+					"entry": entry,
+				})
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		opts.TrustedProxies = append(opts.TrustedProxies, prefix.Masked())
+	}
+	return opts
+}
+
+// loadIdentityOptions reads IDENTITY_HEADERS, a list of header=key pairs, and the
+// IDENTITY_HASH / IDENTITY_HASH_KEY privacy settings
+func loadIdentityOptions() middleware.IdentityOptions {
+	opts := middleware.IdentityOptions{
+		Hash:    getEnvBool("IDENTITY_HASH", false),
+		HashKey: getEnv("IDENTITY_HASH_KEY", ""),
+	}
+	for _, entry := range splitList(getEnv("IDENTITY_HEADERS", "X-User-Id=enduser.id,X-Session-Id=session.id")) {
+		header, key, ok := strings.Cut(entry, "=")
+		header, key = strings.TrimSpace(header), strings.TrimSpace(key)
+		if !ok || header == "" || key == "" {
+			logWarn("Ignoring invalid IDENTITY_HEADERS entry; expected header=key", map[string]interface{}{
+				"entry": entry,
+			})
+			continue
+		}
+		opts.Headers = append(opts.Headers, middleware.IdentityHeader{Header: header, Key: key})
+	}
+	return opts
+}
+
+func loadServerConfig() serverConfig {
+	return serverConfig{
+		Addr:         ":" + getEnv("PORT", "8080"),
+		CertFile:     getEnv("TLS_CERT_FILE", ""),
+		KeyFile:      getEnv("TLS_KEY_FILE", ""),
+		RedirectAddr: getEnv("HTTPS_REDIRECT_ADDR", ""),
+		HTTP2:        getEnvBool("HTTP2_ENABLED", true),
+		H2C:          getEnvBool("H2C_ENABLED", false),
+	}
+}
+
+// TLSEnabled reports whether the server should terminate TLS itself
+func (c serverConfig) TLSEnabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// serverTLSConfig returns conservative TLS defaults: TLS 1.2+, AEAD-only suites, modern curves
+func serverTLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
+
+func newServer(cfg serverConfig, handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+	h2 := &http2.Server{IdleTimeout: srv.IdleTimeout}
+	switch {
+	case cfg.TLSEnabled() && cfg.HTTP2:
+		srv.TLSConfig = serverTLSConfig()
+		if err := http2.ConfigureServer(srv, h2); err != nil {
+			logWarn("Failed to enable HTTP/2, serving HTTP/1.1 only", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	case cfg.TLSEnabled():
+		srv.TLSConfig = serverTLSConfig()
+		// A non-nil empty map stops net/http from enabling h2 on its own
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	case cfg.H2C:
+		// h2c connections are hijacked from net/http, so Shutdown does not wait for them
+		srv.Handler = h2c.NewHandler(handler, h2)
+	}
+	return srv
+}
+
+// httpsRedirectHandler sends plain HTTP clients to the same URL on the HTTPS listener
+func httpsRedirectHandler(httpsAddr string) http.Handler {
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// newRedirectServer returns the plain HTTP listener that redirects to HTTPS, or nil when
+// TLS is off or HTTPS_REDIRECT_ADDR is unset; main shuts it down along with the server
+func newRedirectServer(cfg serverConfig) *http.Server {
+	if !cfg.TLSEnabled() || cfg.RedirectAddr == "" {
+		return nil
+	}
+	return &http.Server{
+		Addr:              cfg.RedirectAddr,
+		Handler:           httpsRedirectHandler(cfg.Addr),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// listenAndServe starts the server (and the redirect listener, when not nil), blocking
+// until it fails
+func listenAndServe(cfg serverConfig, srv, redirect *http.Server) error {
+	if !cfg.TLSEnabled() {
+		return srv.ListenAndServe()
+	}
+
+	if redirect != nil {
+		go func() {
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logError("HTTPS redirect listener failed", map[string]interface{}{
+					"addr":  cfg.RedirectAddr,
+					"error": err.Error(),
+				})
+			}
+		}()
+	}
+	return srv.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-service/middleware"
+)
+
+var (
+	slos     *middleware.SLOTracker
+	slosOnce sync.Once
+)
+
+// initSLOs creates the SLO tracker the first time; the objectives are fixed for the life
+// of the process so the slo_* series stay consistent
+func initSLOs() *middleware.SLOTracker {
+	slosOnce.Do(func() {
+		slos = middleware.NewSLOTracker(middleware.SLOOptions{
+			Objectives: loadSLOObjectives(),
+			Skip:       operationalRoute,
+		})
+	})
+	return slos
+}
+
+// loadSLOObjectives reads SLO_AVAILABILITY_TARGET, SLO_LATENCY_TARGET and
+// SLO_LATENCY_THRESHOLD
+func loadSLOObjectives() []middleware.SLOObjective {
+	return []middleware.SLOObjective{
+		{Name: "availability", Target: sloTarget("SLO_AVAILABILITY_TARGET", 0.995)},
+		{Name: "latency", Target: sloTarget("SLO_LATENCY_TARGET", 0.99), Latency: getEnvDuration("SLO_LATENCY_THRESHOLD", 500*time.Millisecond)},
+	}
+}
+
+// sloTarget parses a target strictly between 0 and 1, falling back on anything else
+func sloTarget(key string, fallback float64) float64 {
+	raw := getEnv(key, "")
+	if raw == "" {
+		return fallback
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v <= 0 || v >= 1 {
+		logWarn("Ignoring invalid SLO target; expected a fraction between 0 and 1", map[string]interface{}{
+			"variable": key,
+			"value":    raw,
+		})
+		return fallback
+	}
+	return v
+}
+
+// sloHandler reports each SLO's SLI and burn rate over the tracked windows
+func sloHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, initSLOs().Status())
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxSlowDelay caps injected latency so a typo in a load test can't pin connections forever
+const maxSlowDelay = 60 * time.Second
+
+// z99 is the standard normal quantile for the 99th percentile
+const z99 = 2.3263478740
+
+type SlowResponse struct {
+	Mode      string  `json:"mode"`
+	DelayMs   float64 `json:"delay_ms"`
+	Cancelled bool    `json:"cancelled,omitempty"`
+}
+
+// parseMillis parses a non-negative millisecond query parameter
+func parseMillis(r *http.Request, name string) (float64, bool, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, true, strconv.ErrSyntax
+	}
+	return v, true, nil
+}
+
+// sampleLatency draws a delay from a log-normal distribution fitted to the given p50 and p99
+func sampleLatency(p50, p99 float64) float64 {
+	if p50 <= 0 || p99 <= p50 {
+		return p50
+	}
+	mu := math.Log(p50)
+	sigma := (math.Log(p99) - mu) / z99
+	return math.Exp(mu + sigma*rand.NormFloat64())
+}
+
+// slowHandler sleeps for a fixed (?ms=500) or sampled (?p50=50&p99=2000) delay
+// to give latency histograms and p99 panels a realistic tail during demos
+func slowHandler(w http.ResponseWriter, r *http.Request) {
+	ms, hasMs, errMs := parseMillis(r, "ms")
+	p50, hasP50, errP50 := parseMillis(r, "p50")
+	p99, hasP99, errP99 := parseMillis(r, "p99")
+	if errMs != nil || errP50 != nil || errP99 != nil {
+		writeError(w, r, badRequest("ms, p50 and p99 must be non-negative numbers"))
+		return
+	}
+
+	response := SlowResponse{Mode: "fixed"}
+	switch {
+	case hasMs:
+		response.DelayMs = ms
+	case hasP50 && hasP99:
+		if p99 < p50 {
+			writeError(w, r, badRequest("p99 must be greater than or equal to p50"))
+			return
+		}
+		response.Mode = "distribution"
+		response.DelayMs = sampleLatency(p50, p99)
+	case hasP50 || hasP99:
+		writeError(w, r, badRequest("p50 and p99 must be provided together"))
+		return
+	default:
+		writeError(w, r, badRequest("either ms or p50 and p99 is required"))
+		return
+	}
+
+	delay := time.Duration(response.DelayMs * float64(time.Millisecond))
+	if delay > maxSlowDelay {
+		delay = maxSlowDelay
+		response.DelayMs = float64(maxSlowDelay.Milliseconds())
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		writeJSON(w, http.StatusOK, response)
+	case <-r.Context().Done():
+		// Client went away; 499 keeps cancelled requests distinguishable in metrics
+		logWarnContext(r.Context(), "Slow request cancelled", map[string]interface{}{
+			"path":     r.URL.Path,
+			"delay_ms": response.DelayMs,
+			"error":    r.Context().Err().Error(),
+		})
+		response.Cancelled = true
+		writeJSON(w, 499, response)
+	}
+}
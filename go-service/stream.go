@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+const (
+	maxStreamEvents   = 10000
+	maxStreamInterval = 10 * time.Second
+)
+
+var (
+	sseEventsSent = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "sse_events_sent_total",
+			Help: "Total number of Server-Sent Events written by /stream",
+		},
+	)
+
+	sseActiveStreams = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "sse_active_streams",
+			Help: "Number of open /stream connections",
+		},
+	)
+
+	// The HTTP status of a stream is always 200, so the outcome label carries whether it finished
+	sseStreamDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "sse_stream_duration_seconds",
+			Help:    "Duration of /stream responses in seconds",
+			Buckets: []float64{.1, .5, 1, 5, 10, 30, 60, 300},
+		},
+		[]string{"outcome"},
+	)
+)
+
+func init() {
+	obs.Registry.MustRegister(sseEventsSent)
+	obs.Registry.MustRegister(sseActiveStreams)
+	obs.Registry.MustRegister(sseStreamDuration)
+}
+
+type StreamEvent struct {
+	Seq     int    `json:"seq"`
+	Time    string `json:"time"`
+	TraceID string `json:"trace_id"`
+}
+
+// parseInterval accepts a Go duration ("250ms") or a bare number of milliseconds ("250")
+func parseInterval(raw string) (time.Duration, error) {
+	if ms, err := strconv.Atoi(raw); err == nil {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	return time.ParseDuration(raw)
+}
+
+// streamHandler emits ?events=100 SSE events every ?interval=100ms, stopping early when the client disconnects
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	events := 100
+	if raw := r.URL.Query().Get("events"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 1 || v > maxStreamEvents {
+			writeError(w, r, badRequest(fmt.Sprintf("events must be between 1 and %d", maxStreamEvents)))
+			return
+		}
+		events = v
+	}
+	interval := 100 * time.Millisecond
+	if raw := r.URL.Query().Get("interval"); raw != "" {
+		v, err := parseInterval(raw)
+		if err != nil || v < 0 || v > maxStreamInterval {
+			writeError(w, r, badRequest(fmt.Sprintf("interval must be a duration between 0 and %s", maxStreamInterval)))
+			return
+		}
+		interval = v
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, newAppError(http.StatusInternalServerError, "streaming_unsupported", "streaming unsupported"))
+		return
+	}
+
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+	traceID := span.SpanContext().TraceID().String()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	sseActiveStreams.Inc()
+	defer sseActiveStreams.Dec()
+	start := time.Now()
+
+	// A zero interval sends every event back to back
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	outcome := "completed"
+	sent := 0
+	for sent < events {
+		data, _ := json.Marshal(StreamEvent{Seq: sent, Time: time.Now().UTC().Format(time.RFC3339Nano), TraceID: traceID})
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: tick\ndata: %s\n\n", sent, data); err != nil {
+			outcome = "client_disconnected"
+			break
+		}
+		flusher.Flush()
+		sent++
+		sseEventsSent.Inc()
+		if sent == events {
+			break
+		}
+
+		if tick != nil {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			outcome = "client_disconnected"
+			break
+		}
+	}
+
+	duration := time.Since(start)
+	sseStreamDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+	span.SetAttributes(
+		attribute.Int("sse.events_requested", events),
+		attribute.Int("sse.events_sent", sent),
+		attribute.String("sse.outcome", outcome),
+	)
+	logInfoContext(ctx, "SSE stream finished", map[string]interface{}{
+		"remote_addr":      r.RemoteAddr,
+		"events_requested": events,
+		"events_sent":      sent,
+		"outcome":          outcome,
+		"duration_seconds": duration.Seconds(),
+	})
+}
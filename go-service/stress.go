@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Guards keep stress endpoints from taking down the host they are demonstrating on
+var (
+	stressMaxSeconds    = getEnvInt("STRESS_MAX_SECONDS", 30)
+	stressMaxGoroutines = getEnvInt("STRESS_MAX_GOROUTINES", 2*runtime.NumCPU())
+	stressMaxMB         = getEnvInt("STRESS_MAX_MB", 1024)
+	stressMaxHold       = getEnvDuration("STRESS_MAX_HOLD", 60*time.Second)
+
+	// Only one stress run at a time so results stay attributable
+	stressRunning atomic.Bool
+
+	// stressSink keeps the compiler from optimising the CPU burn loop away
+	stressSink atomic.Uint64
+)
+
+type StressResponse struct {
+	Kind       string  `json:"kind"`
+	Seconds    float64 `json:"seconds,omitempty"`
+	Goroutines int     `json:"goroutines,omitempty"`
+	Iterations uint64  `json:"iterations,omitempty"`
+	MB         int     `json:"mb,omitempty"`
+	HoldMs     int64   `json:"hold_ms,omitempty"`
+	Cancelled  bool    `json:"cancelled,omitempty"`
+}
+
+// parseBoundedInt parses an integer query parameter within [1, max], using fallback when absent
+func parseBoundedInt(r *http.Request, name string, fallback, max int) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return fallback, true
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 1 || v > max {
+		return 0, false
+	}
+	return v, true
+}
+
+// acquireStress marks a stress run as active, rejecting concurrent runs
+func acquireStress(w http.ResponseWriter, r *http.Request) bool {
+	if !stressRunning.CompareAndSwap(false, true) {
+		writeError(w, r, newAppError(http.StatusConflict, "stress_in_progress", "a stress run is already in progress"))
+		return false
+	}
+	return true
+}
+
+// stressCPUHandler burns CPU on N goroutines for the requested duration
+func stressCPUHandler(w http.ResponseWriter, r *http.Request) {
+	seconds, ok := parseBoundedInt(r, "seconds", 5, stressMaxSeconds)
+	if !ok {
+		writeError(w, r, badRequest("seconds must be between 1 and "+strconv.Itoa(stressMaxSeconds)))
+		return
+	}
+	goroutines, ok := parseBoundedInt(r, "goroutines", runtime.NumCPU(), stressMaxGoroutines)
+	if !ok {
+		writeError(w, r, badRequest("goroutines must be between 1 and "+strconv.Itoa(stressMaxGoroutines)))
+		return
+	}
+	if !acquireStress(w, r) {
+		return
+	}
+	defer stressRunning.Store(false)
+
+	ctx, span := otel.Tracer(serviceName).Start(r.Context(), "stress.cpu")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int("stress.seconds", seconds),
+		attribute.Int("stress.goroutines", goroutines),
+	)
+
+	logInfo("CPU stress started", map[string]interface{}{
+		"seconds":    seconds,
+		"goroutines": goroutines,
+	})
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(seconds)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	var iterations atomic.Uint64
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			burnCPU(ctx, &iterations)
+		}()
+	}
+	wg.Wait()
+
+	response := StressResponse{
+		Kind:       "cpu",
+		Seconds:    time.Since(start).Seconds(),
+		Goroutines: goroutines,
+		Iterations: iterations.Load(),
+		Cancelled:  r.Context().Err() != nil,
+	}
+	span.SetAttributes(attribute.Int64("stress.iterations", int64(response.Iterations)))
+	if response.Cancelled {
+		span.SetStatus(codes.Error, "client cancelled")
+	}
+
+	logInfo("CPU stress finished", map[string]interface{}{
+		"seconds":    response.Seconds,
+		"goroutines": goroutines,
+		"iterations": response.Iterations,
+		"cancelled":  response.Cancelled,
+	})
+	writeJSON(w, http.StatusOK, response)
+}
+
+// burnCPU spins until ctx is done, checking for cancellation between batches
+func burnCPU(ctx context.Context, iterations *atomic.Uint64) {
+	x := 1.0
+	for {
+		select {
+		case <-ctx.Done():
+			stressSink.Store(math.Float64bits(x))
+			return
+		default:
+		}
+		for i := 0; i < 100000; i++ {
+			x = x*1.0000001 + 0.0000001
+		}
+		iterations.Add(1)
+	}
+}
+
+// stressMemHandler allocates and touches the requested amount of memory, holding it before release
+func stressMemHandler(w http.ResponseWriter, r *http.Request) {
+	mb, ok := parseBoundedInt(r, "mb", 256, stressMaxMB)
+	if !ok {
+		writeError(w, r, badRequest("mb must be between 1 and "+strconv.Itoa(stressMaxMB)))
+		return
+	}
+	hold := 10 * time.Second
+	if raw := r.URL.Query().Get("hold"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 || d > stressMaxHold {
+			writeError(w, r, badRequest("hold must be a duration between 0s and "+stressMaxHold.String()))
+			return
+		}
+		hold = d
+	}
+	if !acquireStress(w, r) {
+		return
+	}
+	defer stressRunning.Store(false)
+
+	_, span := otel.Tracer(serviceName).Start(r.Context(), "stress.mem")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int("stress.mb", mb),
+		attribute.Int64("stress.hold_ms", hold.Milliseconds()),
+	)
+
+	logInfo("Memory stress started", map[string]interface{}{
+		"mb":      mb,
+		"hold_ms": hold.Milliseconds(),
+	})
+
+	// Touch every page so the allocation shows up in RSS, not just virtual memory
+	chunks := make([][]byte, mb)
+	for i := range chunks {
+		chunks[i] = make([]byte, 1<<20)
+		for j := 0; j < len(chunks[i]); j += 4096 {
+			chunks[i][j] = 1
+		}
+	}
+	span.AddEvent("memory allocated")
+
+	timer := time.NewTimer(hold)
+	defer timer.Stop()
+
+	response := StressResponse{Kind: "mem", MB: mb, HoldMs: hold.Milliseconds()}
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+		response.Cancelled = true
+		span.SetStatus(codes.Error, "client cancelled")
+	}
+	runtime.KeepAlive(chunks)
+	span.AddEvent("memory released")
+
+	logInfo("Memory stress finished", map[string]interface{}{
+		"mb":        mb,
+		"hold_ms":   hold.Milliseconds(),
+		"cancelled": response.Cancelled,
+	})
+	writeJSON(w, http.StatusOK, response)
+}
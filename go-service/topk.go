@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-service/middleware"
+)
+
+var (
+	trafficStats     *middleware.TrafficStats
+	trafficStatsOnce sync.Once
+)
+
+// initTrafficStats creates the shared top-K tracker from TOPK_SIZE and TOPK_WINDOW, so the
+// public and internal routers count into the same window
+func initTrafficStats() *middleware.TrafficStats {
+	trafficStatsOnce.Do(func() {
+		trafficStats = middleware.NewTrafficStats(middleware.TrafficStatsOptions{
+			K:      getEnvInt("TOPK_SIZE", 10),
+			Window: getEnvDuration("TOPK_WINDOW", 5*time.Minute),
+		})
+	})
+	return trafficStats
+}
+
+// topKHandler reports the busiest clients, routes and statuses over the window; ?k=
+// lowers the number of entries per list
+func topKHandler(w http.ResponseWriter, r *http.Request) {
+	k, _ := strconv.Atoi(r.URL.Query().Get("k"))
+	writeJSON(w, http.StatusOK, initTrafficStats().Snapshot(k))
+}
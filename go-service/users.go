@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XSAM/otelsql"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+
+	"go-service/obs"
+)
+
+// pgUniqueViolation is the Postgres SQLSTATE for unique constraint violations
+const pgUniqueViolation = "23505"
+
+// usersDB is nil when DATABASE_URL is unset, in which case /users returns 503
+var usersDB *sql.DB
+
+type User struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type userInput struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// initDatabase opens the instrumented Postgres pool and ensures the users table exists
+func initDatabase(ctx context.Context) {
+	dsn := getEnv("DATABASE_URL", "")
+	if dsn == "" {
+		logInfo("DATABASE_URL not set, /users endpoints disabled", nil)
+		return
+	}
+
+	db, err := otelsql.Open("pgx", dsn,
+		otelsql.WithAttributes(semconv.DBSystemPostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{DisableErrSkip: true}),
+	)
+	if err != nil {
+		logError("Failed to open database", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	db.SetMaxOpenConns(getEnvInt("DATABASE_MAX_OPEN_CONNS", 10))
+	db.SetMaxIdleConns(getEnvInt("DATABASE_MAX_IDLE_CONNS", 5))
+	db.SetConnMaxLifetime(getEnvDuration("DATABASE_CONN_MAX_LIFETIME", 30*time.Minute))
+
+	// Pool metrics via both pipelines: OTel meter and Prometheus /metrics
+	if err := otelsql.RegisterDBStatsMetrics(db, otelsql.WithAttributes(semconv.DBSystemPostgreSQL)); err != nil {
+		logWarn("Failed to register database pool metrics", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	obs.Registry.MustRegister(collectors.NewDBStatsCollector(db, "users"))
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	_, err = db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS users (
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		email TEXT NOT NULL UNIQUE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		// Keep the pool: the database may come up after us, and later queries will retry
+		logError("Failed to migrate users table", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	usersDB = db
+	logInfo("Database initialized", map[string]interface{}{
+		"db_system": "postgresql",
+	})
+}
+
+// requireDB short-circuits /users requests when no database is configured
+func requireDB(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if usersDB == nil {
+			writeError(w, r, unavailable("database_not_configured", "database not configured"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// writeDBError maps database errors to HTTP responses and logs the unexpected ones
+func writeDBError(w http.ResponseWriter, r *http.Request, err error) {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, r, newAppError(http.StatusNotFound, "user_not_found", "user not found"))
+	case errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation:
+		writeError(w, r, newAppError(http.StatusConflict, "email_exists", "email already exists"))
+	case errors.Is(err, context.Canceled):
+		writeError(w, r, newAppError(499, "request_cancelled", "request cancelled").withCause(err))
+	default:
+		logErrorContext(r.Context(), "Database query failed", map[string]interface{}{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"error":  err.Error(),
+		})
+		writeError(w, r, newAppError(http.StatusInternalServerError, "database_error", "database error").withCause(err))
+	}
+}
+
+// decodeUserInput parses and validates a user create/update body
+func decodeUserInput(w http.ResponseWriter, r *http.Request) (userInput, error) {
+	var in userInput
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&in); err != nil {
+		return in, errors.New("invalid JSON body")
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	in.Email = strings.TrimSpace(in.Email)
+	if in.Name == "" || !strings.Contains(in.Email, "@") {
+		return in, errors.New("name and a valid email are required")
+	}
+	return in, nil
+}
+
+// userID parses the {id} route variable
+func userID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id, err == nil && id > 0
+}
+
+func listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, ok := parseBoundedInt(r, "limit", 50, 500)
+	if !ok {
+		writeError(w, r, badRequest("limit must be between 1 and 500"))
+		return
+	}
+
+	rows, err := usersDB.QueryContext(r.Context(),
+		`SELECT id, name, email, created_at FROM users ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+			writeDBError(w, r, err)
+			return
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+func getUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(r)
+	if !ok {
+		writeError(w, r, badRequest("invalid user id"))
+		return
+	}
+
+	var u User
+	err := usersDB.QueryRowContext(r.Context(),
+		`SELECT id, name, email, created_at FROM users WHERE id = $1`, id,
+	).Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+func createUserHandler(w http.ResponseWriter, r *http.Request) {
+	in, err := decodeUserInput(w, r)
+	if err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	u := User{Name: in.Name, Email: in.Email}
+	err = usersDB.QueryRowContext(r.Context(),
+		`INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id, created_at`, in.Name, in.Email,
+	).Scan(&u.ID, &u.CreatedAt)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+
+	logInfo("User created", map[string]interface{}{
+		"user_id": u.ID,
+	})
+	writeJSON(w, http.StatusCreated, u)
+}
+
+func updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(r)
+	if !ok {
+		writeError(w, r, badRequest("invalid user id"))
+		return
+	}
+	in, err := decodeUserInput(w, r)
+	if err != nil {
+		writeError(w, r, badRequest(err.Error()))
+		return
+	}
+
+	u := User{ID: id, Name: in.Name, Email: in.Email}
+	err = usersDB.QueryRowContext(r.Context(),
+		`UPDATE users SET name = $1, email = $2 WHERE id = $3 RETURNING created_at`, in.Name, in.Email, id,
+	).Scan(&u.CreatedAt)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+func deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := userID(r)
+	if !ok {
+		writeError(w, r, badRequest("invalid user id"))
+		return
+	}
+
+	res, err := usersDB.ExecContext(r.Context(), `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		writeDBError(w, r, err)
+		return
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		writeDBError(w, r, sql.ErrNoRows)
+		return
+	}
+
+	logInfo("User deleted", map[string]interface{}{
+		"user_id": id,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
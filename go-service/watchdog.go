@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"go-service/middleware"
+	"go-service/obs"
+)
+
+// watchdogHistory is how many checks the goroutine growth baseline looks back over
+const watchdogHistory = 8
+
+var (
+	watchdogWarnings = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "watchdog_warnings_total",
+			Help: "Total number of leak watchdog warnings, by kind (goroutine_threshold, goroutine_growth, blocked_request)",
+		},
+		[]string{"kind"},
+	)
+
+	longestInFlightRequest = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "longest_inflight_request_seconds",
+			Help: "Age of the oldest HTTP request still being served, excluding WATCHDOG_IGNORE_ROUTES",
+		},
+		func() float64 { return longestInFlight().Seconds() },
+	)
+)
+
+func init() {
+	obs.Registry.MustRegister(watchdogWarnings, longestInFlightRequest)
+}
+
+// watchdogIgnoreRoutes are route templates (path.Match patterns) that are long-lived by
+// design, such as streams and profiles, and are never reported as blocked
+var watchdogIgnoreRoutes = splitList(getEnv("WATCHDOG_IGNORE_ROUTES", "/stream,/ws/echo,/debug/pprof/*"))
+
+func watchdogIgnored(route string) bool {
+	for _, pattern := range watchdogIgnoreRoutes {
+		if ok, _ := path.Match(pattern, route); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// longestInFlight is the age of the oldest request not on an ignored route
+func longestInFlight() time.Duration {
+	for _, req := range middleware.InFlight() {
+		if !watchdogIgnored(req.Route) {
+			return time.Since(req.Start)
+		}
+	}
+	return 0
+}
+
+// watchdogConfig is read from WATCHDOG_INTERVAL (0 disables), WATCHDOG_GOROUTINE_THRESHOLD,
+// WATCHDOG_GOROUTINE_GROWTH (0 disables the growth check), WATCHDOG_REQUEST_THRESHOLD and
+// WATCHDOG_DUMP_BYTES (0 leaves goroutine dumps out of the warnings)
+type watchdogConfig struct {
+	Interval           time.Duration
+	GoroutineThreshold int
+	GoroutineGrowth    int
+	RequestThreshold   time.Duration
+	DumpBytes          int
+}
+
+func loadWatchdogConfig() watchdogConfig {
+	return watchdogConfig{
+		Interval:           getEnvDuration("WATCHDOG_INTERVAL", 15*time.Second),
+		GoroutineThreshold: getEnvInt("WATCHDOG_GOROUTINE_THRESHOLD", 1000),
+		GoroutineGrowth:    getEnvInt("WATCHDOG_GOROUTINE_GROWTH", 200),
+		RequestThreshold:   getEnvDuration("WATCHDOG_REQUEST_THRESHOLD", 30*time.Second),
+		DumpBytes:          getEnvInt("WATCHDOG_DUMP_BYTES", 32<<10),
+	}
+}
+
+// watchdog samples the goroutine count and the in-flight requests. It warns once when the
+// count crosses the threshold, again only after it has fallen back under 90% of it, and
+// when it has grown by GoroutineGrowth over the last few checks, which catches a leak long
+// before an absolute limit would. Each request older than RequestThreshold is reported
+// once, with the stacks of the goroutines labelled with its request ID.
+type watchdog struct {
+	cfg      watchdogConfig
+	history  []int
+	over     bool
+	reported map[uint64]bool
+}
+
+func newWatchdog(cfg watchdogConfig) *watchdog {
+	return &watchdog{cfg: cfg, reported: map[uint64]bool{}}
+}
+
+// startWatchdog checks every interval until ctx is done
+func startWatchdog(ctx context.Context, cfg watchdogConfig) {
+	if cfg.Interval <= 0 {
+		return
+	}
+	w := newWatchdog(cfg)
+	goBackground(func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.check()
+			}
+		}
+	})
+}
+
+func (w *watchdog) check() {
+	w.checkGoroutines(runtime.NumGoroutine())
+	w.checkRequests(time.Now())
+}
+
+func (w *watchdog) checkGoroutines(n int) {
+	reason, baseline := "", 0
+	if len(w.history) > 0 {
+		baseline = w.history[0]
+		for _, v := range w.history {
+			if v < baseline {
+				baseline = v
+			}
+		}
+	}
+	switch {
+	case w.cfg.GoroutineThreshold > 0 && n > w.cfg.GoroutineThreshold:
+		if !w.over {
+			w.over, reason = true, "goroutine_threshold"
+		}
+	case n < w.cfg.GoroutineThreshold*9/10:
+		w.over = false
+	}
+	if reason == "" && w.cfg.GoroutineGrowth > 0 && len(w.history) > 0 && n-baseline >= w.cfg.GoroutineGrowth {
+		reason = "goroutine_growth"
+	}
+
+	if reason != "" {
+		watchdogWarnings.WithLabelValues(reason).Inc()
+		fields := map[string]interface{}{
+			"reason":                   reason,
+			"goroutines":               n,
+			"baseline":                 baseline,
+			"goroutine_threshold":      w.cfg.GoroutineThreshold,
+			"goroutine_growth":         w.cfg.GoroutineGrowth,
+			"in_flight":                middleware.InFlightRequests(),
+			"longest_inflight_seconds": longestInFlight().Seconds(),
+		}
+		w.addDump(fields, "")
+		logWarn("Goroutine leak suspected", fields)
+		// Growth is measured again from here rather than re-reported every check
+		w.history = w.history[:0]
+	}
+	w.history = append(w.history, n)
+	if len(w.history) > watchdogHistory {
+		w.history = w.history[1:]
+	}
+}
+
+func (w *watchdog) checkRequests(now time.Time) {
+	if w.cfg.RequestThreshold <= 0 {
+		return
+	}
+	blocked := map[uint64]bool{}
+	for _, req := range middleware.InFlight() {
+		age := now.Sub(req.Start)
+		if age < w.cfg.RequestThreshold || watchdogIgnored(req.Route) {
+			continue
+		}
+		blocked[req.ID] = true
+		if w.reported[req.ID] {
+			continue
+		}
+		watchdogWarnings.WithLabelValues("blocked_request").Inc()
+		fields := map[string]interface{}{
+			"method":            req.Method,
+			"route":             req.Route,
+			"age_seconds":       age.Seconds(),
+			"threshold_seconds": w.cfg.RequestThreshold.Seconds(),
+		}
+		match := `"http.route":"` + req.Route + `"`
+		if req.RequestID != "" {
+			fields["request_id"] = req.RequestID
+			match = `"request_id":"` + req.RequestID + `"`
+		}
+		if req.TraceID != "" {
+			fields["trace_id"] = req.TraceID
+		}
+		w.addDump(fields, match)
+		logWarn("Request blocked past watchdog threshold", fields)
+	}
+	// Finished requests are forgotten so the map stays as small as the blocked set
+	w.reported = blocked
+}
+
+// addDump sets goroutine_dump to the goroutine profile, grouped by stack with pprof labels,
+// keeping only the groups whose labels contain match when it is set
+func (w *watchdog) addDump(fields map[string]interface{}, match string) {
+	if w.cfg.DumpBytes <= 0 {
+		return
+	}
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		fields["goroutine_dump_error"] = err.Error()
+		return
+	}
+	dump := buf.String()
+	if match != "" {
+		var kept []string
+		for _, group := range strings.Split(dump, "\n\n") {
+			if strings.Contains(group, match) {
+				kept = append(kept, group)
+			}
+		}
+		dump = strings.Join(kept, "\n\n")
+	}
+	if len(dump) > w.cfg.DumpBytes {
+		dump = dump[:w.cfg.DumpBytes]
+		fields["goroutine_dump_truncated"] = true
+	}
+	fields["goroutine_dump"] = dump
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go-service/obs"
+)
+
+// wsMaxMessageBytes caps a single inbound message; larger messages close the connection
+const wsMaxMessageBytes = 64 << 10
+
+// wsIdleTimeout closes connections that send nothing for this long
+var wsIdleTimeout = getEnvDuration("WS_IDLE_TIMEOUT", 60*time.Second)
+
+var (
+	wsActiveConnections = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "websocket_active_connections",
+			Help: "Number of open WebSocket connections",
+		},
+	)
+
+	wsMessagesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_messages_total",
+			Help: "Total number of WebSocket messages",
+		},
+		[]string{"direction"},
+	)
+
+	wsMessageBytesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "websocket_message_bytes_total",
+			Help: "Total WebSocket payload bytes",
+		},
+		[]string{"direction"},
+	)
+
+	wsConnectionDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "websocket_connection_duration_seconds",
+			Help:    "Lifetime of WebSocket connections in seconds",
+			Buckets: []float64{1, 5, 15, 30, 60, 300, 900, 3600},
+		},
+	)
+)
+
+func init() {
+	obs.Registry.MustRegister(wsActiveConnections)
+	obs.Registry.MustRegister(wsMessagesTotal)
+	obs.Registry.MustRegister(wsMessageBytesTotal)
+	obs.Registry.MustRegister(wsConnectionDuration)
+}
+
+var wsUpgrader = websocket.Upgrader{
+	CheckOrigin: wsOriginAllowed,
+}
+
+// wsOriginAllowed accepts same-origin and non-browser clients, plus any origin allowed by CORS_ALLOWED_ORIGINS
+func wsOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return loadCORSConfig().originAllowed(origin)
+}
+
+// wsEchoHandler echoes every message back. The server span covers the whole connection
+// and carries the message and byte totals once it closes.
+func wsEchoHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response
+		logWarnContext(r.Context(), "WebSocket upgrade failed", map[string]interface{}{
+			"remote_addr": r.RemoteAddr,
+			"error":       err.Error(),
+		})
+		return
+	}
+	defer conn.Close()
+
+	ctx := r.Context()
+	span := trace.SpanFromContext(ctx)
+	start := time.Now()
+	wsActiveConnections.Inc()
+	defer wsActiveConnections.Dec()
+
+	logInfoContext(ctx, "WebSocket connected", map[string]interface{}{
+		"remote_addr": r.RemoteAddr,
+		"path":        r.URL.Path,
+	})
+
+	conn.SetReadLimit(wsMaxMessageBytes)
+	var messages, bytesIn, bytesOut int64
+	var closeErr error
+	for {
+		conn.SetReadDeadline(time.Now().Add(wsIdleTimeout))
+		msgType, payload, err := conn.ReadMessage()
+		if err != nil {
+			closeErr = err
+			break
+		}
+		messages++
+		bytesIn += int64(len(payload))
+		wsMessagesTotal.WithLabelValues("received").Inc()
+		wsMessageBytesTotal.WithLabelValues("received").Add(float64(len(payload)))
+
+		if err := conn.WriteMessage(msgType, payload); err != nil {
+			closeErr = err
+			break
+		}
+		bytesOut += int64(len(payload))
+		wsMessagesTotal.WithLabelValues("sent").Inc()
+		wsMessageBytesTotal.WithLabelValues("sent").Add(float64(len(payload)))
+	}
+
+	duration := time.Since(start)
+	wsConnectionDuration.Observe(duration.Seconds())
+
+	closeCode := websocket.CloseAbnormalClosure
+	var ce *websocket.CloseError
+	if errors.As(closeErr, &ce) {
+		closeCode = ce.Code
+	}
+	span.SetAttributes(
+		attribute.Int64("websocket.messages", messages),
+		attribute.Int64("websocket.bytes_received", bytesIn),
+		attribute.Int64("websocket.bytes_sent", bytesOut),
+		attribute.Int("websocket.close_code", closeCode),
+	)
+
+	fields := map[string]interface{}{
+		"remote_addr":      r.RemoteAddr,
+		"messages":         messages,
+		"bytes_received":   bytesIn,
+		"bytes_sent":       bytesOut,
+		"close_code":       closeCode,
+		"duration_seconds": duration.Seconds(),
+	}
+	if websocket.IsCloseError(closeErr, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		logInfoContext(ctx, "WebSocket disconnected", fields)
+		return
+	}
+	span.SetStatus(codes.Error, closeErr.Error())
+	fields["error"] = closeErr.Error()
+	logWarnContext(ctx, "WebSocket disconnected", fields)
+}